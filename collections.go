@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/altmer/bellboy/media"
+	"github.com/spf13/cobra"
+)
+
+func collectionsCommand(repo media.Repository) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "collections",
+		Short: "Curates posts into named ordered collections",
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "Lists collections with numbers of posts",
+		Run: func(cmd *cobra.Command, args []string) {
+			collections, err := repo.ListCollections()
+			panicOnError(err)
+			for _, collection := range collections {
+				fmt.Printf("%6d  %s\n", collection.Posts, collection.Name)
+			}
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "show <collection>",
+		Short: "Lists posts of the collection in order",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			collection, err := repo.GetCollection(args[0])
+			panicOnError(err)
+			posts, err := repo.ListCollectionPosts(args[0])
+			panicOnError(err)
+			fmt.Printf("%s\n%s\n", collection.Name, collection.Description)
+			if collection.CoverPhotoID != nil {
+				fmt.Printf("Cover photo [%d]\n", *collection.CoverPhotoID)
+			}
+			for i, post := range posts {
+				fmt.Printf("%d. [%d] %s %s %s\n", i+1, post.ID, post.Type, post.Category, post.ExternalURL)
+			}
+		},
+	})
+
+	var description string
+	var cover uint
+	create := &cobra.Command{
+		Use:   "create <collection>",
+		Short: "Creates collection",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			collection := media.Collection{Name: args[0], Description: description}
+			if cover > 0 {
+				collection.CoverPhotoID = &cover
+			}
+			panicOnError(repo.AddCollection(&collection))
+			fmt.Printf("Collection [%s] created\n", collection.Name)
+		},
+	}
+	create.Flags().StringVarP(&description, "description", "d", "", "description of the collection")
+	create.Flags().UintVarP(&cover, "cover", "c", 0, "ID of the cover photo")
+	cmd.AddCommand(create)
+
+	var name string
+	edit := &cobra.Command{
+		Use:   "edit <collection>",
+		Short: "Changes name, description or cover of the collection",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			collection, err := repo.GetCollection(args[0])
+			panicOnError(err)
+			if cmd.Flags().Changed("name") {
+				collection.Name = name
+			}
+			if cmd.Flags().Changed("description") {
+				collection.Description = description
+			}
+			if cmd.Flags().Changed("cover") {
+				collection.CoverPhotoID = nil
+				if cover > 0 {
+					collection.CoverPhotoID = &cover
+				}
+			}
+			panicOnError(repo.UpdateCollection(&collection))
+		},
+	}
+	edit.Flags().StringVarP(&name, "name", "n", "", "new name of the collection")
+	edit.Flags().StringVarP(&description, "description", "d", "", "description of the collection")
+	edit.Flags().UintVarP(&cover, "cover", "c", 0, "ID of the cover photo, 0 removes the cover")
+	cmd.AddCommand(edit)
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "delete <collection>",
+		Short: "Deletes collection, its posts are kept",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			panicOnError(repo.DeleteCollection(args[0]))
+			fmt.Printf("Collection [%s] deleted\n", args[0])
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "add <collection> <post id>...",
+		Short: "Adds posts to the end of the collection",
+		Args:  cobra.MinimumNArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			for _, id := range parsePostIDs(args[1:]) {
+				panicOnError(repo.AddPostToCollection(args[0], id))
+			}
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "remove <collection> <post id>...",
+		Short: "Removes posts from the collection",
+		Args:  cobra.MinimumNArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			for _, id := range parsePostIDs(args[1:]) {
+				panicOnError(repo.RemovePostFromCollection(args[0], id))
+			}
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "move <collection> <post id> <position>",
+		Short: "Moves post of the collection to the position, positions start from 1",
+		Args:  cobra.ExactArgs(3),
+		Run: func(cmd *cobra.Command, args []string) {
+			id := parsePostIDs(args[1:2])[0]
+			position, err := strconv.Atoi(args[2])
+			panicOnError(err)
+			panicOnError(repo.MovePostInCollection(args[0], id, position))
+		},
+	})
+
+	var format string
+	export := &cobra.Command{
+		Use:   "export <folder>",
+		Short: "Exports collections as pages: index and a page per collection",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			exported, err := repo.ExportCollections(args[0], format)
+			panicOnError(err)
+			fmt.Printf("%d collections exported to [%s]\n", exported, args[0])
+		},
+	}
+	export.Flags().StringVarP(&format, "format", "f", "html", "json or html")
+	cmd.AddCommand(export)
+	return cmd
+}
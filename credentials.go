@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/altmer/bellboy/credentials"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// credentialsStore returns store configured by "credentials" config section,
+// vault passphrase is read from environment variable
+func credentialsStore() *credentials.Store {
+	viper.SetDefault("credentials.file", filepath.Join(bellboyDirPath(), "credentials.json"))
+	viper.SetDefault("credentials.vault", filepath.Join(bellboyDirPath(), "credentials.vault"))
+	viper.SetDefault("credentials.passphrase_env", "BELLBOY_VAULT_PASSPHRASE")
+	return &credentials.Store{
+		Path:       viper.GetString("credentials.file"),
+		VaultPath:  viper.GetString("credentials.vault"),
+		Passphrase: os.Getenv(viper.GetString("credentials.passphrase_env")),
+	}
+}
+
+// checkConfigSecrets refuses config file which keeps secrets and is accessible by other users
+func checkConfigSecrets() error {
+	for _, key := range viper.AllKeys() {
+		parts := strings.Split(key, ".")
+		if credentials.IsSecret(parts[len(parts)-1]) && viper.GetString(key) != "" {
+			return credentials.CheckPermissions(viper.ConfigFileUsed())
+		}
+	}
+	return nil
+}
+
+func credentialsCommand(store *credentials.Store, tumblrKey string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "credentials",
+		Short: "Manages secrets of the tumblr config section of the profile",
+	}
+
+	var vault bool
+	set := &cobra.Command{
+		Use:   "set <name> [value]",
+		Short: "Saves secret to credentials file or vault, value is read from input when omitted, empty value removes it",
+		Args:  cobra.RangeArgs(1, 2),
+		Run: func(cmd *cobra.Command, args []string) {
+			var value string
+			if len(args) == 2 {
+				value = args[1]
+			} else {
+				fmt.Printf("Value of [%s]: ", args[0])
+				line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+				if line == "" {
+					panicOnError(err)
+				}
+				value = strings.TrimSpace(line)
+			}
+			panicOnError(store.Set(tumblrKey, args[0], value, vault))
+			location := store.Path
+			if vault {
+				location = store.VaultPath
+			}
+			fmt.Printf("Secret [%s] of [%s] saved to [%s]\n", args[0], tumblrKey, location)
+			if viper.GetString(tumblrKey+"."+args[0]) != "" {
+				fmt.Printf("Secret [%s] is still in [%s], remove it from there\n", args[0], viper.ConfigFileUsed())
+			}
+		},
+	}
+	set.Flags().BoolVar(&vault, "vault", false, "save to vault encrypted with passphrase instead of credentials file")
+	cmd.AddCommand(set)
+
+	var redacted bool
+	show := &cobra.Command{
+		Use:   "show",
+		Short: "Prints secrets with their sources",
+		Run: func(cmd *cobra.Command, args []string) {
+			secrets, err := store.List(tumblrKey, viper.GetStringMapString(tumblrKey))
+			panicOnError(err)
+			for _, secret := range secrets {
+				value := secret.Value
+				if redacted {
+					value = credentials.Redact(value)
+				}
+				fmt.Printf("%s [%s] %s\n", secret.Name, secret.Source, value)
+			}
+			fmt.Printf("%d secrets of [%s]\n", len(secrets), tumblrKey)
+		},
+	}
+	show.Flags().BoolVar(&redacted, "redacted", false, "hide values")
+	cmd.AddCommand(show)
+	return cmd
+}
@@ -0,0 +1,299 @@
+package credentials
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/altmer/bellboy/media"
+)
+
+// Names of config settings which are secrets
+var Names = []string{
+	"consumer_key", "consumer_secret", "oauth_key", "oauth_secret",
+	"oauth2_access_token", "oauth2_refresh_token",
+}
+
+// Sources of credentials, earlier sources override later ones
+const (
+	SourceEnv    = "env"
+	SourceFile   = "file"
+	SourceVault  = "vault"
+	SourceConfig = "config"
+)
+
+const vaultKeyPurpose = "bellboy credentials vault"
+
+// Credential is a secret with the source it is read from
+type Credential struct {
+	Name   string
+	Value  string
+	Source string
+}
+
+// Store reads secrets of config sections from environment variables, credentials file
+// and encrypted vault. Both files keep secrets by section: {"tumblr": {"oauth_key": "..."}}
+// and should be readable only by the owner.
+type Store struct {
+	Path       string // credentials file, missing file has no secrets
+	VaultPath  string // encrypted vault, missing vault has no secrets
+	Passphrase string // unlocks vault
+}
+
+// sections are secrets of config sections by their names
+type sections map[string]map[string]string
+
+// vaultFile is the vault on disk, secrets are sealed with key derived from passphrase and salt
+type vaultFile struct {
+	Salt string `json:"salt"`
+	Data string `json:"data"`
+}
+
+// IsSecret tells whether config setting is a secret
+func IsSecret(name string) bool {
+	for _, secret := range Names {
+		if name == secret {
+			return true
+		}
+	}
+	return false
+}
+
+// EnvName returns environment variable with the secret of config section,
+// f.ex. BELLBOY_TUMBLR_OAUTH_KEY or BELLBOY_PROFILES_WORK_TUMBLR_OAUTH_KEY
+func EnvName(section, name string) string {
+	return "BELLBOY_" + strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, strings.ToUpper(section+"_"+name))
+}
+
+// CheckPermissions fails when file could be read or written by other users,
+// missing files are fine
+func CheckPermissions(path string) error {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	// there are no unix permissions on windows
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0077 != 0 {
+		return fmt.Errorf("file [%s] with secrets is accessible by other users, run: chmod 600 %s", path, path)
+	}
+	return nil
+}
+
+// Redact hides the secret leaving last characters of long values to tell them apart
+func Redact(value string) string {
+	if len(value) < 12 {
+		return "********"
+	}
+	return "********" + value[len(value)-4:]
+}
+
+// List returns secrets of config section from all sources, config values are used
+// for secrets not found elsewhere
+func (s *Store) List(section string, config map[string]string) ([]Credential, error) {
+	file, err := s.readFile()
+	if err != nil {
+		return nil, err
+	}
+	vault, err := s.readVault()
+	if err != nil {
+		return nil, err
+	}
+
+	var credentials []Credential
+	for _, name := range Names {
+		credential := Credential{Name: name}
+		if value := os.Getenv(EnvName(section, name)); value != "" {
+			credential.Value, credential.Source = value, SourceEnv
+		} else if value := file[section][name]; value != "" {
+			credential.Value, credential.Source = value, SourceFile
+		} else if value := vault[section][name]; value != "" {
+			credential.Value, credential.Source = value, SourceVault
+		} else if value := config[name]; value != "" {
+			credential.Value, credential.Source = value, SourceConfig
+		} else {
+			continue
+		}
+		credentials = append(credentials, credential)
+	}
+	return credentials, nil
+}
+
+// Resolve returns copy of config section with secrets taken from all sources
+func (s *Store) Resolve(section string, config map[string]string) (map[string]string, error) {
+	credentials, err := s.List(section, config)
+	if err != nil {
+		return nil, err
+	}
+	params := map[string]string{}
+	for key, value := range config {
+		params[key] = value
+	}
+	for _, credential := range credentials {
+		params[credential.Name] = credential.Value
+	}
+	return params, nil
+}
+
+// Set saves secret of config section to credentials file or to the vault,
+// empty value removes the secret
+func (s *Store) Set(section, name, value string, vault bool) error {
+	if !IsSecret(name) {
+		return fmt.Errorf("unknown secret [%s], should be one of %v", name, Names)
+	}
+	read, write := s.readFile, s.writeFile
+	if vault {
+		read, write = s.readVault, s.writeVault
+	}
+	secrets, err := read()
+	if err != nil {
+		return err
+	}
+	if secrets[section] == nil {
+		secrets[section] = map[string]string{}
+	}
+	if value == "" {
+		delete(secrets[section], name)
+	} else {
+		secrets[section][name] = value
+	}
+	if len(secrets[section]) == 0 {
+		delete(secrets, section)
+	}
+	return write(secrets)
+}
+
+func (s *Store) readFile() (sections, error) {
+	data, err := readSecretFile(s.Path)
+	if err != nil || data == nil {
+		return sections{}, err
+	}
+	secrets := sections{}
+	err = json.Unmarshal(data, &secrets)
+	if err != nil {
+		return nil, fmt.Errorf("can't parse credentials file [%s]: %s", s.Path, err)
+	}
+	return secrets, nil
+}
+
+func (s *Store) writeFile(secrets sections) error {
+	if s.Path == "" {
+		return fmt.Errorf("credentials file is not set")
+	}
+	data, err := json.MarshalIndent(secrets, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeSecretFile(s.Path, data)
+}
+
+func (s *Store) readVault() (sections, error) {
+	data, err := readSecretFile(s.VaultPath)
+	if err != nil || data == nil {
+		return sections{}, err
+	}
+	var vault vaultFile
+	err = json.Unmarshal(data, &vault)
+	if err != nil {
+		return nil, fmt.Errorf("can't parse vault [%s]: %s", s.VaultPath, err)
+	}
+	key, err := s.vaultKey(vault.Salt)
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := base64.StdEncoding.DecodeString(vault.Data)
+	if err != nil {
+		return nil, fmt.Errorf("can't parse vault [%s]: %s", s.VaultPath, err)
+	}
+	plain, err := key.Open(vaultKeyPurpose, sealed)
+	if err != nil {
+		return nil, fmt.Errorf("can't unlock vault [%s]: passphrase is wrong or vault is corrupted", s.VaultPath)
+	}
+	secrets := sections{}
+	return secrets, json.Unmarshal(plain, &secrets)
+}
+
+// writeVault seals secrets with new salt every time
+func (s *Store) writeVault(secrets sections) error {
+	if s.VaultPath == "" {
+		return fmt.Errorf("vault file is not set")
+	}
+	vault := vaultFile{Salt: media.GenerateSalt()}
+	key, err := s.vaultKey(vault.Salt)
+	if err != nil {
+		return err
+	}
+	plain, err := json.Marshal(secrets)
+	if err != nil {
+		return err
+	}
+	sealed, err := key.Seal(vaultKeyPurpose, plain)
+	if err != nil {
+		return err
+	}
+	vault.Data = base64.StdEncoding.EncodeToString(sealed)
+	data, err := json.MarshalIndent(vault, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeSecretFile(s.VaultPath, data)
+}
+
+func (s *Store) vaultKey(salt string) (*media.Key, error) {
+	if s.Passphrase == "" {
+		return nil, fmt.Errorf("vault [%s] is locked, passphrase is not set", s.VaultPath)
+	}
+	saltBytes, err := hex.DecodeString(salt)
+	if err != nil {
+		return nil, fmt.Errorf("salt of vault [%s] should be hex encoded: [%s]", s.VaultPath, err)
+	}
+	return media.KeyFromPassphrase(s.Passphrase, saltBytes)
+}
+
+// readSecretFile returns nil for missing files and refuses files accessible by other users
+func readSecretFile(path string) ([]byte, error) {
+	if path == "" {
+		return nil, nil
+	}
+	err := CheckPermissions(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return data, err
+}
+
+// writeSecretFile replaces file with new one readable only by the owner
+func writeSecretFile(path string, data []byte) error {
+	temp := path + ".tmp"
+	// stale temporary file could have wider permissions
+	os.Remove(temp)
+	file, err := os.OpenFile(temp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(temp)
+		return err
+	}
+	return os.Rename(temp, path)
+}
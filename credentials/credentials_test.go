@@ -0,0 +1,138 @@
+package credentials
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func checkErrors(t *testing.T, expectedErr, err error) {
+	if err == nil || expectedErr == nil {
+		if err != expectedErr {
+			t.Errorf("Expected error to be [%#v], got [%#v]", expectedErr, err)
+		}
+	} else {
+		if err.Error() != expectedErr.Error() {
+			t.Errorf("Expected to fail with error [%#v], got [%#v]", expectedErr.Error(), err.Error())
+		}
+	}
+}
+
+func newTestStore(t *testing.T) (*Store, string, func()) {
+	folder, err := ioutil.TempDir("", "bellboy_credentials")
+	checkErrors(t, nil, err)
+	store := &Store{
+		Path:       filepath.Join(folder, "credentials.json"),
+		VaultPath:  filepath.Join(folder, "credentials.vault"),
+		Passphrase: "correct horse battery staple",
+	}
+	return store, folder, func() { os.RemoveAll(folder) }
+}
+
+func TestEnvName(t *testing.T) {
+	assert.Equal(t, "BELLBOY_TUMBLR_OAUTH_KEY", EnvName("tumblr", "oauth_key"))
+	assert.Equal(t, "BELLBOY_PROFILES_MY_ART_TUMBLR_CONSUMER_SECRET", EnvName("profiles.my-art.tumblr", "consumer_secret"))
+}
+
+func TestRedact(t *testing.T) {
+	assert.Equal(t, "********", Redact("short"))
+	assert.Equal(t, "********wxyz", Redact("abcdefghijklmnopqrstuvwxyz"))
+}
+
+func TestSetAndList(t *testing.T) {
+	store, _, teardown := newTestStore(t)
+	defer teardown()
+
+	credentials, err := store.List("tumblr", map[string]string{"blog": "art", "oauth_key": "config-key"})
+	checkErrors(t, nil, err)
+	assert.Equal(t, []Credential{{Name: "oauth_key", Value: "config-key", Source: SourceConfig}}, credentials)
+
+	checkErrors(t, nil, store.Set("tumblr", "oauth_key", "file-key", false))
+	checkErrors(t, nil, store.Set("tumblr", "oauth_secret", "file-secret", false))
+	checkErrors(t, nil, store.Set("profiles.work.tumblr", "oauth_key", "work-key", false))
+	checkErrors(t, errors.New("unknown secret [blog], should be one of "+fmt.Sprint(Names)), store.Set("tumblr", "blog", "art", false))
+
+	info, err := os.Stat(store.Path)
+	checkErrors(t, nil, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	credentials, err = store.List("tumblr", map[string]string{"oauth_key": "config-key"})
+	checkErrors(t, nil, err)
+	assert.Equal(t, []Credential{
+		{Name: "oauth_key", Value: "file-key", Source: SourceFile},
+		{Name: "oauth_secret", Value: "file-secret", Source: SourceFile},
+	}, credentials)
+
+	checkErrors(t, nil, store.Set("tumblr", "oauth_secret", "", false))
+	credentials, err = store.List("tumblr", nil)
+	checkErrors(t, nil, err)
+	assert.Equal(t, []Credential{{Name: "oauth_key", Value: "file-key", Source: SourceFile}}, credentials)
+}
+
+func TestEnvOverridesFile(t *testing.T) {
+	store, _, teardown := newTestStore(t)
+	defer teardown()
+
+	checkErrors(t, nil, store.Set("tumblr", "consumer_key", "file-key", false))
+	os.Setenv("BELLBOY_TUMBLR_CONSUMER_KEY", "env-key")
+	defer os.Unsetenv("BELLBOY_TUMBLR_CONSUMER_KEY")
+
+	params, err := store.Resolve("tumblr", map[string]string{"blog": "art", "consumer_key": "config-key"})
+	checkErrors(t, nil, err)
+	assert.Equal(t, map[string]string{"blog": "art", "consumer_key": "env-key"}, params)
+}
+
+func TestRefusesFilesAccessibleByOthers(t *testing.T) {
+	store, _, teardown := newTestStore(t)
+	defer teardown()
+
+	checkErrors(t, nil, store.Set("tumblr", "oauth_key", "file-key", false))
+	checkErrors(t, nil, os.Chmod(store.Path, 0644))
+
+	expectedErr := fmt.Errorf("file [%s] with secrets is accessible by other users, run: chmod 600 %s", store.Path, store.Path)
+	_, err := store.List("tumblr", nil)
+	checkErrors(t, expectedErr, err)
+	checkErrors(t, expectedErr, store.Set("tumblr", "oauth_secret", "file-secret", false))
+	checkErrors(t, expectedErr, CheckPermissions(store.Path))
+
+	checkErrors(t, nil, os.Chmod(store.Path, 0600))
+	checkErrors(t, nil, CheckPermissions(store.Path))
+	checkErrors(t, nil, CheckPermissions(store.Path+".missing"))
+}
+
+func TestVault(t *testing.T) {
+	store, _, teardown := newTestStore(t)
+	defer teardown()
+
+	checkErrors(t, nil, store.Set("tumblr", "oauth_secret", "vault-secret", true))
+	checkErrors(t, nil, store.Set("tumblr", "oauth_key", "vault-key", true))
+	checkErrors(t, nil, store.Set("tumblr", "oauth_key", "file-key", false))
+
+	data, err := ioutil.ReadFile(store.VaultPath)
+	checkErrors(t, nil, err)
+	assert.False(t, strings.Contains(string(data), "vault-secret"))
+	info, err := os.Stat(store.VaultPath)
+	checkErrors(t, nil, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	credentials, err := store.List("tumblr", nil)
+	checkErrors(t, nil, err)
+	assert.Equal(t, []Credential{
+		{Name: "oauth_key", Value: "file-key", Source: SourceFile},
+		{Name: "oauth_secret", Value: "vault-secret", Source: SourceVault},
+	}, credentials)
+
+	store.Passphrase = "wrong"
+	_, err = store.List("tumblr", nil)
+	checkErrors(t, fmt.Errorf("can't unlock vault [%s]: passphrase is wrong or vault is corrupted", store.VaultPath), err)
+
+	store.Passphrase = ""
+	_, err = store.List("tumblr", nil)
+	checkErrors(t, fmt.Errorf("vault [%s] is locked, passphrase is not set", store.VaultPath), err)
+}
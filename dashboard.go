@@ -0,0 +1,29 @@
+package main
+
+import (
+	"github.com/altmer/bellboy/tumblr"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func dashboardCommand(syncer tumblr.Syncer) *cobra.Command {
+	// filters from "dashboard" config section are used unless flags are given
+	filter := tumblr.PostFilter{
+		Blogs:    viper.GetStringSlice("dashboard.blogs"),
+		Tags:     viper.GetStringSlice("dashboard.tags"),
+		Types:    viper.GetStringSlice("dashboard.types"),
+		MinNotes: viper.GetInt("dashboard.min_notes"),
+	}
+	cmd := &cobra.Command{
+		Use:   "dashboard",
+		Short: "Queues new dashboard posts matching filters for review",
+		Run: func(cmd *cobra.Command, args []string) {
+			syncer.Dashboard(filter)
+		},
+	}
+	cmd.Flags().StringSliceVar(&filter.Blogs, "blog", filter.Blogs, "only posts of the blogs")
+	cmd.Flags().StringSliceVar(&filter.Tags, "tag", filter.Tags, "only posts with any of the tags")
+	cmd.Flags().StringSliceVar(&filter.Types, "type", filter.Types, "only posts of the types: photo, video, text or link")
+	cmd.Flags().IntVar(&filter.MinNotes, "min-notes", filter.MinNotes, "skip posts with fewer notes")
+	return cmd
+}
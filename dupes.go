@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/altmer/bellboy/media"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func dupesCommand(repo media.Repository) *cobra.Command {
+	var distance int
+	var assumeYes bool
+
+	cmd := &cobra.Command{
+		Use:   "dupes",
+		Short: "Finds near-duplicate photos and keeps the highest-resolution copy",
+		Run: func(cmd *cobra.Command, args []string) {
+			analyzed, err := repo.AnalyzePhotos()
+			panicOnError(err)
+			fmt.Printf("%d new photos analyzed\n", analyzed)
+
+			photos, err := repo.ListFingerprintedPhotos()
+			panicOnError(err)
+			clusters := media.ClusterDuplicates(photos, distance)
+			fmt.Printf("%d duplicate clusters found\n", len(clusters))
+
+			input := bufio.NewReader(os.Stdin)
+			for i, cluster := range clusters {
+				keep, duplicates := cluster[0], cluster[1:]
+				fmt.Printf("Cluster %d:\n", i+1)
+				for _, photo := range cluster {
+					marker := " "
+					if photo.ID == keep.ID {
+						marker = "*"
+					}
+					fmt.Printf("  %s photo [%d] of post [%d] %dx%d %s\n",
+						marker, photo.ID, photo.PostID, photo.Width, photo.Height, repo.GetPhotoPath(&photo))
+				}
+				if !assumeYes && !confirm(input, fmt.Sprintf("Keep photo [%d] and merge %d duplicate(s)?", keep.ID, len(duplicates))) {
+					continue
+				}
+				err = repo.MergeDuplicatePhotos(keep, duplicates)
+				if err != nil {
+					fmt.Printf("WARN: Merging duplicates of photo [%d] failed with error [%s]\n", keep.ID, err)
+				}
+			}
+		},
+	}
+	viper.SetDefault("dupes.distance", 6)
+	cmd.Flags().IntVarP(&distance, "distance", "d", viper.GetInt("dupes.distance"), "maximum Hamming distance between hashes of duplicates")
+	cmd.Flags().BoolVarP(&assumeYes, "yes", "y", false, "merge all clusters without asking")
+	return cmd
+}
+
+func confirm(input *bufio.Reader, question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+	answer, _ := input.ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
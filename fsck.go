@@ -0,0 +1,46 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/altmer/bellboy/media"
+	"github.com/spf13/cobra"
+)
+
+func fsckCommand(repo media.Repository) *cobra.Command {
+	var options media.FsckOptions
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "fsck",
+		Short: "Checks that database and media folder are consistent, prints JSON report",
+		Run: func(cmd *cobra.Command, args []string) {
+			report, err := repo.Fsck(options)
+			panicOnError(err)
+
+			var out io.Writer = os.Stdout
+			if output != "" {
+				file, err := os.Create(output)
+				panicOnError(err)
+				defer file.Close()
+				out = file
+			}
+			encoder := json.NewEncoder(out)
+			encoder.SetIndent("", "  ")
+			panicOnError(encoder.Encode(report))
+
+			unresolved := report.Unresolved()
+			fmt.Fprintf(os.Stderr, "%d issues found, %d unresolved\n", len(report.Issues), unresolved)
+			if unresolved > 0 {
+				os.Exit(1)
+			}
+		},
+	}
+	cmd.Flags().BoolVar(&options.Repair, "repair", false, "re-download missing media, remove orphaned files and dangling tag links")
+	cmd.Flags().StringVar(&options.QuarantineFolder, "quarantine", "", "move orphaned files to this folder instead of deleting them")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "write report to the file instead of standard output")
+	return cmd
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"time"
+
+	"github.com/altmer/bellboy/tumblr"
+	"github.com/spf13/cobra"
+)
+
+func harvestCommand(syncer tumblr.Syncer) *cobra.Command {
+	var options tumblr.HarvestOptions
+	var since string
+	cmd := &cobra.Command{
+		Use:   "harvest",
+		Short: "Queues posts with the tag from all blogs for review",
+		Run: func(cmd *cobra.Command, args []string) {
+			if since != "" {
+				var err error
+				options.Since, err = time.Parse("2006-01-02", since)
+				panicOnError(err)
+			}
+			syncer.Harvest(options)
+		},
+	}
+	cmd.Flags().StringVar(&options.Tag, "tag", "", "tag to harvest")
+	cmd.MarkFlagRequired("tag")
+	cmd.Flags().IntVar(&options.Limit, "limit", 100, "maximum number of harvested posts, 0 for no limit")
+	cmd.Flags().StringVar(&since, "since", "", "skip posts older than the date, f.ex. 2020-01-31")
+	cmd.Flags().StringSliceVar(&options.Types, "type", nil, "post types to harvest: photo, video, text or link")
+	cmd.Flags().IntVar(&options.MinNotes, "min-notes", 0, "skip posts with fewer notes")
+	return cmd
+}
@@ -4,6 +4,9 @@ import (
 	"os"
 	"os/user"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 )
 
 func userFolder() string {
@@ -34,3 +37,25 @@ func panicOnError(err error) {
 		panic(err)
 	}
 }
+
+// parsePostIDs converts command arguments to post IDs
+func parsePostIDs(args []string) []uint {
+	var ids []uint
+	for _, arg := range args {
+		id, err := strconv.ParseUint(arg, 10, 64)
+		panicOnError(err)
+		ids = append(ids, uint(id))
+	}
+	return ids
+}
+
+// parseAge parses durations like "30d", "2w" or "1y" in addition to ones time.ParseDuration knows
+func parseAge(value string) (time.Duration, error) {
+	units := map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour, "y": 365 * 24 * time.Hour}
+	for suffix, unit := range units {
+		if count, err := strconv.Atoi(strings.TrimSuffix(value, suffix)); strings.HasSuffix(value, suffix) && err == nil {
+			return time.Duration(count) * unit, nil
+		}
+	}
+	return time.ParseDuration(value)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/altmer/bellboy/media"
+	"github.com/jmoiron/sqlx"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func keysCommand(db *sqlx.DB, store media.Store, current *media.Encryption) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "keys",
+		Short: "Manages keys of at-rest encryption",
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "generate <keyfile>",
+		Short: "Creates new random key file",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			panicOnError(media.GenerateKeyFile(args[0]))
+			fmt.Printf("Key file [%s] created\n", args[0])
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "salt",
+		Short: "Prints random salt for passphrase keys",
+		Run: func(cmd *cobra.Command, args []string) {
+			fmt.Println(media.GenerateSalt())
+		},
+	})
+
+	var mode, keyFile, passphraseEnv, salt string
+	rotate := &cobra.Command{
+		Use:   "rotate",
+		Short: "Re-encrypts all media files and sensitive columns with new key",
+		Run: func(cmd *cobra.Command, args []string) {
+			if mode == "passphrase" && salt == "" {
+				salt = media.GenerateSalt()
+			}
+			key, err := media.LoadKey(mode, keyFile, passphraseEnv, salt)
+			panicOnError(err)
+			if key == nil {
+				panic("new key mode should be one of [keyfile passphrase]")
+			}
+
+			// settings are printed first: files resealed before a failure
+			// can be read only with the new key and its salt
+			fmt.Println("Update encryption settings in configuration file:")
+			fmt.Printf("  \"mode\": %q\n", mode)
+			if mode == "keyfile" {
+				fmt.Printf("  \"keyfile\": %q\n", keyFile)
+			} else {
+				fmt.Printf("  \"passphrase_env\": %q\n", passphraseEnv)
+				fmt.Printf("  \"salt\": %q\n", salt)
+			}
+
+			keys := &media.Keyring{Current: key}
+			if current != nil {
+				keys.Previous = append(keys.Previous, current.Keys.Current)
+			}
+			repo := media.NewEncryptedRepository(db, store, media.Encryption{
+				Keys:    keys,
+				Columns: viper.GetBool("encryption.columns"),
+			})
+			files, values, err := repo.Reencrypt()
+			if err != nil {
+				fmt.Printf("Rotation failed, %d files are already re-encrypted with key [%s]:\n", len(files), key.ID())
+				for _, name := range files {
+					fmt.Printf("  %s\n", name)
+				}
+				fmt.Println("Run rotation again with the same settings to finish it")
+			}
+			panicOnError(err)
+			fmt.Printf("%d files and %d column values re-encrypted with key [%s]\n", len(files), values, key.ID())
+		},
+	}
+	rotate.Flags().StringVar(&mode, "mode", "keyfile", "kind of new key: keyfile or passphrase")
+	rotate.Flags().StringVar(&keyFile, "keyfile", "", "path to new key file")
+	rotate.Flags().StringVar(&passphraseEnv, "passphrase-env", "BELLBOY_NEW_PASSPHRASE", "environment variable with new passphrase")
+	rotate.Flags().StringVar(&salt, "salt", "", "salt for new passphrase, generated when empty")
+	cmd.AddCommand(rotate)
+	return cmd
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/altmer/bellboy/credentials"
+	"github.com/altmer/bellboy/tumblr"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func loginCommand(tumblrKey string, params map[string]string, secrets *credentials.Store) *cobra.Command {
+	var paste, oauth2 bool
+	var callbackAddress string
+	cmd := &cobra.Command{
+		Use:   "login",
+		Short: "Authorizes bellboy with tumblr account and saves access token to the credentials file",
+		Run: func(cmd *cobra.Command, args []string) {
+			consumerKey := params["consumer_key"]
+			consumerSecret := params["consumer_secret"]
+			if consumerKey == "" || consumerSecret == "" {
+				panic(fmt.Errorf("consumer_key and consumer_secret of [%s] should be set with \"bellboy credentials set\"", tumblrKey))
+			}
+
+			if oauth2 {
+				callback, err := tumblr.NewCallbackServerAt(callbackAddress)
+				panicOnError(err)
+				defer callback.Close()
+				token, err := tumblr.LoginOAuth2(tumblr.TumblrOAuth2Endpoints, consumerKey, consumerSecret, callback.URL,
+					func(authorizeURL string) (string, string, error) {
+						fmt.Printf("Open the page and allow access:\n%s\n", authorizeURL)
+						query, err := callback.Wait(10 * time.Minute)
+						return query.Get("code"), query.Get("state"), err
+					})
+				panicOnError(err)
+				viper.Set(tumblrKey+".auth", "oauth2")
+				panicOnError(saveOAuth2Token(tumblrKey, secrets)(token))
+				fmt.Printf("OAuth2 token saved to [%s]\n", secrets.Path)
+				return
+			}
+
+			callbackURL := "oob"
+			authorize := func(authorizeURL string) (string, string, error) {
+				fmt.Printf("Open the page and allow access:\n%s\n", authorizeURL)
+				fmt.Print("Paste the address of the page you were redirected to: ")
+				line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+				return "", tumblr.ParseVerifier(line), err
+			}
+			if !paste {
+				callback, err := tumblr.NewCallbackServerAt(callbackAddress)
+				panicOnError(err)
+				defer callback.Close()
+				callbackURL = callback.URL
+				authorize = func(authorizeURL string) (string, string, error) {
+					fmt.Printf("Open the page and allow access:\n%s\n", authorizeURL)
+					query, err := callback.Wait(10 * time.Minute)
+					return query.Get("oauth_token"), query.Get("oauth_verifier"), err
+				}
+			}
+
+			token, err := tumblr.LoginOAuth1(tumblr.TumblrOAuthEndpoints, consumerKey, consumerSecret, callbackURL, authorize)
+			panicOnError(err)
+			panicOnError(secrets.Set(tumblrKey, "oauth_key", token.Key, false))
+			panicOnError(secrets.Set(tumblrKey, "oauth_secret", token.Secret, false))
+			viper.Set(tumblrKey+".auth", "oauth1")
+			panicOnError(viper.WriteConfig())
+			fmt.Printf("Access token saved to [%s]\n", secrets.Path)
+		},
+	}
+	cmd.Flags().BoolVar(&paste, "paste", false, "paste verifier instead of catching the redirect on local port (OAuth 1.0a only)")
+	cmd.Flags().BoolVar(&oauth2, "oauth2", false, "use OAuth2 authorization code flow with PKCE instead of OAuth 1.0a")
+	cmd.Flags().StringVar(&callbackAddress, "callback", "127.0.0.1:0", "local address catching the redirect, f.ex. 127.0.0.1:8765")
+	return cmd
+}
+
+// saveOAuth2Token returns function writing OAuth2 token to the credentials file,
+// expiry time is not a secret and is kept in the config section
+func saveOAuth2Token(tumblrKey string, secrets *credentials.Store) func(tumblr.OAuth2Token) error {
+	return func(token tumblr.OAuth2Token) error {
+		expiry := ""
+		if !token.Expiry.IsZero() {
+			expiry = token.Expiry.Format(time.RFC3339)
+		}
+		err := secrets.Set(tumblrKey, "oauth2_access_token", token.AccessToken, false)
+		if err == nil {
+			err = secrets.Set(tumblrKey, "oauth2_refresh_token", token.RefreshToken, false)
+		}
+		if err != nil {
+			return err
+		}
+		viper.Set(tumblrKey+".oauth2_expiry", expiry)
+		return viper.WriteConfig()
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/altmer/bellboy/tumblr"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"os"
 )
 
 const version = "0.0.1"
@@ -21,44 +22,110 @@ func main() {
 	if err != nil {
 		panic(err)
 	}
+	err = checkConfigSecrets()
+	if err != nil {
+		panic(err)
+	}
 
 	db := context.NewDBConnection(viper.GetString("db"))
 	defer db.Close()
 
 	fmt.Printf("Opened database at [%s]\n", viper.GetString("db"))
-	fmt.Printf("Media folder is [%s]\n", viper.GetString("media_folder"))
+
+	store, err := media.NewStore()
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("Media storage is [%s]\n", store.Location(""))
+
+	encryption, err := media.LoadEncryption()
+	if err != nil {
+		panic(err)
+	}
+	var repo media.Repository
+	if encryption != nil {
+		repo = media.NewEncryptedRepository(db, store, *encryption)
+	} else {
+		repo = media.NewRepositoryWithStore(db, store)
+	}
+
+	profile := profileFlag(os.Args[1:])
+	tumblrKey := tumblrConfigKey(profile)
+	blogName, blogs := profileBlogs(tumblrKey)
+
+	photoSizes, err := tumblr.ParsePhotoSizePolicy(viper.GetString(tumblrKey + ".photo_size"))
+	if err != nil {
+		panic(err)
+	}
+
+	rules, err := media.LoadRules(rulesPath())
+	if err != nil {
+		panic(err)
+	}
+
+	secrets := credentialsStore()
+	params, err := secrets.Resolve(tumblrKey, viper.GetStringMapString(tumblrKey))
+	if err != nil {
+		panic(err)
+	}
+	auth, err := tumblr.NewAuthenticator(params, saveOAuth2Token(tumblrKey, secrets))
+	if err != nil {
+		panic(err)
+	}
 
 	syncer := tumblr.Syncer{
-		BlogName: viper.GetString("tumblr.blog"),
-		Client:   tumblr.New(viper.GetStringMapString("tumblr")),
-		Repo:     media.NewRepository(db),
+		BlogName:   blogName,
+		Blogs:      blogs,
+		Account:    profileAccount(profile, tumblrKey),
+		Client:     tumblr.NewWithAuthenticator(auth, params["consumer_key"]),
+		Repo:       repo,
+		PhotoSizes: photoSizes,
+		Tags:       media.LoadTagNormalizer(),
+
+		SuggestTags: viper.GetBool(tumblrKey + ".suggest_tags"),
+		Rules:       rules,
 	}
 
+	var refresh bool
 	var cmdSync = &cobra.Command{
 		Use:   "sync",
-		Short: "Sync tumblr blog posts and likes",
+		Short: "Sync tumblr blogs posts and likes of the account",
 		Run: func(cmd *cobra.Command, args []string) {
+			if refresh {
+				policy := media.DefaultPostMergePolicy
+				syncer.Refresh = &policy
+			}
 			syncer.Sync()
 		},
 	}
+	cmdSync.Flags().BoolVar(&refresh, "refresh", false, "update likes, summaries and tags of posts synced before")
 
+	var dryRun, unfollow bool
 	var cmdSubsDown = &cobra.Command{
 		Use:   "subsdown",
-		Short: "Loads tumblr subscriptions to local DB (destructive operation!)",
+		Short: "Makes local subscriptions match tumblr ones, local notes are kept",
 		Run: func(cmd *cobra.Command, args []string) {
+			syncer.DryRun = dryRun
 			syncer.SubsDown()
 		},
 	}
+	cmdSubsDown.Flags().BoolVar(&dryRun, "dry-run", false, "only print changes")
 
 	var cmdSubsUp = &cobra.Command{
 		Use:   "export",
-		Short: "Exports subscriptions from local DB to tumblr blog (follows all blogs)",
+		Short: "Makes tumblr subscriptions match local ones (follows blogs, unfollows them with --unfollow)",
 		Run: func(cmd *cobra.Command, args []string) {
+			syncer.DryRun = dryRun
+			syncer.Unfollow = unfollow
 			syncer.SubsUp()
 		},
 	}
+	cmdSubsUp.Flags().BoolVar(&dryRun, "dry-run", false, "only print changes")
+	cmdSubsUp.Flags().BoolVar(&unfollow, "unfollow", false, "also unfollow blogs which are not subscribed locally")
 
 	var rootCmd = &cobra.Command{Use: "bellboy"}
-	rootCmd.AddCommand(cmdSync, cmdSubsDown, cmdSubsUp)
+	// the flag is read by profileFlag, it is declared to be accepted and documented
+	rootCmd.PersistentFlags().String("profile", "", "name of the profile from \"profiles\" config section")
+	rootCmd.AddCommand(cmdSync, cmdSubsDown, cmdSubsUp, subsCommand(syncer), mirrorCommand(syncer), harvestCommand(syncer), dashboardCommand(syncer), dupesCommand(syncer.Repo), photosCommand(syncer.Repo), thumbsCommand(syncer.Repo), mediaCommand(syncer.Repo), fsckCommand(syncer.Repo), tagsCommand(syncer.Repo, syncer.Tags), queueCommand(syncer.Repo), rulesCommand(syncer.Repo, rules), collectionsCommand(syncer.Repo), trashCommand(syncer.Repo), keysCommand(db, store, encryption), loginCommand(tumblrKey, params, secrets), credentialsCommand(secrets, tumblrKey))
 	rootCmd.Execute()
 }
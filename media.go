@@ -0,0 +1,26 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/altmer/bellboy/media"
+	"github.com/spf13/cobra"
+)
+
+func mediaCommand(repo media.Repository) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "media",
+		Short: "Maintenance of downloaded media files",
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "fix-extensions",
+		Short: "Detects real types of media files and renames files with wrong extensions",
+		Run: func(cmd *cobra.Command, args []string) {
+			fixed, err := repo.FixExtensions()
+			panicOnError(err)
+			fmt.Printf("%d media files renamed\n", fixed)
+		},
+	})
+	return cmd
+}
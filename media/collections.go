@@ -0,0 +1,265 @@
+package media
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Collection is a named ordered set of curated posts from any source
+type Collection struct {
+	ID        uint
+	CreatedAt time.Time `db:"created_at"`
+	UpdatedAt time.Time `db:"updated_at"`
+
+	Name         string
+	Description  string
+	CoverPhotoID *uint `db:"cover_photo_id"` // NULL when collection has no cover
+}
+
+// CollectionCount is a collection with number of its posts
+type CollectionCount struct {
+	Collection
+	Posts int
+}
+
+// AddCollection creates collection, names are unique
+func (r mediaRepo) AddCollection(collection *Collection) error {
+	if _, err := r.GetCollection(collection.Name); err == nil {
+		return fmt.Errorf("collection [%s] already exists", collection.Name)
+	}
+	err := r.checkCoverPhoto(collection)
+	if err != nil {
+		return err
+	}
+	collection.CreatedAt = time.Now()
+	collection.UpdatedAt = time.Now()
+	res, err := r.DB.NamedExec(
+		`INSERT INTO collections (created_at, updated_at, name, description, cover_photo_id)
+		VALUES (:created_at, :updated_at, :name, :description, :cover_photo_id)`,
+		collection,
+	)
+	if err != nil {
+		return err
+	}
+	collectionID, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	collection.ID = uint(collectionID)
+	return nil
+}
+
+// GetCollection finds collection by name
+func (r mediaRepo) GetCollection(name string) (Collection, error) {
+	var collection Collection
+	err := r.DB.Get(&collection, "SELECT * FROM collections WHERE name = ?", name)
+	if err != nil {
+		return collection, fmt.Errorf("collection [%s] not found", name)
+	}
+	return collection, nil
+}
+
+// ListCollections returns all collections with their posts counts ordered by name
+func (r mediaRepo) ListCollections() ([]CollectionCount, error) {
+	var collections []CollectionCount
+	err := r.DB.Select(
+		&collections,
+		`SELECT collections.*, count(posts.id) AS posts
+		FROM collections
+		LEFT JOIN collections_posts ON collections_posts.collection_id = collections.id
+		LEFT JOIN posts ON posts.id = collections_posts.post_id AND posts.deleted_at IS NULL
+		GROUP BY collections.id ORDER BY collections.name`,
+	)
+	return collections, err
+}
+
+// UpdateCollection saves name, description and cover of the collection
+func (r mediaRepo) UpdateCollection(collection *Collection) error {
+	if existing, err := r.GetCollection(collection.Name); err == nil && existing.ID != collection.ID {
+		return fmt.Errorf("collection [%s] already exists", collection.Name)
+	}
+	err := r.checkCoverPhoto(collection)
+	if err != nil {
+		return err
+	}
+	collection.UpdatedAt = time.Now()
+	res, err := r.DB.NamedExec(
+		`UPDATE collections SET updated_at = :updated_at, name = :name, description = :description,
+		cover_photo_id = :cover_photo_id WHERE id = :id`,
+		collection,
+	)
+	if err != nil {
+		return err
+	}
+	if updated, _ := res.RowsAffected(); updated == 0 {
+		return fmt.Errorf("collection [%d] not found", collection.ID)
+	}
+	return nil
+}
+
+// DeleteCollection removes collection, its posts are kept
+func (r mediaRepo) DeleteCollection(name string) error {
+	collection, err := r.GetCollection(name)
+	if err != nil {
+		return err
+	}
+	return r.inTransaction(func(tx *sqlx.Tx) error {
+		_, err := tx.Exec("DELETE FROM collections_posts WHERE collection_id = ?", collection.ID)
+		if err == nil {
+			_, err = tx.Exec("DELETE FROM collections WHERE id = ?", collection.ID)
+		}
+		return err
+	})
+}
+
+// AddPostToCollection puts post to the end of the collection
+func (r mediaRepo) AddPostToCollection(name string, postID uint) error {
+	collection, err := r.GetCollection(name)
+	if err != nil {
+		return err
+	}
+	if _, err := r.GetPost(postID); err != nil {
+		return err
+	}
+	if _, err := r.postPosition(collection, postID); err == nil {
+		return fmt.Errorf("post [%d] is already in collection [%s]", postID, name)
+	}
+	_, err = r.DB.Exec(
+		`INSERT INTO collections_posts (collection_id, post_id, position)
+		SELECT ?, ?, coalesce(max(position), 0) + 1 FROM collections_posts WHERE collection_id = ?`,
+		collection.ID, postID, collection.ID,
+	)
+	return err
+}
+
+// RemovePostFromCollection takes post out of the collection, posts after it move up
+func (r mediaRepo) RemovePostFromCollection(name string, postID uint) error {
+	collection, err := r.GetCollection(name)
+	if err != nil {
+		return err
+	}
+	position, err := r.postPosition(collection, postID)
+	if err != nil {
+		return err
+	}
+	return r.inTransaction(func(tx *sqlx.Tx) error {
+		_, err := tx.Exec("DELETE FROM collections_posts WHERE collection_id = ? AND post_id = ?", collection.ID, postID)
+		if err == nil {
+			_, err = tx.Exec(
+				"UPDATE collections_posts SET position = position - 1 WHERE collection_id = ? AND position > ?",
+				collection.ID, position,
+			)
+		}
+		return err
+	})
+}
+
+// MovePostInCollection puts post to the position (starting from 1) shifting posts between
+// old and new positions, positions out of range move post to the start or to the end
+func (r mediaRepo) MovePostInCollection(name string, postID uint, position int) error {
+	collection, err := r.GetCollection(name)
+	if err != nil {
+		return err
+	}
+	current, err := r.postPosition(collection, postID)
+	if err != nil {
+		return err
+	}
+	var count int
+	err = r.DB.Get(&count, "SELECT count(*) FROM collections_posts WHERE collection_id = ?", collection.ID)
+	if err != nil {
+		return err
+	}
+	if position < 1 {
+		position = 1
+	}
+	if position > count {
+		position = count
+	}
+	return r.inTransaction(func(tx *sqlx.Tx) error {
+		var err error
+		if position < current {
+			_, err = tx.Exec(
+				`UPDATE collections_posts SET position = position + 1
+				WHERE collection_id = ? AND position >= ? AND position < ?`,
+				collection.ID, position, current,
+			)
+		} else {
+			_, err = tx.Exec(
+				`UPDATE collections_posts SET position = position - 1
+				WHERE collection_id = ? AND position > ? AND position <= ?`,
+				collection.ID, current, position,
+			)
+		}
+		if err == nil {
+			_, err = tx.Exec(
+				"UPDATE collections_posts SET position = ? WHERE collection_id = ? AND post_id = ?",
+				position, collection.ID, postID,
+			)
+		}
+		return err
+	})
+}
+
+// ListCollectionPosts returns posts of the collection in their order
+func (r mediaRepo) ListCollectionPosts(name string) ([]Post, error) {
+	collection, err := r.GetCollection(name)
+	if err != nil {
+		return nil, err
+	}
+	var posts []Post
+	err = r.DB.Select(
+		&posts,
+		`SELECT posts.* FROM posts
+		INNER JOIN collections_posts ON collections_posts.post_id = posts.id
+		WHERE collections_posts.collection_id = ? AND posts.deleted_at IS NULL
+		ORDER BY collections_posts.position`,
+		collection.ID,
+	)
+	return posts, err
+}
+
+func (r mediaRepo) postPosition(collection Collection, postID uint) (int, error) {
+	var position int
+	err := r.DB.Get(
+		&position, "SELECT position FROM collections_posts WHERE collection_id = ? AND post_id = ?",
+		collection.ID, postID,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("post [%d] is not in collection [%s]", postID, collection.Name)
+	}
+	return position, nil
+}
+
+func (r mediaRepo) checkCoverPhoto(collection *Collection) error {
+	if collection.CoverPhotoID == nil {
+		return nil
+	}
+	var count int
+	r.DB.Get(&count, "SELECT count(*) FROM photos WHERE id = ?", *collection.CoverPhotoID)
+	if count == 0 {
+		return fmt.Errorf("photo [%d] not found", *collection.CoverPhotoID)
+	}
+	return nil
+}
+
+// CollectionsSchema represents schema for "collections" table
+var CollectionsSchema = `CREATE TABLE "collections" (
+	"id" integer PRIMARY KEY AUTOINCREMENT,
+	"created_at" datetime,
+	"updated_at" datetime,
+	"name" varchar(255) UNIQUE,
+	"description" text DEFAULT '',
+	"cover_photo_id" integer
+)`
+
+// CollectionsPostsSchema represents schema for "collections_posts" table
+// that keeps posts of collections in order
+var CollectionsPostsSchema = `CREATE TABLE "collections_posts" (
+	"collection_id" integer,
+	"post_id" integer,
+	"position" integer,
+	PRIMARY KEY ("collection_id","post_id")
+)`
@@ -0,0 +1,211 @@
+package media
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func collectionFixture(t *testing.T, postsCount int) []uint {
+	var ids []uint
+	for i := 1; i <= postsCount; i++ {
+		post := Post{Status: "added", Source: "tumblr", Type: "link", Category: "blog", ExternalID: strconv.Itoa(i)}
+		checkErrors(t, nil, repo.AddPost(&post))
+		ids = append(ids, post.ID)
+	}
+	return ids
+}
+
+func collectionPostIDs(t *testing.T, name string) []uint {
+	posts, err := repo.ListCollectionPosts(name)
+	checkErrors(t, nil, err)
+	ids := []uint{}
+	for _, post := range posts {
+		ids = append(ids, post.ID)
+	}
+	return ids
+}
+
+func TestCollectionsCRUD(t *testing.T) {
+	teardown := setup()
+	defer teardown()
+
+	DB.Exec("INSERT INTO photos (id, post_id, external_url) VALUES (7, 1, 'http://example.com/cover.jpg')")
+	cover := uint(7)
+	missingCover := uint(8)
+
+	collection := Collection{Name: "cats", Description: "Best cats", CoverPhotoID: &cover}
+	checkErrors(t, nil, repo.AddCollection(&collection))
+	assert.NotZero(t, collection.ID)
+	checkErrors(t, errors.New("collection [cats] already exists"), repo.AddCollection(&Collection{Name: "cats"}))
+	checkErrors(t, errors.New("photo [8] not found"), repo.AddCollection(&Collection{Name: "dogs", CoverPhotoID: &missingCover}))
+	checkErrors(t, nil, repo.AddCollection(&Collection{Name: "dogs"}))
+
+	stored, err := repo.GetCollection("cats")
+	checkErrors(t, nil, err)
+	assert.Equal(t, "Best cats", stored.Description)
+	assert.Equal(t, cover, *stored.CoverPhotoID)
+	_, err = repo.GetCollection("birds")
+	checkErrors(t, errors.New("collection [birds] not found"), err)
+
+	stored.Name = "kittens"
+	stored.CoverPhotoID = nil
+	checkErrors(t, nil, repo.UpdateCollection(&stored))
+	stored.Name = "dogs"
+	checkErrors(t, errors.New("collection [dogs] already exists"), repo.UpdateCollection(&stored))
+
+	collections, err := repo.ListCollections()
+	checkErrors(t, nil, err)
+	if assert.Len(t, collections, 2) {
+		assert.Equal(t, "dogs", collections[0].Name)
+		assert.Equal(t, "kittens", collections[1].Name)
+		assert.Nil(t, collections[1].CoverPhotoID)
+	}
+
+	checkErrors(t, nil, repo.DeleteCollection("dogs"))
+	checkErrors(t, errors.New("collection [dogs] not found"), repo.DeleteCollection("dogs"))
+}
+
+func TestCollectionPosts(t *testing.T) {
+	teardown := setup()
+	defer teardown()
+
+	ids := collectionFixture(t, 4)
+	checkErrors(t, nil, repo.AddCollection(&Collection{Name: "cats"}))
+	for _, id := range ids {
+		checkErrors(t, nil, repo.AddPostToCollection("cats", id))
+	}
+	checkErrors(t, errors.New("post [1] is already in collection [cats]"), repo.AddPostToCollection("cats", ids[0]))
+	checkErrors(t, errors.New("post [42] not found"), repo.AddPostToCollection("cats", 42))
+	checkErrors(t, errors.New("collection [dogs] not found"), repo.AddPostToCollection("dogs", ids[0]))
+	assert.Equal(t, ids, collectionPostIDs(t, "cats"))
+
+	checkErrors(t, nil, repo.MovePostInCollection("cats", ids[3], 1))
+	assert.Equal(t, []uint{ids[3], ids[0], ids[1], ids[2]}, collectionPostIDs(t, "cats"))
+	checkErrors(t, nil, repo.MovePostInCollection("cats", ids[3], 3))
+	assert.Equal(t, []uint{ids[0], ids[1], ids[3], ids[2]}, collectionPostIDs(t, "cats"))
+	checkErrors(t, nil, repo.MovePostInCollection("cats", ids[0], 100))
+	assert.Equal(t, []uint{ids[1], ids[3], ids[2], ids[0]}, collectionPostIDs(t, "cats"))
+
+	checkErrors(t, nil, repo.RemovePostFromCollection("cats", ids[3]))
+	assert.Equal(t, []uint{ids[1], ids[2], ids[0]}, collectionPostIDs(t, "cats"))
+	checkErrors(t, errors.New("post [4] is not in collection [cats]"), repo.RemovePostFromCollection("cats", ids[3]))
+
+	// positions stay consecutive after removal
+	checkErrors(t, nil, repo.AddPostToCollection("cats", ids[3]))
+	checkErrors(t, nil, repo.MovePostInCollection("cats", ids[3], 0))
+	assert.Equal(t, []uint{ids[3], ids[1], ids[2], ids[0]}, collectionPostIDs(t, "cats"))
+
+	checkErrors(t, nil, repo.DeleteCollection("cats"))
+	var count int
+	DB.Get(&count, "SELECT count(*) FROM collections_posts")
+	assert.Equal(t, 0, count)
+	DB.Get(&count, "SELECT count(*) FROM posts")
+	assert.Equal(t, 4, count)
+}
+
+func TestExportCollections(t *testing.T) {
+	teardown := setup()
+	defer teardown()
+
+	folder, err := ioutil.TempDir("", "bellboy_export")
+	checkErrors(t, nil, err)
+	defer os.RemoveAll(folder)
+
+	ids := collectionFixture(t, 2)
+	DB.Exec("INSERT INTO links (post_id, url) VALUES (?, 'http://example.com/<cats>')", ids[0])
+	DB.Exec("INSERT INTO photos (id, post_id, external_url, mime_type) VALUES (3, ?, 'http://example.com/cat', 'image/png')", ids[1])
+	checkErrors(t, nil, repo.AddTagToPost(&Post{ID: ids[0]}, "cats"))
+	cover := uint(3)
+	checkErrors(t, nil, repo.AddCollection(&Collection{Name: "cats", Description: "Best <cats>", CoverPhotoID: &cover}))
+	checkErrors(t, nil, repo.AddCollection(&Collection{Name: "empty"}))
+	checkErrors(t, nil, repo.AddPostToCollection("cats", ids[1]))
+	checkErrors(t, nil, repo.AddPostToCollection("cats", ids[0]))
+
+	exported, err := repo.ExportCollections(folder, "json")
+	checkErrors(t, nil, err)
+	assert.Equal(t, 2, exported)
+
+	var index []ExportedCollection
+	data, _ := ioutil.ReadFile(filepath.Join(folder, "index.json"))
+	checkErrors(t, nil, json.Unmarshal(data, &index))
+	// photo file is not stored, its URL is exported
+	photoPath := "http://example.com/cat"
+	assert.Equal(t, []ExportedCollection{
+		{Name: "cats", Description: "Best <cats>", Cover: photoPath, Page: "collection_1.json"},
+		{Name: "empty", Page: "collection_2.json"},
+	}, index)
+
+	var page ExportedCollection
+	data, _ = ioutil.ReadFile(filepath.Join(folder, "collection_1.json"))
+	checkErrors(t, nil, json.Unmarshal(data, &page))
+	if assert.Len(t, page.Posts, 2) {
+		assert.Equal(t, ids[1], page.Posts[0].ID)
+		assert.Equal(t, []string{photoPath}, page.Posts[0].Photos)
+		assert.Equal(t, ids[0], page.Posts[1].ID)
+		assert.Equal(t, []string{"cats"}, page.Posts[1].Tags)
+		assert.Equal(t, []string{"http://example.com/<cats>"}, page.Posts[1].Links)
+	}
+
+	_, err = repo.ExportCollections(folder, "html")
+	checkErrors(t, nil, err)
+	html, _ := ioutil.ReadFile(filepath.Join(folder, "index.html"))
+	assert.Contains(t, string(html), `<a href="collection_1.html">cats</a>`)
+	assert.Contains(t, string(html), "Best &lt;cats&gt;")
+	html, _ = ioutil.ReadFile(filepath.Join(folder, "collection_1.html"))
+	assert.Contains(t, string(html), "#cats")
+
+	_, err = repo.ExportCollections(folder, "pdf")
+	checkErrors(t, errors.New("unknown export format [pdf]"), err)
+}
+
+func TestExportCollectionsCopiesDecryptedMedia(t *testing.T) {
+	teardown := setup()
+	defer teardown()
+
+	folder, err := ioutil.TempDir("", "bellboy_export")
+	checkErrors(t, nil, err)
+	defer os.RemoveAll(folder)
+	store, storeFolder, removeStore := newTestEncryptedStore(t, &Keyring{Current: testKey(1)})
+	defer removeStore()
+	encryptedRepo := NewEncryptedRepository(DB, store.Store, Encryption{Keys: store.Keys})
+
+	ids := collectionFixture(t, 1)
+	DB.Exec("INSERT INTO photos (id, post_id, external_url, mime_type) VALUES (3, ?, 'http://example.com/cat', 'image/gif')", ids[0])
+	photo := Photo{ID: 3, MimeType: "image/gif", ExternalURL: "http://example.com/cat"}
+	checkErrors(t, nil, store.Put(photo.FileName(), bytes.NewReader(gifContents)))
+	checkErrors(t, nil, store.Put(photo.ThumbnailFileName(256), bytes.NewReader([]byte("thumbnail"))))
+	cover := uint(3)
+	checkErrors(t, nil, encryptedRepo.AddCollection(&Collection{Name: "cats", CoverPhotoID: &cover}))
+	checkErrors(t, nil, encryptedRepo.AddPostToCollection("cats", ids[0]))
+
+	_, err = encryptedRepo.ExportCollections(folder, "json")
+	checkErrors(t, nil, err)
+
+	var page ExportedCollection
+	data, _ := ioutil.ReadFile(filepath.Join(folder, "collection_1.json"))
+	checkErrors(t, nil, json.Unmarshal(data, &page))
+	assert.Equal(t, "media/photo_3.gif", page.Cover)
+	if assert.Len(t, page.Posts, 1) {
+		assert.Equal(t, []string{"media/photo_3.gif"}, page.Posts[0].Photos)
+		assert.Equal(t, []string{"media/" + photo.ThumbnailFileName(256)}, page.Posts[0].Thumbnails)
+	}
+	contents, _ := ioutil.ReadFile(filepath.Join(folder, "media", "photo_3.gif"))
+	assert.Equal(t, gifContents, contents)
+	contents, _ = ioutil.ReadFile(filepath.Join(folder, "media", photo.ThumbnailFileName(256)))
+	assert.Equal(t, "thumbnail", string(contents))
+	sealed, _ := ioutil.ReadFile(filepath.Join(storeFolder, "photo_3.gif"))
+	assert.NotEqual(t, gifContents, sealed)
+
+	_, err = encryptedRepo.ExportCollections(folder, "html")
+	checkErrors(t, nil, err)
+	html, _ := ioutil.ReadFile(filepath.Join(folder, "collection_1.html"))
+	assert.Contains(t, string(html), `<img src="media/photo_3.gif" alt="">`)
+}
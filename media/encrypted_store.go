@@ -0,0 +1,261 @@
+package media
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// Encrypted files start with a header: magic, ID of the key and random nonce prefix.
+// Contents follow in chunks sealed with XChaCha20-Poly1305, chunk nonce is the prefix,
+// chunk counter and a flag of the last chunk, so chunks can't be reordered or dropped.
+const (
+	fileMagic         = "BBE1"
+	noncePrefixLength = 15
+	chunkSize         = 64 * 1024
+	fileHeaderLength  = len(fileMagic) + keyIDLength + noncePrefixLength
+	lastChunkFlag     = 1
+	resealSuffix      = ".reseal"
+)
+
+// EncryptedStore seals files of underlying store, files written before
+// encryption was turned on are read as is
+type EncryptedStore struct {
+	Store
+	Keys *Keyring
+}
+
+func (s *EncryptedStore) Put(name string, contents io.Reader) error {
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(encryptStream(writer, contents, s.Keys.Current))
+	}()
+	err := s.Store.Put(name, reader)
+	reader.CloseWithError(err)
+	return err
+}
+
+func (s *EncryptedStore) Open(name string) (io.ReadCloser, error) {
+	file, err := s.Store.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	stream := bufio.NewReaderSize(file, chunkSize+chacha20poly1305.Overhead)
+	head, _ := stream.Peek(fileHeaderLength)
+	if !bytes.HasPrefix(head, []byte(fileMagic)) {
+		return readCloser{stream, file}, nil
+	}
+	decrypted, err := newDecryptingReader(stream, s.Keys)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("can't decrypt [%s]: %s", name, err)
+	}
+	return readCloser{decrypted, file}, nil
+}
+
+// Stat returns size of decrypted contents, it is calculated from size of sealed file
+func (s *EncryptedStore) Stat(name string) (FileInfo, error) {
+	info, err := s.Store.Stat(name)
+	if err != nil {
+		return info, err
+	}
+	if s.isEncrypted(name) {
+		info.Size = plainSize(info.Size)
+	}
+	return info, nil
+}
+
+// List returns sizes of sealed files, they are not opened to check headers
+func (s *EncryptedStore) List() ([]FileInfo, error) {
+	return s.Store.List()
+}
+
+// isEncrypted checks file header
+func (s *EncryptedStore) isEncrypted(name string) bool {
+	file, err := s.Store.Open(name)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+	magic := make([]byte, len(fileMagic))
+	_, err = io.ReadFull(file, magic)
+	return err == nil && string(magic) == fileMagic
+}
+
+// keyID returns ID of the key the file is sealed with, nil for plain files
+func (s *EncryptedStore) keyID(name string) ([]byte, error) {
+	file, err := s.Store.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	header := make([]byte, fileHeaderLength)
+	_, err = io.ReadFull(file, header)
+	if err != nil || !bytes.HasPrefix(header, []byte(fileMagic)) {
+		return nil, nil
+	}
+	return header[len(fileMagic) : len(fileMagic)+keyIDLength], nil
+}
+
+func plainSize(sealedSize int64) int64 {
+	size := sealedSize - int64(fileHeaderLength)
+	chunks := (size + chunkSize + chacha20poly1305.Overhead - 1) / (chunkSize + chacha20poly1305.Overhead)
+	if chunks == 0 {
+		return 0
+	}
+	return size - chunks*chacha20poly1305.Overhead
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+func chunkNonce(prefix []byte, counter uint64, last bool) []byte {
+	nonce := make([]byte, noncePrefixLength+9)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint64(nonce[noncePrefixLength:], counter)
+	if last {
+		nonce[noncePrefixLength+8] = lastChunkFlag
+	}
+	return nonce
+}
+
+// encryptStream writes header and sealed chunks, empty contents produce one empty last chunk
+func encryptStream(w io.Writer, contents io.Reader, key *Key) error {
+	header := make([]byte, fileHeaderLength)
+	copy(header, fileMagic)
+	copy(header[len(fileMagic):], key.id)
+	_, err := rand.Read(header[len(fileMagic)+keyIDLength:])
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(header)
+	if err != nil {
+		return err
+	}
+
+	aead := key.aead(filesKeyPurpose)
+	prefix := header[len(fileMagic)+keyIDLength:]
+	// one chunk is read ahead to know which one is the last
+	current := make([]byte, chunkSize)
+	next := make([]byte, chunkSize)
+	n, err := io.ReadFull(contents, current)
+	for counter := uint64(0); ; counter++ {
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		last := err != nil
+		var m int
+		if !last {
+			m, err = io.ReadFull(contents, next)
+			if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+				return err
+			}
+			last = m == 0
+		}
+		_, werr := w.Write(aead.Seal(nil, chunkNonce(prefix, counter, last), current[:n], header))
+		if werr != nil {
+			return werr
+		}
+		if last {
+			return nil
+		}
+		current, next = next, current
+		n = m
+	}
+}
+
+// decryptingReader opens sealed chunks one by one
+type decryptingReader struct {
+	source  *bufio.Reader
+	aead    cipher.AEAD
+	header  []byte
+	counter uint64
+	chunk   []byte
+	sealed  []byte
+	done    bool
+}
+
+func newDecryptingReader(source *bufio.Reader, keys *Keyring) (*decryptingReader, error) {
+	header := make([]byte, fileHeaderLength)
+	_, err := io.ReadFull(source, header)
+	if err != nil {
+		return nil, fmt.Errorf("truncated header")
+	}
+	key, err := keys.find(header[len(fileMagic) : len(fileMagic)+keyIDLength])
+	if err != nil {
+		return nil, err
+	}
+	return &decryptingReader{
+		source: source,
+		aead:   key.aead(filesKeyPurpose),
+		header: header,
+		sealed: make([]byte, chunkSize+chacha20poly1305.Overhead),
+	}, nil
+}
+
+func (r *decryptingReader) Read(p []byte) (int, error) {
+	for len(r.chunk) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		err := r.nextChunk()
+		if err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, r.chunk)
+	r.chunk = r.chunk[n:]
+	return n, nil
+}
+
+func (r *decryptingReader) nextChunk() error {
+	n, err := io.ReadFull(r.source, r.sealed)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return fmt.Errorf("encrypted file is truncated")
+	}
+	last := err == io.ErrUnexpectedEOF
+	if !last {
+		_, err = r.source.Peek(1)
+		last = err == io.EOF
+	}
+	prefix := r.header[len(fileMagic)+keyIDLength:]
+	chunk, err := r.aead.Open(r.sealed[:0], chunkNonce(prefix, r.counter, last), r.sealed[:n], r.header)
+	if err != nil {
+		return fmt.Errorf("encrypted file is corrupted or truncated")
+	}
+	r.chunk = chunk
+	r.counter++
+	r.done = last
+	return nil
+}
+
+// reseal decrypts the file and writes it again with current key. Contents are sealed
+// into a sibling object which replaces the file, so plain contents stay in memory
+// and the file is kept when anything fails.
+func (s *EncryptedStore) reseal(name string) error {
+	file, err := s.Open(name)
+	if err != nil {
+		return err
+	}
+	temp := name + resealSuffix
+	err = s.Put(temp, file)
+	file.Close()
+	if err != nil {
+		s.Store.Delete(temp)
+		return fmt.Errorf("can't reseal [%s]: %s", name, err)
+	}
+	err = moveFile(s.Store, temp, name)
+	if err != nil {
+		s.Store.Delete(temp)
+		return fmt.Errorf("can't reseal [%s]: %s", name, err)
+	}
+	return nil
+}
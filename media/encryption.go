@@ -0,0 +1,337 @@
+package media
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/spf13/viper"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	keyIDLength      = 8
+	minSaltLength    = 16
+	keyFileLength    = 32
+	columnPrefix     = "enc:v1:"
+	filesKeyPurpose  = "bellboy media files"
+	columnKeyPurpose = "bellboy columns"
+)
+
+// Argon2id parameters for passphrase keys: 3 passes over 64 MiB
+const (
+	argonTime    = 3
+	argonMemory  = 64 * 1024
+	argonThreads = 4
+)
+
+// Key is a master key, separate keys for files and columns are derived from it
+type Key struct {
+	id     []byte
+	secret []byte
+}
+
+func newKey(secret []byte) *Key {
+	return &Key{id: deriveBytes(secret, "bellboy key id", keyIDLength), secret: secret}
+}
+
+// KeyFromPassphrase derives key from passphrase with Argon2id
+func KeyFromPassphrase(passphrase string, salt []byte) (*Key, error) {
+	if passphrase == "" {
+		return nil, fmt.Errorf("passphrase should not be empty")
+	}
+	if len(salt) < minSaltLength {
+		return nil, fmt.Errorf("salt should be at least %d bytes", minSaltLength)
+	}
+	return newKey(argon2.IDKey([]byte(passphrase), salt, argonTime, argonMemory, argonThreads, chacha20poly1305.KeySize)), nil
+}
+
+// KeyFromFile derives key from contents of the key file
+func KeyFromFile(path string) (*Key, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < keyFileLength {
+		return nil, fmt.Errorf("key file [%s] should contain at least %d bytes", path, keyFileLength)
+	}
+	return newKey(deriveBytes(data, "bellboy key file", chacha20poly1305.KeySize)), nil
+}
+
+// GenerateKeyFile writes new random key file readable only by the owner, existing files are kept
+func GenerateKeyFile(path string) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = io.CopyN(file, rand.Reader, keyFileLength)
+	return err
+}
+
+// GenerateSalt returns random hex encoded salt for passphrase keys
+func GenerateSalt() string {
+	salt := make([]byte, minSaltLength)
+	rand.Read(salt)
+	return hex.EncodeToString(salt)
+}
+
+// ID returns fingerprint of the key stored along with encrypted data
+func (k *Key) ID() string {
+	return hex.EncodeToString(k.id)
+}
+
+func (k *Key) aead(purpose string) cipher.AEAD {
+	aead, err := chacha20poly1305.NewX(deriveBytes(k.secret, purpose, chacha20poly1305.KeySize))
+	if err != nil {
+		// derived key always has valid size
+		panic(err)
+	}
+	return aead
+}
+
+// Seal encrypts data with key derived for the purpose, random nonce is prepended to the result
+func (k *Key) Seal(purpose string, data []byte) ([]byte, error) {
+	aead := k.aead(purpose)
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(data)+aead.Overhead())
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, data, k.id), nil
+}
+
+// Open decrypts data sealed by Seal with the same key and purpose
+func (k *Key) Open(purpose string, sealed []byte) ([]byte, error) {
+	aead := k.aead(purpose)
+	if len(sealed) < aead.NonceSize() {
+		return nil, fmt.Errorf("malformed encrypted data")
+	}
+	plain, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], k.id)
+	if err != nil {
+		return nil, fmt.Errorf("encrypted data is corrupted or key is wrong")
+	}
+	return plain, nil
+}
+
+func deriveBytes(secret []byte, info string, length int) []byte {
+	derived := make([]byte, length)
+	io.ReadFull(hkdf.New(sha256.New, secret, nil, []byte(info)), derived)
+	return derived
+}
+
+// Keyring encrypts data with current key and decrypts data written with any of its keys
+type Keyring struct {
+	Current  *Key
+	Previous []*Key // keys used before rotation
+}
+
+func (k *Keyring) find(id []byte) (*Key, error) {
+	for _, key := range append([]*Key{k.Current}, k.Previous...) {
+		if key != nil && bytes.Equal(key.id, id) {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("data is encrypted with unknown key [%x]", id)
+}
+
+// Encryption configures at-rest encryption of the repository
+type Encryption struct {
+	Keys    *Keyring
+	Columns bool // encrypt texts bodies and photos captions
+}
+
+// LoadEncryption reads "encryption" config section, returns nil when encryption is off.
+// Key is read from key file or derived from passphrase in environment variable.
+func LoadEncryption() (*Encryption, error) {
+	key, err := LoadKey(
+		viper.GetString("encryption.mode"), viper.GetString("encryption.keyfile"),
+		viper.GetString("encryption.passphrase_env"), viper.GetString("encryption.salt"),
+	)
+	if err != nil || key == nil {
+		return nil, err
+	}
+	return &Encryption{Keys: &Keyring{Current: key}, Columns: viper.GetBool("encryption.columns")}, nil
+}
+
+// LoadKey creates key in one of the modes: "keyfile" or "passphrase", nil is returned for empty mode
+func LoadKey(mode, keyFile, passphraseEnv, salt string) (*Key, error) {
+	switch mode {
+	case "":
+		return nil, nil
+	case "keyfile":
+		return KeyFromFile(keyFile)
+	case "passphrase":
+		if passphraseEnv == "" {
+			passphraseEnv = "BELLBOY_PASSPHRASE"
+		}
+		saltBytes, err := hex.DecodeString(salt)
+		if err != nil {
+			return nil, fmt.Errorf("salt should be hex encoded: [%s]", err)
+		}
+		return KeyFromPassphrase(os.Getenv(passphraseEnv), saltBytes)
+	default:
+		return nil, fmt.Errorf("unknown encryption mode [%s]", mode)
+	}
+}
+
+// encryptValue seals column value with current key, empty values are kept as is
+func (k *Keyring) encryptValue(value string) (string, error) {
+	if value == "" {
+		return value, nil
+	}
+	sealed, err := k.Current.Seal(columnKeyPurpose, []byte(value))
+	if err != nil {
+		return "", err
+	}
+	return columnPrefix + k.Current.ID() + ":" + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// decryptValue opens sealed column value, plain values are returned as is
+func (k *Keyring) decryptValue(value string) (string, error) {
+	if !strings.HasPrefix(value, columnPrefix) {
+		return value, nil
+	}
+	parts := strings.SplitN(strings.TrimPrefix(value, columnPrefix), ":", 2)
+	if len(parts) != 2 {
+		return "", fmt.Errorf("malformed encrypted value")
+	}
+	id, err := hex.DecodeString(parts[0])
+	if err != nil {
+		return "", fmt.Errorf("malformed encrypted value: [%s]", err)
+	}
+	key, err := k.find(id)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", fmt.Errorf("malformed encrypted value")
+	}
+	plain, err := key.Open(columnKeyPurpose, sealed)
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+// encryptedWithCurrent tells whether value is sealed with current key already
+func (k *Keyring) encryptedWithCurrent(value string) bool {
+	return strings.HasPrefix(value, columnPrefix+k.Current.ID()+":")
+}
+
+// sealColumn encrypts value of sensitive column when column encryption is on
+func (r mediaRepo) sealColumn(value string) (string, error) {
+	if r.keys == nil || !r.encryptColumns {
+		return value, nil
+	}
+	return r.keys.encryptValue(value)
+}
+
+// openColumn decrypts value of sensitive column, values are kept as is without keys
+func (r mediaRepo) openColumn(value string) (string, error) {
+	if r.keys == nil {
+		return value, nil
+	}
+	return r.keys.decryptValue(value)
+}
+
+// openCaptions decrypts captions of selected photos
+func (r mediaRepo) openCaptions(photos []Photo) error {
+	for i := range photos {
+		caption, err := r.openColumn(photos[i].Caption)
+		if err != nil {
+			return err
+		}
+		photos[i].Caption = caption
+	}
+	return nil
+}
+
+// Reencrypt seals all stored files and sensitive column values with current key.
+// Data sealed with previous keys or not encrypted at all is rewritten, column values
+// are decrypted when column encryption is off. Returns names of rewritten files, also
+// when it fails partway, and number of rewritten values.
+func (r mediaRepo) Reencrypt() ([]string, int, error) {
+	store, ok := r.Store.(*EncryptedStore)
+	if !ok {
+		return nil, 0, fmt.Errorf("encryption is not configured")
+	}
+	files, err := store.List()
+	if err != nil {
+		return nil, 0, err
+	}
+	var resealed []string
+	for _, file := range files {
+		// other files in media folder are not ours to encrypt
+		if !isRepositoryFile(file.Name) {
+			continue
+		}
+		id, err := store.keyID(file.Name)
+		if err != nil {
+			return resealed, 0, err
+		}
+		if bytes.Equal(id, r.keys.Current.id) {
+			continue
+		}
+		err = store.reseal(file.Name)
+		if err != nil {
+			return resealed, 0, err
+		}
+		resealed = append(resealed, file.Name)
+	}
+
+	values := 0
+	for _, column := range [][2]string{{"texts", "body"}, {"photos", "caption"}} {
+		count, err := r.reencryptColumn(column[0], column[1])
+		values += count
+		if err != nil {
+			return resealed, values, err
+		}
+	}
+	return resealed, values, nil
+}
+
+// reencryptColumn rewrites values of the column which are not sealed the way configured now
+func (r mediaRepo) reencryptColumn(table, column string) (int, error) {
+	var rows []struct {
+		ID    uint
+		Value string
+	}
+	err := r.DB.Select(&rows, fmt.Sprintf(
+		`SELECT id, %[1]s AS value FROM %[2]s WHERE %[1]s LIKE ? OR (? AND %[1]s != '')`, column, table,
+	), columnPrefix+"%", r.encryptColumns)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, row := range rows {
+		if r.encryptColumns && r.keys.encryptedWithCurrent(row.Value) {
+			continue
+		}
+		plain, err := r.openColumn(row.Value)
+		if err != nil {
+			return count, fmt.Errorf("can't decrypt %s.%s of [%d]: %s", table, column, row.ID, err)
+		}
+		value, err := r.sealColumn(plain)
+		if err != nil {
+			return count, err
+		}
+		_, err = r.DB.Exec(fmt.Sprintf("UPDATE %s SET %s = ? WHERE id = ?", table, column), value, row.ID)
+		if err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
@@ -0,0 +1,340 @@
+package media
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	httpmock "gopkg.in/jarcoal/httpmock.v1"
+)
+
+func testKey(seed byte) *Key {
+	return newKey(bytes.Repeat([]byte{seed}, 32))
+}
+
+func randomBytes(size int) []byte {
+	data := make([]byte, size)
+	rand.Read(data)
+	return data
+}
+
+func newTestEncryptedStore(t *testing.T, keys *Keyring) (*EncryptedStore, string, func()) {
+	folder, err := ioutil.TempDir("", "bellboy_encrypted")
+	checkErrors(t, nil, err)
+	return &EncryptedStore{NewLocalStore(folder), keys}, folder, func() { os.RemoveAll(folder) }
+}
+
+func TestEncryptedStoreRoundTrip(t *testing.T) {
+	store, folder, teardown := newTestEncryptedStore(t, &Keyring{Current: testKey(1)})
+	defer teardown()
+
+	for _, size := range []int{0, 1, 1000, chunkSize - 1, chunkSize, chunkSize + 1, 3 * chunkSize, 3*chunkSize + 17} {
+		name := fmt.Sprintf("photo_%d.jpg", size)
+		contents := randomBytes(size)
+		checkErrors(t, nil, store.Put(name, bytes.NewReader(contents)))
+
+		sealed, _ := ioutil.ReadFile(filepath.Join(folder, name))
+		assert.Equal(t, fileMagic, string(sealed[:len(fileMagic)]))
+		// a few random bytes could occur in sealed data by chance
+		if size >= 16 {
+			assert.False(t, bytes.Contains(sealed, contents), "file of size [%d] is stored in plain", size)
+		}
+
+		file, err := store.Open(name)
+		checkErrors(t, nil, err)
+		decrypted, err := ioutil.ReadAll(file)
+		file.Close()
+		checkErrors(t, nil, err)
+		assert.True(t, bytes.Equal(contents, decrypted), "file of size [%d] is not decrypted", size)
+
+		info, err := store.Stat(name)
+		checkErrors(t, nil, err)
+		assert.Equal(t, int64(size), info.Size)
+	}
+}
+
+func TestEncryptedStoreDetectsTampering(t *testing.T) {
+	store, folder, teardown := newTestEncryptedStore(t, &Keyring{Current: testKey(1)})
+	defer teardown()
+
+	contents := randomBytes(2*chunkSize + 100)
+	checkErrors(t, nil, store.Put("video_1.mp4", bytes.NewReader(contents)))
+	sealed, _ := ioutil.ReadFile(filepath.Join(folder, "video_1.mp4"))
+	chunk := chunkSize + 16
+
+	readAll := func(data []byte) error {
+		ioutil.WriteFile(filepath.Join(folder, "video_1.mp4"), data, 0644)
+		file, err := store.Open("video_1.mp4")
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		_, err = ioutil.ReadAll(file)
+		return err
+	}
+
+	flipped := append([]byte{}, sealed...)
+	flipped[fileHeaderLength+10] ^= 1
+	assert.Error(t, readAll(flipped))
+
+	// dropping of the last chunks is detected
+	assert.Error(t, readAll(sealed[:fileHeaderLength+chunk]))
+	assert.Error(t, readAll(sealed[:fileHeaderLength+2*chunk]))
+
+	swapped := append([]byte{}, sealed[:fileHeaderLength]...)
+	swapped = append(swapped, sealed[fileHeaderLength+chunk:fileHeaderLength+2*chunk]...)
+	swapped = append(swapped, sealed[fileHeaderLength:fileHeaderLength+chunk]...)
+	swapped = append(swapped, sealed[fileHeaderLength+2*chunk:]...)
+	assert.Error(t, readAll(swapped))
+
+	assert.Nil(t, readAll(sealed))
+
+	other := &EncryptedStore{store.Store, &Keyring{Current: testKey(2)}}
+	_, err := other.Open("video_1.mp4")
+	assert.Equal(t,
+		fmt.Sprintf("can't decrypt [video_1.mp4]: data is encrypted with unknown key [%s]", testKey(1).ID()),
+		err.Error(),
+	)
+}
+
+func TestEncryptedStoreReseal(t *testing.T) {
+	store, folder, teardown := newTestEncryptedStore(t, &Keyring{Current: testKey(1)})
+	defer teardown()
+	contents := randomBytes(3*chunkSize + 17)
+	checkErrors(t, nil, store.Put("photo_1.jpg", bytes.NewReader(contents)))
+
+	// file sealed with unknown key is kept as is
+	sealed, _ := ioutil.ReadFile(filepath.Join(folder, "photo_1.jpg"))
+	unknownKey := &EncryptedStore{store.Store, &Keyring{Current: testKey(2)}}
+	err := unknownKey.reseal("photo_1.jpg")
+	checkErrors(t, fmt.Errorf("can't decrypt [photo_1.jpg]: data is encrypted with unknown key [%s]", testKey(1).ID()), err)
+	kept, _ := ioutil.ReadFile(filepath.Join(folder, "photo_1.jpg"))
+	assert.Equal(t, sealed, kept)
+
+	rotated := &EncryptedStore{store.Store, &Keyring{Current: testKey(2), Previous: []*Key{testKey(1)}}}
+	checkErrors(t, nil, rotated.reseal("photo_1.jpg"))
+	id, _ := rotated.keyID("photo_1.jpg")
+	assert.Equal(t, testKey(2).id, id)
+	file, err := rotated.Open("photo_1.jpg")
+	checkErrors(t, nil, err)
+	resealed, _ := ioutil.ReadAll(file)
+	file.Close()
+	assert.Equal(t, contents, resealed)
+
+	files, _ := store.List()
+	assert.Len(t, files, 1)
+}
+
+func TestEncryptedStoreReadsPlainFiles(t *testing.T) {
+	store, folder, teardown := newTestEncryptedStore(t, &Keyring{Current: testKey(1)})
+	defer teardown()
+
+	ioutil.WriteFile(filepath.Join(folder, "photo_1.gif"), gifContents, 0644)
+	file, err := store.Open("photo_1.gif")
+	checkErrors(t, nil, err)
+	contents, _ := ioutil.ReadAll(file)
+	file.Close()
+	assert.Equal(t, gifContents, contents)
+
+	info, err := store.Stat("photo_1.gif")
+	checkErrors(t, nil, err)
+	assert.Equal(t, int64(len(gifContents)), info.Size)
+}
+
+func TestColumnEncryption(t *testing.T) {
+	keys := &Keyring{Current: testKey(1)}
+
+	sealed, err := keys.encryptValue("secret caption")
+	checkErrors(t, nil, err)
+	assert.True(t, strings.HasPrefix(sealed, "enc:v1:"+testKey(1).ID()+":"))
+	assert.NotContains(t, sealed, "secret")
+	again, _ := keys.encryptValue("secret caption")
+	assert.NotEqual(t, sealed, again)
+
+	plain, err := keys.decryptValue(sealed)
+	checkErrors(t, nil, err)
+	assert.Equal(t, "secret caption", plain)
+
+	empty, _ := keys.encryptValue("")
+	assert.Equal(t, "", empty)
+	plain, _ = keys.decryptValue("plain caption")
+	assert.Equal(t, "plain caption", plain)
+
+	rotated := &Keyring{Current: testKey(2), Previous: []*Key{testKey(1)}}
+	plain, err = rotated.decryptValue(sealed)
+	checkErrors(t, nil, err)
+	assert.Equal(t, "secret caption", plain)
+
+	_, err = (&Keyring{Current: testKey(2)}).decryptValue(sealed)
+	checkErrors(t, fmt.Errorf("data is encrypted with unknown key [%s]", testKey(1).ID()), err)
+	_, err = keys.decryptValue(sealed[:len(sealed)-2] + "AA")
+	checkErrors(t, fmt.Errorf("encrypted data is corrupted or key is wrong"), err)
+}
+
+func TestKeySeal(t *testing.T) {
+	sealed, err := testKey(1).Seal("test", []byte("secret"))
+	checkErrors(t, nil, err)
+	assert.NotContains(t, string(sealed), "secret")
+
+	plain, err := testKey(1).Open("test", sealed)
+	checkErrors(t, nil, err)
+	assert.Equal(t, "secret", string(plain))
+
+	_, err = testKey(1).Open("other", sealed)
+	checkErrors(t, fmt.Errorf("encrypted data is corrupted or key is wrong"), err)
+	_, err = testKey(2).Open("test", sealed)
+	checkErrors(t, fmt.Errorf("encrypted data is corrupted or key is wrong"), err)
+	_, err = testKey(1).Open("test", sealed[:4])
+	checkErrors(t, fmt.Errorf("malformed encrypted data"), err)
+}
+
+func TestLoadKey(t *testing.T) {
+	folder, _ := ioutil.TempDir("", "bellboy_keys")
+	defer os.RemoveAll(folder)
+	keyFile := filepath.Join(folder, "media.key")
+
+	checkErrors(t, nil, GenerateKeyFile(keyFile))
+	info, _ := os.Stat(keyFile)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	assert.Error(t, GenerateKeyFile(keyFile))
+
+	first, err := LoadKey("keyfile", keyFile, "", "")
+	checkErrors(t, nil, err)
+	second, _ := LoadKey("keyfile", keyFile, "", "")
+	assert.Equal(t, first.ID(), second.ID())
+
+	salt := GenerateSalt()
+	os.Setenv("BELLBOY_TEST_PASSPHRASE", "correct horse battery staple")
+	defer os.Unsetenv("BELLBOY_TEST_PASSPHRASE")
+	first, err = LoadKey("passphrase", "", "BELLBOY_TEST_PASSPHRASE", salt)
+	checkErrors(t, nil, err)
+	second, _ = LoadKey("passphrase", "", "BELLBOY_TEST_PASSPHRASE", salt)
+	assert.Equal(t, first.ID(), second.ID())
+	other, _ := LoadKey("passphrase", "", "BELLBOY_TEST_PASSPHRASE", GenerateSalt())
+	assert.NotEqual(t, first.ID(), other.ID())
+
+	key, err := LoadKey("", "", "", "")
+	assert.Nil(t, key)
+	checkErrors(t, nil, err)
+	_, err = LoadKey("passphrase", "", "BELLBOY_MISSING_PASSPHRASE", salt)
+	checkErrors(t, fmt.Errorf("passphrase should not be empty"), err)
+	_, err = LoadKey("passphrase", "", "BELLBOY_TEST_PASSPHRASE", "abcd")
+	checkErrors(t, fmt.Errorf("salt should be at least 16 bytes"), err)
+	_, err = LoadKey("rot13", "", "", "")
+	checkErrors(t, fmt.Errorf("unknown encryption mode [rot13]"), err)
+
+	ioutil.WriteFile(keyFile+".short", []byte("short"), 0600)
+	_, err = LoadKey("keyfile", keyFile+".short", "", "")
+	checkErrors(t, fmt.Errorf("key file [%s] should contain at least 32 bytes", keyFile+".short"), err)
+}
+
+func TestEncryptedRepository(t *testing.T) {
+	teardown := setup()
+	defer teardown()
+
+	store, folder, removeFolder := newTestEncryptedStore(t, nil)
+	defer removeFolder()
+	encryption := Encryption{Keys: &Keyring{Current: testKey(1)}, Columns: true}
+	encryptedRepo := NewEncryptedRepository(DB, store.Store, encryption)
+
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	png := encodePNG(testImage(64, 32, false))
+	httpmock.RegisterResponder("GET", "http://example.com/photo.png", httpmock.NewBytesResponder(200, png))
+
+	photo := Photo{ExternalURL: "http://example.com/photo.png", PostID: 1, Caption: "nsfw caption"}
+	checkErrors(t, nil, encryptedRepo.AddPhoto(&photo))
+	checkErrors(t, nil, encryptedRepo.AddText(&Text{PostID: 1, Title: "title", Body: "private story"}))
+	assert.Equal(t, "nsfw caption", photo.Caption)
+
+	// files and columns are sealed
+	sealed, _ := ioutil.ReadFile(filepath.Join(folder, "photo_1.png"))
+	assert.Equal(t, fileMagic, string(sealed[:len(fileMagic)]))
+	var caption, body string
+	DB.Get(&caption, "SELECT caption FROM photos WHERE id = ?", photo.ID)
+	DB.Get(&body, "SELECT body FROM texts")
+	assert.True(t, strings.HasPrefix(caption, columnPrefix))
+	assert.True(t, strings.HasPrefix(body, columnPrefix))
+
+	// metadata and thumbnails are made of decrypted contents
+	photos, err := encryptedRepo.FindPhotos(PhotoFilter{})
+	checkErrors(t, nil, err)
+	if assert.Len(t, photos, 1) {
+		assert.Equal(t, 64, photos[0].Width)
+		assert.Equal(t, "nsfw caption", photos[0].Caption)
+	}
+	thumbnail, _ := ioutil.ReadFile(filepath.Join(folder, "photo_1_thumb_256.jpg"))
+	assert.Equal(t, fileMagic, string(thumbnail[:len(fileMagic)]))
+
+	// rotation re-encrypts everything, files written before encryption included
+	ioutil.WriteFile(filepath.Join(folder, "photo_2.gif"), gifContents, 0644)
+	ioutil.WriteFile(filepath.Join(folder, "trash_photo_3.gif"), gifContents, 0644)
+	ioutil.WriteFile(filepath.Join(folder, "avatar_1.gif"), gifContents, 0644)
+	// files of others are skipped whatever they contain
+	ioutil.WriteFile(filepath.Join(folder, "notes.txt"), []byte("notes"), 0644)
+	checkErrors(t, nil, (&EncryptedStore{store.Store, &Keyring{Current: testKey(9)}}).Put("backup.bin", strings.NewReader("foreign")))
+	rotated := NewEncryptedRepository(DB, store.Store, Encryption{
+		Keys:    &Keyring{Current: testKey(2), Previous: []*Key{testKey(1)}},
+		Columns: true,
+	})
+	files, values, err := rotated.Reencrypt()
+	checkErrors(t, nil, err)
+	assert.ElementsMatch(t, []string{"photo_1.png", "photo_1_thumb_256.jpg", "photo_2.gif", "trash_photo_3.gif", "avatar_1.gif"}, files)
+	notes, _ := ioutil.ReadFile(filepath.Join(folder, "notes.txt"))
+	assert.Equal(t, "notes", string(notes))
+	assert.Equal(t, 2, values)
+
+	files, values, err = rotated.Reencrypt()
+	checkErrors(t, nil, err)
+	assert.Empty(t, files)
+	assert.Equal(t, 0, values)
+
+	onlyNewKey := &EncryptedStore{store.Store, &Keyring{Current: testKey(2)}}
+	for name, expected := range map[string][]byte{"photo_1.png": png, "photo_2.gif": gifContents} {
+		file, err := onlyNewKey.Open(name)
+		checkErrors(t, nil, err)
+		contents, _ := ioutil.ReadAll(file)
+		file.Close()
+		assert.Equal(t, expected, contents)
+	}
+	DB.Get(&caption, "SELECT caption FROM photos WHERE id = ?", photo.ID)
+	plain, err := (&Keyring{Current: testKey(2)}).decryptValue(caption)
+	checkErrors(t, nil, err)
+	assert.Equal(t, "nsfw caption", plain)
+
+	// columns are decrypted back when column encryption is off
+	decrypting := NewEncryptedRepository(DB, store.Store, Encryption{Keys: &Keyring{Current: testKey(2)}})
+	_, values, err = decrypting.Reencrypt()
+	checkErrors(t, nil, err)
+	assert.Equal(t, 2, values)
+	DB.Get(&body, "SELECT body FROM texts")
+	assert.Equal(t, "private story", body)
+}
+
+func TestLoadEncryption(t *testing.T) {
+	defer viper.Set("encryption", nil)
+
+	encryption, err := LoadEncryption()
+	checkErrors(t, nil, err)
+	assert.Nil(t, encryption)
+
+	folder, _ := ioutil.TempDir("", "bellboy_keys")
+	defer os.RemoveAll(folder)
+	GenerateKeyFile(filepath.Join(folder, "media.key"))
+	viper.Set("encryption", map[string]interface{}{
+		"mode": "keyfile", "keyfile": filepath.Join(folder, "media.key"), "columns": true,
+	})
+	encryption, err = LoadEncryption()
+	checkErrors(t, nil, err)
+	if assert.NotNil(t, encryption) {
+		assert.True(t, encryption.Columns)
+		assert.NotNil(t, encryption.Keys.Current)
+	}
+}
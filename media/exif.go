@@ -0,0 +1,162 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"time"
+)
+
+// exifData holds EXIF fields bellboy is interested in
+type exifData struct {
+	Orientation int
+	Make        string
+	Model       string
+	TakenAt     *time.Time
+}
+
+const (
+	exifTagMake             = 0x010f
+	exifTagModel            = 0x0110
+	exifTagOrientation      = 0x0112
+	exifTagDateTime         = 0x0132
+	exifTagExifIFDPointer   = 0x8769
+	exifTagDateTimeOriginal = 0x9003
+
+	exifTypeASCII = 2
+	exifTypeShort = 3
+	exifTypeLong  = 4
+
+	exifDateFormat = "2006:01:02 15:04:05"
+)
+
+var errNoExif = errors.New("no EXIF data found")
+
+// parseJPEGExif finds APP1 Exif segment in JPEG file and parses it
+func parseJPEGExif(data []byte) (exifData, error) {
+	if len(data) < 4 || data[0] != 0xff || data[1] != 0xd8 {
+		return exifData{}, errors.New("not a JPEG file")
+	}
+	for offset := 2; offset+4 <= len(data); {
+		if data[offset] != 0xff {
+			return exifData{}, errors.New("malformed JPEG segment")
+		}
+		marker := data[offset+1]
+		// start of scan or end of image: no more metadata segments
+		if marker == 0xda || marker == 0xd9 {
+			break
+		}
+		length := int(binary.BigEndian.Uint16(data[offset+2:]))
+		end := offset + 2 + length
+		if length < 2 || end > len(data) {
+			return exifData{}, errors.New("malformed JPEG segment")
+		}
+		segment := data[offset+4 : end]
+		if marker == 0xe1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return parseTIFF(segment[6:])
+		}
+		offset = end
+	}
+	return exifData{}, errNoExif
+}
+
+type tiffReader struct {
+	data  []byte
+	order binary.ByteOrder
+}
+
+type ifdEntry struct {
+	typ   uint16
+	count uint32
+	value []byte // 4 bytes of value or offset
+}
+
+func parseTIFF(data []byte) (exifData, error) {
+	if len(data) < 8 {
+		return exifData{}, errNoExif
+	}
+	tiff := tiffReader{data: data}
+	switch string(data[:2]) {
+	case "II":
+		tiff.order = binary.LittleEndian
+	case "MM":
+		tiff.order = binary.BigEndian
+	default:
+		return exifData{}, errors.New("unknown EXIF byte order")
+	}
+	if tiff.order.Uint16(data[2:]) != 42 {
+		return exifData{}, errors.New("malformed EXIF header")
+	}
+
+	ifd0, err := tiff.readIFD(tiff.order.Uint32(data[4:]))
+	if err != nil {
+		return exifData{}, err
+	}
+	result := exifData{
+		Orientation: int(tiff.uint(ifd0[exifTagOrientation])),
+		Make:        tiff.string(ifd0[exifTagMake]),
+		Model:       tiff.string(ifd0[exifTagModel]),
+	}
+	takenAt := tiff.string(ifd0[exifTagDateTime])
+	if pointer, ok := ifd0[exifTagExifIFDPointer]; ok {
+		exifIFD, err := tiff.readIFD(tiff.uint(pointer))
+		if err == nil {
+			if original := tiff.string(exifIFD[exifTagDateTimeOriginal]); original != "" {
+				takenAt = original
+			}
+		}
+	}
+	if parsed, err := time.Parse(exifDateFormat, takenAt); err == nil {
+		result.TakenAt = &parsed
+	}
+	return result, nil
+}
+
+func (tiff tiffReader) readIFD(offset uint32) (map[uint16]ifdEntry, error) {
+	if int(offset)+2 > len(tiff.data) {
+		return nil, errors.New("EXIF directory is out of bounds")
+	}
+	count := int(tiff.order.Uint16(tiff.data[offset:]))
+	entries := map[uint16]ifdEntry{}
+	for i := 0; i < count; i++ {
+		start := int(offset) + 2 + i*12
+		if start+12 > len(tiff.data) {
+			return nil, errors.New("EXIF entry is out of bounds")
+		}
+		raw := tiff.data[start : start+12]
+		entries[tiff.order.Uint16(raw)] = ifdEntry{
+			typ:   tiff.order.Uint16(raw[2:]),
+			count: tiff.order.Uint32(raw[4:]),
+			value: raw[8:12],
+		}
+	}
+	return entries, nil
+}
+
+func (tiff tiffReader) uint(entry ifdEntry) uint32 {
+	switch entry.typ {
+	case exifTypeShort:
+		return uint32(tiff.order.Uint16(entry.value))
+	case exifTypeLong:
+		return tiff.order.Uint32(entry.value)
+	}
+	return 0
+}
+
+func (tiff tiffReader) string(entry ifdEntry) string {
+	if entry.typ != exifTypeASCII {
+		return ""
+	}
+	value := entry.value
+	if entry.count > 4 {
+		offset := tiff.order.Uint32(entry.value)
+		if uint64(offset)+uint64(entry.count) > uint64(len(tiff.data)) {
+			return ""
+		}
+		value = tiff.data[offset : offset+entry.count]
+	} else {
+		value = value[:entry.count]
+	}
+	return strings.TrimSpace(strings.TrimRight(string(value), "\x00"))
+}
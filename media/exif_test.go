@@ -0,0 +1,132 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image/jpeg"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testByteOrder interface {
+	binary.ByteOrder
+	binary.AppendByteOrder
+}
+
+type testIFDEntry struct {
+	tag   uint16
+	typ   uint16
+	value interface{} // string, uint16 or uint32
+}
+
+// buildTIFF writes IFD0 with given entries and Exif IFD with DateTimeOriginal
+func buildTIFF(order testByteOrder, entries []testIFDEntry, takenAt string) []byte {
+	var header []byte
+	if order == binary.LittleEndian {
+		header = []byte("II")
+	} else {
+		header = []byte("MM")
+	}
+	header = order.AppendUint16(header, 42)
+	header = order.AppendUint32(header, 8)
+
+	entries = append(entries, testIFDEntry{tag: exifTagExifIFDPointer, typ: exifTypeLong})
+	ifd0Size := 2 + len(entries)*12 + 4
+	exifIFDOffset := uint32(8 + ifd0Size)
+	exifIFDSize := 2 + 12 + 4
+	dataOffset := exifIFDOffset + uint32(exifIFDSize)
+
+	var data []byte
+	writeEntry := func(ifd []byte, entry testIFDEntry) []byte {
+		ifd = order.AppendUint16(ifd, entry.tag)
+		ifd = order.AppendUint16(ifd, entry.typ)
+		switch value := entry.value.(type) {
+		case string:
+			raw := append([]byte(value), 0)
+			ifd = order.AppendUint32(ifd, uint32(len(raw)))
+			if len(raw) <= 4 {
+				ifd = append(ifd, append(raw, make([]byte, 4-len(raw))...)...)
+			} else {
+				ifd = order.AppendUint32(ifd, dataOffset+uint32(len(data)))
+				data = append(data, raw...)
+			}
+		case uint16:
+			ifd = order.AppendUint32(ifd, 1)
+			ifd = order.AppendUint16(ifd, value)
+			ifd = append(ifd, 0, 0)
+		case uint32:
+			ifd = order.AppendUint32(ifd, 1)
+			ifd = order.AppendUint32(ifd, value)
+		}
+		return ifd
+	}
+
+	entries[len(entries)-1].value = exifIFDOffset
+	ifd0 := order.AppendUint16(nil, uint16(len(entries)))
+	for _, entry := range entries {
+		ifd0 = writeEntry(ifd0, entry)
+	}
+	ifd0 = order.AppendUint32(ifd0, 0)
+
+	exifIFD := order.AppendUint16(nil, 1)
+	exifIFD = writeEntry(exifIFD, testIFDEntry{tag: exifTagDateTimeOriginal, typ: exifTypeASCII, value: takenAt})
+	exifIFD = order.AppendUint32(exifIFD, 0)
+
+	return append(append(append(header, ifd0...), exifIFD...), data...)
+}
+
+// jpegWithExif encodes test image and inserts APP1 segment right after SOI marker
+func jpegWithExif(width, height int, tiff []byte) []byte {
+	var buf bytes.Buffer
+	jpeg.Encode(&buf, testImage(width, height, false), nil)
+	encoded := buf.Bytes()
+
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+	app1 := []byte{0xff, 0xe1}
+	app1 = binary.BigEndian.AppendUint16(app1, uint16(len(segment)+2))
+	app1 = append(app1, segment...)
+
+	return append(append(append([]byte{}, encoded[:2]...), app1...), encoded[2:]...)
+}
+
+func TestParseJPEGExif(t *testing.T) {
+	entries := []testIFDEntry{
+		{exifTagMake, exifTypeASCII, "Canon"},
+		{exifTagModel, exifTypeASCII, "EOS 5D"},
+		{exifTagOrientation, exifTypeShort, uint16(6)},
+	}
+	takenAt := time.Date(2016, 7, 12, 18, 30, 5, 0, time.UTC)
+
+	for _, order := range []testByteOrder{binary.LittleEndian, binary.BigEndian} {
+		exif, err := parseJPEGExif(jpegWithExif(32, 16, buildTIFF(order, entries, "2016:07:12 18:30:05")))
+		checkErrors(t, nil, err)
+		assert.Equal(t, "Canon", exif.Make)
+		assert.Equal(t, "EOS 5D", exif.Model)
+		assert.Equal(t, 6, exif.Orientation)
+		if assert.NotNil(t, exif.TakenAt) {
+			assert.Equal(t, takenAt, *exif.TakenAt)
+		}
+	}
+}
+
+func TestParseJPEGExifWithoutExif(t *testing.T) {
+	var buf bytes.Buffer
+	jpeg.Encode(&buf, testImage(32, 16, false), nil)
+
+	testCases := []struct {
+		data []byte
+		err  error
+	}{
+		{buf.Bytes(), errNoExif},
+		{[]byte("not a jpeg"), errors.New("not a JPEG file")},
+		{[]byte{0xff, 0xd8, 0xff, 0xe1, 0xff, 0xff}, errors.New("malformed JPEG segment")},
+	}
+
+	for _, testCase := range testCases {
+		_, err := parseJPEGExif(testCase.data)
+		checkErrors(t, testCase.err, err)
+	}
+}
@@ -0,0 +1,259 @@
+package media
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ExportedCollection is a page of exported collection
+type ExportedCollection struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	Cover       string         `json:"cover,omitempty"` // path of the cover photo relative to the export folder
+	Page        string         `json:"page"`            // file name of the collection page
+	Posts       []ExportedPost `json:"posts,omitempty"`
+}
+
+// ExportedPost is a post with its media and tags, media paths are relative
+// to the export folder, URLs are used for media which is not stored
+type ExportedPost struct {
+	ID          uint      `json:"id"`
+	Type        string    `json:"type"`
+	Source      string    `json:"source"`
+	Category    string    `json:"category"`
+	ExternalURL string    `json:"external_url"`
+	ReleasedAt  time.Time `json:"released_at"`
+	Summary     string    `json:"summary"`
+	Tags        []string  `json:"tags"`
+	Photos      []string  `json:"photos,omitempty"`
+	Videos      []string  `json:"videos,omitempty"`
+	Thumbnails  []string  `json:"thumbnails,omitempty"` // photo thumbnails, video thumbnails and posters
+	Links       []string  `json:"links,omitempty"`
+	Title       string    `json:"title,omitempty"`
+	Body        string    `json:"body,omitempty"`
+}
+
+// exportMediaFolder is a subfolder of export folder where media files are copied
+const exportMediaFolder = "media"
+
+// ExportCollections writes index page and a page per collection to the folder
+// in "json" or "html" format, returns number of exported collections.
+// Media files are copied decrypted to "media" subfolder, so the export is portable.
+func (r mediaRepo) ExportCollections(folder, format string) (int, error) {
+	if format != "json" && format != "html" {
+		return 0, fmt.Errorf("unknown export format [%s]", format)
+	}
+	err := os.MkdirAll(filepath.Join(folder, exportMediaFolder), 0755)
+	if err != nil {
+		return 0, err
+	}
+	collections, err := r.ListCollections()
+	if err != nil {
+		return 0, err
+	}
+
+	index := []ExportedCollection{}
+	for _, collection := range collections {
+		exported, err := r.exportCollection(folder, collection.Collection, format)
+		if err != nil {
+			return len(index), err
+		}
+		err = writeExportPage(filepath.Join(folder, exported.Page), format, collectionTemplate, exported)
+		if err != nil {
+			return len(index), err
+		}
+		exported.Posts = nil
+		index = append(index, exported)
+	}
+	return len(index), writeExportPage(filepath.Join(folder, "index."+format), format, indexTemplate, index)
+}
+
+func (r mediaRepo) exportCollection(folder string, collection Collection, format string) (ExportedCollection, error) {
+	exported := ExportedCollection{
+		Name:        collection.Name,
+		Description: collection.Description,
+		Page:        fmt.Sprintf("collection_%d.%s", collection.ID, format),
+		Posts:       []ExportedPost{},
+	}
+	if collection.CoverPhotoID != nil {
+		var cover Photo
+		err := r.DB.Get(&cover, "SELECT id, external_url, mime_type FROM photos WHERE id = ?", *collection.CoverPhotoID)
+		if err == nil {
+			exported.Cover, err = r.exportFile(folder, cover.FileName(), cover.ExternalURL)
+			if err != nil {
+				return exported, err
+			}
+		}
+	}
+	posts, err := r.ListCollectionPosts(collection.Name)
+	if err != nil {
+		return exported, err
+	}
+	for _, post := range posts {
+		exportedPost, err := r.exportPost(folder, post)
+		if err != nil {
+			return exported, err
+		}
+		exported.Posts = append(exported.Posts, exportedPost)
+	}
+	return exported, nil
+}
+
+func (r mediaRepo) exportPost(folder string, post Post) (ExportedPost, error) {
+	exported := ExportedPost{
+		ID:          post.ID,
+		Type:        post.Type,
+		Source:      post.Source,
+		Category:    post.Category,
+		ExternalURL: post.ExternalURL,
+		ReleasedAt:  post.ReleasedAt,
+		Summary:     post.Summary,
+	}
+	var err error
+	exported.Tags, err = r.ListPostTags(post.ID)
+	if err != nil {
+		return exported, err
+	}
+
+	var photos []Photo
+	err = r.DB.Select(&photos, "SELECT id, external_url, mime_type FROM photos WHERE post_id = ? ORDER BY id", post.ID)
+	if err != nil {
+		return exported, err
+	}
+	for _, photo := range photos {
+		path, err := r.exportFile(folder, photo.FileName(), photo.ExternalURL)
+		if err != nil {
+			return exported, err
+		}
+		exported.Photos = append(exported.Photos, path)
+		for _, size := range thumbnailSizes() {
+			err = r.exportThumbnail(folder, photo.ThumbnailFileName(size), &exported)
+			if err != nil {
+				return exported, err
+			}
+		}
+	}
+	var videos []Video
+	err = r.DB.Select(
+		&videos,
+		"SELECT id, external_url, thumbnail_url, mime_type, thumbnail_mime_type FROM videos WHERE post_id = ? ORDER BY id",
+		post.ID,
+	)
+	if err != nil {
+		return exported, err
+	}
+	for _, video := range videos {
+		path, err := r.exportFile(folder, video.FileName(), video.ExternalURL)
+		if err != nil {
+			return exported, err
+		}
+		exported.Videos = append(exported.Videos, path)
+		names := []string{video.ThumbnailFileName()}
+		for _, size := range thumbnailSizes() {
+			names = append(names, video.PosterFileName(size))
+		}
+		for _, name := range names {
+			err = r.exportThumbnail(folder, name, &exported)
+			if err != nil {
+				return exported, err
+			}
+		}
+	}
+	err = r.DB.Select(&exported.Links, "SELECT url FROM links WHERE post_id = ? ORDER BY id", post.ID)
+	if err != nil {
+		return exported, err
+	}
+
+	var text Text
+	err = r.DB.Get(&text, "SELECT title, body FROM texts WHERE post_id = ?", post.ID)
+	if err == nil {
+		exported.Title = text.Title
+		exported.Body, err = r.openColumn(text.Body)
+	} else {
+		err = nil
+	}
+	return exported, err
+}
+
+// exportFile copies decrypted media file to the export folder and returns its relative path,
+// URL is returned for files which are not stored
+func (r mediaRepo) exportFile(folder, name, url string) (string, error) {
+	file, err := r.Store.Open(name)
+	if os.IsNotExist(err) {
+		return url, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	err = NewLocalStore(filepath.Join(folder, exportMediaFolder)).Put(name, file)
+	if err != nil {
+		return "", err
+	}
+	return exportMediaFolder + "/" + name, nil
+}
+
+// exportThumbnail copies thumbnail to the export folder, missing thumbnails are skipped
+func (r mediaRepo) exportThumbnail(folder, name string, exported *ExportedPost) error {
+	path, err := r.exportFile(folder, name, "")
+	if path != "" {
+		exported.Thumbnails = append(exported.Thumbnails, path)
+	}
+	return err
+}
+
+func writeExportPage(path, format string, page *template.Template, data interface{}) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if format == "json" {
+		encoder := json.NewEncoder(file)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(data)
+	}
+	return page.Execute(file, data)
+}
+
+var indexTemplate = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Collections</title></head>
+<body>
+<h1>Collections</h1>
+<ul>
+{{range .}}<li>
+{{if .Cover}}<img src="{{.Cover}}" alt="" width="256">{{end}}
+<a href="{{.Page}}">{{.Name}}</a>
+<p>{{.Description}}</p>
+</li>
+{{end}}</ul>
+</body>
+</html>
+`))
+
+var collectionTemplate = template.Must(template.New("collection").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{.Name}}</title></head>
+<body>
+<p><a href="index.html">Collections</a></p>
+<h1>{{.Name}}</h1>
+{{if .Cover}}<img src="{{.Cover}}" alt="">{{end}}
+<p>{{.Description}}</p>
+{{range .Posts}}<article>
+<h2><a href="{{.ExternalURL}}">{{.Category}}</a> {{.ReleasedAt.Format "2006-01-02"}}</h2>
+{{if .Title}}<h3>{{.Title}}</h3>{{end}}
+{{range .Photos}}<img src="{{.}}" alt="">
+{{end}}{{range .Videos}}<video src="{{.}}" controls></video>
+{{end}}{{range .Links}}<a href="{{.}}">{{.}}</a>
+{{end}}{{if .Body}}<div>{{.Body}}</div>{{end}}
+<p>{{.Summary}}</p>
+{{if .Tags}}<p>{{range .Tags}}#{{.}} {{end}}</p>{{end}}
+</article>
+{{end}}</body>
+</html>
+`))
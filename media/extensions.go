@@ -0,0 +1,211 @@
+package media
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// sniffLength is the number of first bytes http.DetectContentType looks at
+const sniffLength = 512
+
+// mimeExtensions lists preferred extensions, system mime tables are not always available
+var mimeExtensions = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/gif":       ".gif",
+	"image/webp":      ".webp",
+	"image/bmp":       ".bmp",
+	"video/mp4":       ".mp4",
+	"video/webm":      ".webm",
+	"video/quicktime": ".mov",
+	"audio/mpeg":      ".mp3",
+}
+
+// detectMimeType sniffs content type of the file without parameters like charset
+func detectMimeType(data []byte) string {
+	mimeType, _, err := mime.ParseMediaType(http.DetectContentType(data))
+	if err != nil {
+		return ""
+	}
+	return mimeType
+}
+
+// sniffMimeType detects content type from the first bytes of the file,
+// type from response header is used when contents are not recognized
+func sniffMimeType(head []byte, contentType string) string {
+	detected := detectMimeType(head)
+	if isMediaType(detected) {
+		return detected
+	}
+	declared, _, err := mime.ParseMediaType(contentType)
+	if err == nil && isMediaType(declared) {
+		return declared
+	}
+	return detected
+}
+
+// isMediaType tells whether type is an image, video or audio
+func isMediaType(mimeType string) bool {
+	return strings.HasPrefix(mimeType, "image/") ||
+		strings.HasPrefix(mimeType, "video/") ||
+		strings.HasPrefix(mimeType, "audio/")
+}
+
+// extensionForType returns file extension for media type, empty string for unknown ones
+func extensionForType(mimeType string) string {
+	if !isMediaType(mimeType) {
+		return ""
+	}
+	if ext, ok := mimeExtensions[mimeType]; ok {
+		return ext
+	}
+	extensions, err := mime.ExtensionsByType(mimeType)
+	if err != nil || len(extensions) == 0 {
+		return ""
+	}
+	return extensions[0]
+}
+
+// fileExtension picks extension by detected type, falls back to extension from URL
+func fileExtension(mimeType, url string) string {
+	if ext := extensionForType(mimeType); ext != "" {
+		return ext
+	}
+	return extension(url)
+}
+
+// extensionMatches tells whether file extension is one of the known extensions of the type
+func extensionMatches(ext, mimeType string) bool {
+	extensions, _ := mime.ExtensionsByType(mimeType)
+	for _, known := range append(extensions, mimeExtensions[mimeType]) {
+		if strings.EqualFold(known, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// FixExtensions sniffs types of stored media files, stores detected types
+// and renames files which extensions don't match. Returns number of renamed files.
+func (r mediaRepo) FixExtensions() (int, error) {
+	var photos []Photo
+	err := r.DB.Select(&photos, "SELECT id, post_id, external_url, mime_type FROM photos WHERE "+livePosts+" ORDER BY id")
+	if err != nil {
+		return 0, err
+	}
+	var videos []Video
+	err = r.DB.Select(
+		&videos,
+		"SELECT id, post_id, external_url, thumbnail_url, mime_type, thumbnail_mime_type FROM videos WHERE "+livePosts+" ORDER BY id",
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	stored, err := r.Store.List()
+	if err != nil {
+		return 0, err
+	}
+	names := make([]string, 0, len(stored))
+	for _, file := range stored {
+		names = append(names, file.Name)
+	}
+	sort.Strings(names)
+
+	fixed := 0
+	for i := range photos {
+		photo := &photos[i]
+		renamed, err := r.fixFileExtension(
+			names, fmt.Sprintf("photo_%d", photo.ID), photo.ExternalURL, &photo.MimeType,
+			func() string { return photo.FileName() },
+		)
+		if err != nil {
+			return fixed, err
+		}
+		_, err = r.DB.Exec("UPDATE photos SET mime_type = ? WHERE id = ?", photo.MimeType, photo.ID)
+		if err != nil {
+			return fixed, err
+		}
+		if renamed {
+			fixed++
+		}
+	}
+	for i := range videos {
+		video := &videos[i]
+		renamed, err := r.fixFileExtension(
+			names, fmt.Sprintf("video_%d", video.ID), video.ExternalURL, &video.MimeType,
+			func() string { return video.FileName() },
+		)
+		if err != nil {
+			return fixed, err
+		}
+		thumbnailRenamed, err := r.fixFileExtension(
+			names, fmt.Sprintf("video_%d_thumbnail", video.ID), video.ThumbnailURL, &video.ThumbnailMimeType,
+			func() string { return video.ThumbnailFileName() },
+		)
+		if err != nil {
+			return fixed, err
+		}
+		_, err = r.DB.Exec(
+			"UPDATE videos SET mime_type = ?, thumbnail_mime_type = ? WHERE id = ?",
+			video.MimeType, video.ThumbnailMimeType, video.ID,
+		)
+		if err != nil {
+			return fixed, err
+		}
+		if renamed {
+			fixed++
+		}
+		if thumbnailRenamed {
+			fixed++
+		}
+	}
+	return fixed, nil
+}
+
+// fixFileExtension finds existing file of media object among stored names by its
+// base name, sniffs its type and moves it to the name derived from detected type
+func (r mediaRepo) fixFileExtension(names []string, baseName, url string, mimeType *string, name func() string) (bool, error) {
+	candidates := []string{name(), baseName + extension(url), baseName}
+	for _, stored := range names {
+		if strings.HasPrefix(stored, baseName+".") {
+			candidates = append(candidates, stored)
+		}
+	}
+
+	existing := ""
+	for _, candidate := range candidates {
+		i := sort.SearchStrings(names, candidate)
+		if i < len(names) && names[i] == candidate {
+			existing = candidate
+			break
+		}
+	}
+	// missing files can't be fixed
+	if existing == "" {
+		return false, nil
+	}
+
+	file, err := r.Store.Open(existing)
+	if err != nil {
+		return false, err
+	}
+	head := make([]byte, sniffLength)
+	n, _ := io.ReadFull(file, head)
+	file.Close()
+
+	// type from response header is kept if contents are not recognized
+	detected := detectMimeType(head[:n])
+	if isMediaType(detected) || !isMediaType(*mimeType) {
+		*mimeType = detected
+	}
+
+	if name() == existing {
+		return false, nil
+	}
+	return true, moveFile(r.Store, existing, name())
+}
@@ -0,0 +1,148 @@
+package media
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	httpmock "gopkg.in/jarcoal/httpmock.v1"
+)
+
+var gifContents = []byte("GIF89a\x01\x00\x01\x00\x00\x00\x00;")
+
+func TestSniffMimeType(t *testing.T) {
+	testCases := []struct {
+		head        []byte
+		contentType string
+		expected    string
+	}{
+		{gifContents, "", "image/gif"},
+		{gifContents, "image/jpeg", "image/gif"},
+		{encodePNG(testImage(4, 4, false)), "application/octet-stream", "image/png"},
+		{[]byte("\x00\x01\x02unknown"), "video/mp4; charset=binary", "video/mp4"},
+		{[]byte("\x00\x01\x02unknown"), "text/html", "application/octet-stream"},
+		{[]byte("plain text"), "", "text/plain"},
+	}
+
+	for _, testCase := range testCases {
+		assert.Equal(t, testCase.expected, sniffMimeType(testCase.head, testCase.contentType))
+	}
+}
+
+func TestFileExtension(t *testing.T) {
+	testCases := []struct {
+		mimeType string
+		url      string
+		expected string
+	}{
+		{"image/jpeg", "http://example.com/photo", ".jpg"},
+		{"image/png", "http://example.com/photo.jpg", ".png"},
+		{"image/gif", "http://example.com/photo.gifv?q=1", ".gif"},
+		{"video/mp4", "http://example.com/video", ".mp4"},
+		{"text/plain", "http://example.com/photo.jpg", ".jpg"},
+		{"", "http://example.com/photo.jpeg", ".jpeg"},
+		{"", "http://example.com/photo", ""},
+	}
+
+	for _, testCase := range testCases {
+		assert.Equal(t, testCase.expected, fileExtension(testCase.mimeType, testCase.url))
+	}
+}
+
+func TestAddPhotoSniffsExtension(t *testing.T) {
+	teardown := setup()
+	defer teardown()
+
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	photo := Photo{ExternalURL: "http://example.com/photo_without_extension", PostID: 1}
+	httpmock.RegisterResponder("GET", photo.ExternalURL, httpmock.NewBytesResponder(200, gifContents))
+
+	err := repo.AddPhoto(&photo)
+	checkErrors(t, nil, err)
+	defer removePhotoFiles(&photo)
+
+	assert.Equal(t, "image/gif", photo.MimeType)
+	assert.Equal(t, "photo_1.gif", photo.FileName())
+	contents, _ := ioutil.ReadFile(repo.GetPhotoPath(&photo))
+	assert.Equal(t, gifContents, contents)
+
+	var dbPhoto Photo
+	DB.Get(&dbPhoto, "SELECT id, external_url, mime_type FROM photos WHERE id = ?", photo.ID)
+	assert.Equal(t, "image/gif", dbPhoto.MimeType)
+}
+
+func TestAddVideoUsesContentTypeHeader(t *testing.T) {
+	teardown := setup()
+	defer teardown()
+
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	video := Video{ExternalURL: "http://example.com/video.gifv", ThumbnailURL: "http://example.com/thumbnail", PostID: 1}
+	httpmock.RegisterResponder("GET", video.ExternalURL, func(req *http.Request) (*http.Response, error) {
+		resp := httpmock.NewBytesResponse(200, []byte("\x00\x01\x02 video contents"))
+		resp.Header.Set("Content-Type", "video/webm")
+		return resp, nil
+	})
+	httpmock.RegisterResponder("GET", video.ThumbnailURL, httpmock.NewBytesResponder(200, gifContents))
+
+	err := repo.AddVideo(&video)
+	checkErrors(t, nil, err)
+	defer os.Remove(repo.GetVideoPath(&video))
+	defer os.Remove(repo.GetVideoThumbnailPath(&video))
+
+	assert.Equal(t, "video_1.webm", video.FileName())
+	assert.Equal(t, "video_1_thumbnail.gif", video.ThumbnailFileName())
+
+	var dbVideo Video
+	DB.Get(&dbVideo, "SELECT id, external_url, thumbnail_url, mime_type, thumbnail_mime_type FROM videos WHERE id = ?", video.ID)
+	assert.Equal(t, "video/webm", dbVideo.MimeType)
+	assert.Equal(t, "image/gif", dbVideo.ThumbnailMimeType)
+	assert.Equal(t, repo.GetVideoPath(&video), repo.GetVideoPath(&dbVideo))
+}
+
+func TestFixExtensions(t *testing.T) {
+	teardown := setup()
+	defer teardown()
+
+	// files stored by older versions are named after URL extension
+	DB.Exec("INSERT INTO photos (id, post_id, external_url) VALUES (1, 1, 'http://example.com/photo.jpg')")
+	DB.Exec("INSERT INTO photos (id, post_id, external_url) VALUES (2, 1, 'http://example.com/photo.gif')")
+	DB.Exec("INSERT INTO photos (id, post_id, external_url) VALUES (3, 1, 'http://example.com/missing.gif')")
+	DB.Exec("INSERT INTO videos (id, post_id, external_url, thumbnail_url) VALUES (1, 1, 'http://example.com/video.gifv', 'http://example.com/thumbnail')")
+	ioutil.WriteFile("photo_1.jpg", gifContents, 0644)
+	ioutil.WriteFile("photo_2.gif", gifContents, 0644)
+	ioutil.WriteFile("video_1.gifv", []byte("\x00\x00\x00\x18ftypmp42\x00\x00\x00\x00mp42isom"), 0644)
+	ioutil.WriteFile("video_1_thumbnail", gifContents, 0644)
+	defer func() {
+		for _, name := range []string{"photo_1.jpg", "photo_1.gif", "photo_2.gif", "video_1.gifv", "video_1.mp4", "video_1_thumbnail", "video_1_thumbnail.gif"} {
+			os.Remove(name)
+		}
+	}()
+
+	fixed, err := repo.FixExtensions()
+	checkErrors(t, nil, err)
+	assert.Equal(t, 3, fixed)
+
+	for _, name := range []string{"photo_1.gif", "photo_2.gif", "video_1.mp4", "video_1_thumbnail.gif"} {
+		_, err := os.Stat(name)
+		assert.Nil(t, err, "file [%s] should exist", name)
+	}
+	for _, name := range []string{"photo_1.jpg", "video_1.gifv", "video_1_thumbnail"} {
+		_, err := os.Stat(name)
+		assert.True(t, os.IsNotExist(err), "file [%s] should be renamed", name)
+	}
+
+	var mimeTypes []string
+	DB.Select(&mimeTypes, "SELECT mime_type FROM photos ORDER BY id")
+	assert.Equal(t, []string{"image/gif", "image/gif", ""}, mimeTypes)
+
+	var video Video
+	DB.Get(&video, "SELECT id, mime_type, thumbnail_mime_type FROM videos WHERE id = 1")
+	assert.Equal(t, "video/mp4", video.MimeType)
+	assert.Equal(t, "image/gif", video.ThumbnailMimeType)
+}
@@ -0,0 +1,356 @@
+package media
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Kinds of inconsistencies reported by Fsck
+const (
+	IssueMissingFile     = "missing_file"
+	IssueEmptyFile       = "empty_file"
+	IssueOrphanFile      = "orphan_file"
+	IssueEmptyPost       = "empty_post"
+	IssueDanglingPostTag = "dangling_post_tag"
+	IssueUnusedTag       = "unused_tag"
+)
+
+// mediaFileRegexp matches names of files created by the repository,
+// other files in media folder are never considered orphans
+var mediaFileRegexp = regexp.MustCompile(`^(?:photo|video|avatar)_\d+(?:[_.].*)?$`)
+
+// isRepositoryFile tells whether the file is created by the repository, trashed media files included
+func isRepositoryFile(name string) bool {
+	return mediaFileRegexp.MatchString(strings.TrimPrefix(name, trashPrefix))
+}
+
+// FsckOptions configures integrity check
+type FsckOptions struct {
+	Repair           bool   // re-download missing media, remove orphans and dangling rows
+	QuarantineFolder string // orphans are moved here instead of deletion when set
+}
+
+// FsckIssue is one inconsistency between database and media folder
+type FsckIssue struct {
+	Kind     string `json:"kind"`
+	Table    string `json:"table,omitempty"`
+	ID       uint   `json:"id,omitempty"`
+	PostID   uint   `json:"post_id,omitempty"`
+	TagID    uint   `json:"tag_id,omitempty"`
+	Path     string `json:"path,omitempty"`
+	URL      string `json:"url,omitempty"`
+	Repaired bool   `json:"repaired"`
+	Error    string `json:"error,omitempty"` // why repair failed
+}
+
+// FsckReport is the result of integrity check
+type FsckReport struct {
+	Photos int         `json:"photos"`
+	Videos int         `json:"videos"`
+	Files  int         `json:"files"`
+	Issues []FsckIssue `json:"issues"`
+}
+
+// Unresolved returns number of issues left after repair
+func (report FsckReport) Unresolved() int {
+	count := 0
+	for _, issue := range report.Issues {
+		if !issue.Repaired {
+			count++
+		}
+	}
+	return count
+}
+
+// mediaFile is one file that should exist for photo or video row
+type mediaFile struct {
+	table    string
+	id       uint
+	postID   uint
+	url      string
+	name     func() string
+	mimeType *string
+	restored func() error // called after successful re-download
+}
+
+// Fsck verifies that every media row has non-empty file, media folder has no
+// orphaned files and posts, tags and their connections are consistent
+func (r mediaRepo) Fsck(options FsckOptions) (FsckReport, error) {
+	report := FsckReport{Issues: []FsckIssue{}}
+
+	var photos []Photo
+	err := r.DB.Select(&photos, "SELECT id, post_id, external_url, mime_type FROM photos WHERE "+livePosts+" ORDER BY id")
+	if err != nil {
+		return report, err
+	}
+	var videos []Video
+	err = r.DB.Select(&videos, "SELECT id, post_id, external_url, thumbnail_url, mime_type, thumbnail_mime_type FROM videos WHERE "+livePosts+" ORDER BY id")
+	if err != nil {
+		return report, err
+	}
+	var subs []Subscription
+	err = r.DB.Select(&subs, "SELECT id, avatar_mime_type FROM subscriptions WHERE avatar_mime_type != '' ORDER BY id")
+	if err != nil {
+		return report, err
+	}
+	report.Photos = len(photos)
+	report.Videos = len(videos)
+
+	var files []mediaFile
+	for i := range photos {
+		photo := &photos[i]
+		files = append(files, mediaFile{
+			table:    "photos",
+			id:       photo.ID,
+			postID:   photo.PostID,
+			url:      photo.ExternalURL,
+			name:     func() string { return photo.FileName() },
+			mimeType: &photo.MimeType,
+			restored: func() error {
+				_, err := r.DB.Exec("UPDATE photos SET mime_type = ? WHERE id = ?", photo.MimeType, photo.ID)
+				r.generatePhotoThumbnails(photo)
+				return err
+			},
+		})
+	}
+	for i := range videos {
+		video := &videos[i]
+		files = append(files, mediaFile{
+			table:    "videos",
+			id:       video.ID,
+			postID:   video.PostID,
+			url:      video.ExternalURL,
+			name:     func() string { return video.FileName() },
+			mimeType: &video.MimeType,
+			restored: func() error {
+				_, err := r.DB.Exec("UPDATE videos SET mime_type = ? WHERE id = ?", video.MimeType, video.ID)
+				return err
+			},
+		}, mediaFile{
+			table:    "videos",
+			id:       video.ID,
+			postID:   video.PostID,
+			url:      video.ThumbnailURL,
+			name:     func() string { return video.ThumbnailFileName() },
+			mimeType: &video.ThumbnailMimeType,
+			restored: func() error {
+				_, err := r.DB.Exec("UPDATE videos SET thumbnail_mime_type = ? WHERE id = ?", video.ThumbnailMimeType, video.ID)
+				r.generateVideoPosters(video)
+				return err
+			},
+		})
+	}
+
+	for _, file := range files {
+		issue, err := r.checkMediaFile(file, options.Repair)
+		if err != nil {
+			return report, err
+		}
+		if issue != nil {
+			report.Issues = append(report.Issues, *issue)
+		}
+	}
+
+	// names are collected after repair since re-downloaded files could change extensions
+	known := map[string]bool{}
+	for _, file := range files {
+		known[file.name()] = true
+	}
+	for _, size := range thumbnailSizes() {
+		for _, photo := range photos {
+			known[photo.ThumbnailFileName(size)] = true
+		}
+		for _, video := range videos {
+			known[video.PosterFileName(size)] = true
+		}
+	}
+	for _, sub := range subs {
+		known[sub.AvatarFileName()] = true
+	}
+
+	orphans, filesCount, err := r.findOrphans(known, options)
+	if err != nil {
+		return report, err
+	}
+	report.Files = filesCount
+	report.Issues = append(report.Issues, orphans...)
+
+	for _, check := range []func(FsckOptions) ([]FsckIssue, error){
+		r.findEmptyPosts, r.findDanglingPostTags, r.findUnusedTags,
+	} {
+		issues, err := check(options)
+		if err != nil {
+			return report, err
+		}
+		report.Issues = append(report.Issues, issues...)
+	}
+	return report, nil
+}
+
+// checkMediaFile reports missing or empty file, re-downloads it in repair mode
+func (r mediaRepo) checkMediaFile(file mediaFile, repair bool) (*FsckIssue, error) {
+	name := file.name()
+	issue := FsckIssue{Table: file.table, ID: file.id, PostID: file.postID, Path: r.Store.Location(name), URL: file.url}
+
+	info, err := r.Store.Stat(name)
+	switch {
+	case os.IsNotExist(err):
+		issue.Kind = IssueMissingFile
+	case err != nil:
+		return nil, err
+	case info.Size == 0:
+		issue.Kind = IssueEmptyFile
+	default:
+		return nil, nil
+	}
+	if !repair {
+		return &issue, nil
+	}
+
+	err = download(r.Store, downloadTask{url: file.url, name: file.name, mimeType: file.mimeType})
+	if err == nil {
+		err = file.restored()
+	}
+	if err != nil {
+		issue.Error = err.Error()
+		return &issue, nil
+	}
+	// empty file is left under the old name when detected type changes extension
+	if file.name() != name && issue.Kind == IssueEmptyFile {
+		r.Store.Delete(name)
+	}
+	issue.Repaired = true
+	return &issue, nil
+}
+
+// findOrphans looks for stored files that don't belong to any media row,
+// returns them with the number of media files found in the store
+func (r mediaRepo) findOrphans(known map[string]bool, options FsckOptions) ([]FsckIssue, int, error) {
+	files, err := r.Store.List()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	issues := []FsckIssue{}
+	count := 0
+	for _, file := range files {
+		if !mediaFileRegexp.MatchString(file.Name) {
+			continue
+		}
+		count++
+		if known[file.Name] {
+			continue
+		}
+
+		issue := FsckIssue{Kind: IssueOrphanFile, Path: r.Store.Location(file.Name)}
+		if options.Repair {
+			err := r.removeOrphan(file.Name, options.QuarantineFolder)
+			if err != nil {
+				issue.Error = err.Error()
+			} else {
+				issue.Repaired = true
+			}
+		}
+		issues = append(issues, issue)
+	}
+	return issues, count, nil
+}
+
+// removeOrphan deletes the file or moves it to local quarantine folder,
+// encrypted files are moved sealed as they are
+func (r mediaRepo) removeOrphan(name, quarantine string) error {
+	if quarantine != "" {
+		err := os.MkdirAll(quarantine, 0755)
+		if err != nil {
+			return err
+		}
+		store := r.Store
+		if encrypted, ok := store.(*EncryptedStore); ok {
+			store = encrypted.Store
+		}
+		file, err := store.Open(name)
+		if err != nil {
+			return err
+		}
+		err = NewLocalStore(quarantine).Put(name, file)
+		file.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return r.Store.Delete(name)
+}
+
+// findEmptyPosts reports posts without photos, videos, texts and links,
+// they are never removed automatically
+func (r mediaRepo) findEmptyPosts(options FsckOptions) ([]FsckIssue, error) {
+	var ids []uint
+	err := r.DB.Select(&ids, `SELECT id FROM posts p
+     WHERE deleted_at IS NULL
+       AND NOT EXISTS (SELECT 1 FROM photos WHERE post_id = p.id)
+       AND NOT EXISTS (SELECT 1 FROM videos WHERE post_id = p.id)
+       AND NOT EXISTS (SELECT 1 FROM texts WHERE post_id = p.id)
+       AND NOT EXISTS (SELECT 1 FROM links WHERE post_id = p.id)
+     ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	issues := []FsckIssue{}
+	for _, id := range ids {
+		issues = append(issues, FsckIssue{Kind: IssueEmptyPost, Table: "posts", ID: id})
+	}
+	return issues, nil
+}
+
+// findDanglingPostTags reports posts_tags and posts_suggested_tags rows pointing to missing post or tag
+func (r mediaRepo) findDanglingPostTags(options FsckOptions) ([]FsckIssue, error) {
+	issues := []FsckIssue{}
+	for _, table := range []string{"posts_tags", "posts_suggested_tags"} {
+		var rows []struct {
+			PostID uint `db:"post_id"`
+			TagID  uint `db:"tag_id"`
+		}
+		err := r.DB.Select(&rows, fmt.Sprintf(`SELECT post_id, tag_id FROM %s pt
+     WHERE NOT EXISTS (SELECT 1 FROM posts WHERE id = pt.post_id)
+        OR NOT EXISTS (SELECT 1 FROM tags WHERE id = pt.tag_id)
+     ORDER BY post_id, tag_id`, table))
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			issue := FsckIssue{Kind: IssueDanglingPostTag, Table: table, PostID: row.PostID, TagID: row.TagID}
+			if options.Repair {
+				_, err := r.DB.Exec("DELETE FROM "+table+" WHERE post_id = ? AND tag_id = ?", row.PostID, row.TagID)
+				if err != nil {
+					issue.Error = err.Error()
+				} else {
+					issue.Repaired = true
+				}
+			}
+			issues = append(issues, issue)
+		}
+	}
+	return issues, nil
+}
+
+// findUnusedTags reports tags not assigned to any existing post, they are kept on repair
+func (r mediaRepo) findUnusedTags(options FsckOptions) ([]FsckIssue, error) {
+	var ids []uint
+	err := r.DB.Select(&ids, `SELECT id FROM tags t
+     WHERE NOT EXISTS (
+       SELECT 1 FROM posts_tags pt JOIN posts p ON p.id = pt.post_id WHERE pt.tag_id = t.id
+     ) AND NOT EXISTS (
+       SELECT 1 FROM posts_suggested_tags pst JOIN posts p ON p.id = pst.post_id WHERE pst.tag_id = t.id
+     )
+     ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	issues := []FsckIssue{}
+	for _, id := range ids {
+		issues = append(issues, FsckIssue{Kind: IssueUnusedTag, Table: "tags", TagID: id})
+	}
+	return issues, nil
+}
@@ -0,0 +1,204 @@
+package media
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	httpmock "gopkg.in/jarcoal/httpmock.v1"
+)
+
+// setupFsck fills database and temporary media folder with broken data
+func setupFsck(t *testing.T) (string, func()) {
+	teardown := setup()
+
+	folder, err := ioutil.TempDir("", "bellboy_fsck")
+	checkErrors(t, nil, err)
+	viper.Set("media_folder", folder)
+	repo = NewRepository(DB)
+
+	DB.Exec("INSERT INTO posts (id, external_id) VALUES (1, 'with_photos'), (2, 'with_video'), (3, 'empty')")
+	DB.Exec(`INSERT INTO photos (id, post_id, external_url, mime_type) VALUES
+    (1, 1, 'http://example.com/ok.gif', 'image/gif'),
+    (2, 1, 'http://example.com/missing.gif', ''),
+    (3, 1, 'http://example.com/empty.gif', '')`)
+	DB.Exec(`INSERT INTO videos (id, post_id, external_url, thumbnail_url) VALUES
+    (1, 2, 'http://example.com/video.mp4', 'http://example.com/poster.gif')`)
+	DB.Exec("INSERT INTO tags (id, name) VALUES (1, 'used'), (2, 'unused'), (3, 'only_dangling')")
+	DB.Exec("INSERT INTO posts_tags (post_id, tag_id) VALUES (1, 1), (2, 5), (10, 3)")
+	DB.Exec("INSERT INTO posts_suggested_tags (post_id, tag_id) VALUES (1, 1), (11, 1)")
+
+	writeFile := func(name string, contents []byte) {
+		checkErrors(t, nil, ioutil.WriteFile(filepath.Join(folder, name), contents, 0644))
+	}
+	writeFile("photo_1.gif", gifContents)
+	writeFile("photo_1_thumb_256.jpg", []byte("thumbnail"))
+	writeFile("photo_3.gif", []byte{})
+	writeFile("video_1.mp4", []byte("video"))
+	writeFile("video_1_thumbnail.gif", gifContents)
+	writeFile("photo_42.jpg", []byte("orphan"))
+	writeFile("notes.txt", []byte("not a media file"))
+
+	return folder, func() {
+		viper.Set("media_folder", "./")
+		os.RemoveAll(folder)
+		teardown()
+	}
+}
+
+func issuesOfKind(report FsckReport, kind string) []FsckIssue {
+	issues := []FsckIssue{}
+	for _, issue := range report.Issues {
+		if issue.Kind == kind {
+			issues = append(issues, issue)
+		}
+	}
+	return issues
+}
+
+func TestFsckReport(t *testing.T) {
+	folder, teardown := setupFsck(t)
+	defer teardown()
+
+	report, err := repo.Fsck(FsckOptions{})
+	checkErrors(t, nil, err)
+
+	assert.Equal(t, 3, report.Photos)
+	assert.Equal(t, 1, report.Videos)
+	assert.Equal(t, 6, report.Files)
+	assert.Equal(t, len(report.Issues), report.Unresolved())
+
+	assert.Equal(t, []FsckIssue{
+		{Kind: IssueMissingFile, Table: "photos", ID: 2, PostID: 1, Path: filepath.Join(folder, "photo_2.gif"), URL: "http://example.com/missing.gif"},
+	}, issuesOfKind(report, IssueMissingFile))
+	assert.Equal(t, []FsckIssue{
+		{Kind: IssueEmptyFile, Table: "photos", ID: 3, PostID: 1, Path: filepath.Join(folder, "photo_3.gif"), URL: "http://example.com/empty.gif"},
+	}, issuesOfKind(report, IssueEmptyFile))
+	assert.Equal(t, []FsckIssue{
+		{Kind: IssueOrphanFile, Path: filepath.Join(folder, "photo_42.jpg")},
+	}, issuesOfKind(report, IssueOrphanFile))
+	assert.Equal(t, []FsckIssue{
+		{Kind: IssueEmptyPost, Table: "posts", ID: 3},
+	}, issuesOfKind(report, IssueEmptyPost))
+	assert.Equal(t, []FsckIssue{
+		{Kind: IssueDanglingPostTag, Table: "posts_tags", PostID: 2, TagID: 5},
+		{Kind: IssueDanglingPostTag, Table: "posts_tags", PostID: 10, TagID: 3},
+		{Kind: IssueDanglingPostTag, Table: "posts_suggested_tags", PostID: 11, TagID: 1},
+	}, issuesOfKind(report, IssueDanglingPostTag))
+	assert.Equal(t, []FsckIssue{
+		{Kind: IssueUnusedTag, Table: "tags", TagID: 2},
+		{Kind: IssueUnusedTag, Table: "tags", TagID: 3},
+	}, issuesOfKind(report, IssueUnusedTag))
+
+	// nothing is changed without repair
+	_, err = os.Stat(filepath.Join(folder, "photo_42.jpg"))
+	assert.Nil(t, err)
+	var count int
+	DB.Get(&count, "SELECT count(*) FROM posts_tags")
+	assert.Equal(t, 3, count)
+}
+
+func TestFsckRepair(t *testing.T) {
+	folder, teardown := setupFsck(t)
+	defer teardown()
+
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	httpmock.RegisterResponder("GET", "http://example.com/missing.gif", httpmock.NewBytesResponder(200, gifContents))
+	httpmock.RegisterResponder("GET", "http://example.com/empty.gif", httpmock.NewBytesResponder(404, []byte{}))
+
+	quarantine := filepath.Join(folder, "quarantine")
+	report, err := repo.Fsck(FsckOptions{Repair: true, QuarantineFolder: quarantine})
+	checkErrors(t, nil, err)
+
+	missing := issuesOfKind(report, IssueMissingFile)
+	if assert.Len(t, missing, 1) {
+		assert.True(t, missing[0].Repaired)
+	}
+	contents, _ := ioutil.ReadFile(filepath.Join(folder, "photo_2.gif"))
+	assert.Equal(t, gifContents, contents)
+	var mimeType string
+	DB.Get(&mimeType, "SELECT mime_type FROM photos WHERE id = 2")
+	assert.Equal(t, "image/gif", mimeType)
+
+	empty := issuesOfKind(report, IssueEmptyFile)
+	if assert.Len(t, empty, 1) {
+		assert.False(t, empty[0].Repaired)
+		assert.Contains(t, empty[0].Error, "404")
+	}
+
+	orphans := issuesOfKind(report, IssueOrphanFile)
+	if assert.Len(t, orphans, 1) {
+		assert.True(t, orphans[0].Repaired)
+	}
+	_, err = os.Stat(filepath.Join(folder, "photo_42.jpg"))
+	assert.True(t, os.IsNotExist(err))
+	contents, _ = ioutil.ReadFile(filepath.Join(quarantine, "photo_42.jpg"))
+	assert.Equal(t, "orphan", string(contents))
+	_, err = os.Stat(filepath.Join(folder, "notes.txt"))
+	assert.Nil(t, err)
+
+	var count int
+	DB.Get(&count, "SELECT count(*) FROM posts_tags")
+	assert.Equal(t, 1, count)
+	DB.Get(&count, "SELECT count(*) FROM posts_suggested_tags")
+	assert.Equal(t, 1, count)
+
+	// posts and tags are only reported
+	assert.Len(t, issuesOfKind(report, IssueEmptyPost), 1)
+	assert.Len(t, issuesOfKind(report, IssueUnusedTag), 2)
+	DB.Get(&count, "SELECT count(*) FROM tags")
+	assert.Equal(t, 3, count)
+	assert.Equal(t, 4, report.Unresolved())
+
+	// orphans are deleted without quarantine
+	ioutil.WriteFile(filepath.Join(folder, "video_7.mp4"), []byte("orphan"), 0644)
+	report, err = repo.Fsck(FsckOptions{Repair: true})
+	checkErrors(t, nil, err)
+	assert.Len(t, issuesOfKind(report, IssueOrphanFile), 1)
+	_, err = os.Stat(filepath.Join(folder, "video_7.mp4"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestFsckReportsOrphanAvatars(t *testing.T) {
+	folder, teardown := setupFsck(t)
+	defer teardown()
+
+	DB.Exec("INSERT INTO subscriptions (id, blog_name, avatar_mime_type) VALUES (1, 'blog', 'image/png'), (2, 'new', '')")
+	ioutil.WriteFile(filepath.Join(folder, "avatar_1.png"), []byte("avatar"), 0644)
+	ioutil.WriteFile(filepath.Join(folder, "avatar_2.png"), []byte("stale avatar"), 0644)
+	ioutil.WriteFile(filepath.Join(folder, "avatar_3.png"), []byte("removed subscription"), 0644)
+
+	report, err := repo.Fsck(FsckOptions{})
+	checkErrors(t, nil, err)
+	assert.Equal(t, 9, report.Files)
+	assert.Equal(t, []FsckIssue{
+		{Kind: IssueOrphanFile, Path: filepath.Join(folder, "avatar_2.png")},
+		{Kind: IssueOrphanFile, Path: filepath.Join(folder, "avatar_3.png")},
+		{Kind: IssueOrphanFile, Path: filepath.Join(folder, "photo_42.jpg")},
+	}, issuesOfKind(report, IssueOrphanFile))
+}
+
+func TestFsckQuarantineKeepsFilesSealed(t *testing.T) {
+	folder, teardown := setupFsck(t)
+	defer teardown()
+
+	keys := &Keyring{Current: testKey(1)}
+	encryptedRepo := NewEncryptedRepository(DB, NewLocalStore(folder), Encryption{Keys: keys})
+	store := &EncryptedStore{NewLocalStore(folder), keys}
+	checkErrors(t, nil, store.Put("photo_43.jpg", bytes.NewReader([]byte("private orphan"))))
+	sealed, _ := ioutil.ReadFile(filepath.Join(folder, "photo_43.jpg"))
+
+	quarantine := filepath.Join(folder, "quarantine")
+	_, err := encryptedRepo.Fsck(FsckOptions{Repair: true, QuarantineFolder: quarantine})
+	checkErrors(t, nil, err)
+
+	contents, _ := ioutil.ReadFile(filepath.Join(quarantine, "photo_43.jpg"))
+	assert.Equal(t, sealed, contents)
+	_, err = os.Stat(filepath.Join(folder, "photo_43.jpg"))
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,150 @@
+package media
+
+import (
+	"bytes"
+	"database/sql"
+	"image"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	// image decoders for metadata extraction
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+)
+
+// analyzePhoto reads downloaded photo file and stores its metadata:
+// size, detected type, dimensions, perceptual hash and EXIF fields.
+// Files that are not decodable images get only size and type.
+func (r mediaRepo) analyzePhoto(photo *Photo) error {
+	name := photo.FileName()
+	file, err := r.Store.Open(name)
+	if err != nil {
+		return err
+	}
+	data, err := ioutil.ReadAll(file)
+	file.Close()
+	if err != nil {
+		return err
+	}
+	photo.ByteSize = int64(len(data))
+	// type is normally sniffed on download, file is renamed if it's detected only now
+	if photo.MimeType == "" {
+		photo.MimeType = detectMimeType(data)
+		if photo.FileName() != name {
+			err = moveFile(r.Store, name, photo.FileName())
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err == nil {
+		photo.Width = config.Width
+		photo.Height = config.Height
+		if img, _, err := image.Decode(bytes.NewReader(data)); err == nil {
+			photo.PHash = sql.NullInt64{Int64: int64(DifferenceHash(img)), Valid: true}
+		}
+	}
+	if format == "jpeg" {
+		if exif, err := parseJPEGExif(data); err == nil {
+			photo.Orientation = exif.Orientation
+			photo.CameraMake = exif.Make
+			photo.CameraModel = exif.Model
+			photo.TakenAt = exif.TakenAt
+		}
+	}
+
+	photo.UpdatedAt = time.Now()
+	_, err = r.DB.NamedExec(
+		`UPDATE photos SET
+			updated_at = :updated_at, phash = :phash, width = :width, height = :height,
+			mime_type = :mime_type, byte_size = :byte_size, orientation = :orientation,
+			camera_make = :camera_make, camera_model = :camera_model, taken_at = :taken_at
+		WHERE id = :id`,
+		photo,
+	)
+	return err
+}
+
+func (r mediaRepo) AnalyzePhotos() (int, error) {
+	var photos []Photo
+	err := r.DB.Select(
+		&photos,
+		"SELECT id, post_id, external_url, width, height, mime_type FROM photos WHERE byte_size = 0 AND "+livePosts,
+	)
+	if err != nil {
+		return 0, err
+	}
+	analyzed := 0
+	for i := range photos {
+		if r.analyzePhoto(&photos[i]) == nil {
+			analyzed++
+		}
+	}
+	return analyzed, nil
+}
+
+// PhotoFilter describes conditions photos are searched by, zero values are ignored
+type PhotoFilter struct {
+	MinWidth          int
+	MinHeight         int
+	Orientation       string // landscape, portrait or square
+	MimeType          string
+	CameraModel       string
+	ExtensionMismatch bool // only photos which file extension doesn't match detected type
+}
+
+func (r mediaRepo) FindPhotos(filter PhotoFilter) ([]Photo, error) {
+	conditions := []string{livePosts}
+	args := []interface{}{}
+	if filter.MinWidth > 0 {
+		conditions = append(conditions, "width >= ?")
+		args = append(args, filter.MinWidth)
+	}
+	if filter.MinHeight > 0 {
+		conditions = append(conditions, "height >= ?")
+		args = append(args, filter.MinHeight)
+	}
+	switch filter.Orientation {
+	case "landscape":
+		conditions = append(conditions, "width > height")
+	case "portrait":
+		conditions = append(conditions, "width < height")
+	case "square":
+		conditions = append(conditions, "width = height AND width > 0")
+	}
+	if filter.MimeType != "" {
+		conditions = append(conditions, "mime_type = ?")
+		args = append(args, filter.MimeType)
+	}
+	if filter.CameraModel != "" {
+		conditions = append(conditions, "camera_model = ?")
+		args = append(args, filter.CameraModel)
+	}
+
+	var photos []Photo
+	err := r.DB.Select(
+		&photos,
+		`SELECT id, created_at, updated_at, post_id, caption, external_url, sfw, phash, width, height,
+			mime_type, byte_size, orientation, camera_make, camera_model, taken_at
+		FROM photos WHERE `+strings.Join(conditions, " AND ")+" ORDER BY id",
+		args...,
+	)
+	if err == nil {
+		err = r.openCaptions(photos)
+	}
+	if err != nil || !filter.ExtensionMismatch {
+		return photos, err
+	}
+
+	var mismatched []Photo
+	for _, photo := range photos {
+		if photo.MimeType != "" && !extensionMatches(extension(photo.ExternalURL), photo.MimeType) {
+			mismatched = append(mismatched, photo)
+		}
+	}
+	return mismatched, nil
+}
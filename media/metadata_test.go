@@ -0,0 +1,98 @@
+package media
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	httpmock "gopkg.in/jarcoal/httpmock.v1"
+)
+
+func TestAddPhotoMetadata(t *testing.T) {
+	teardown := setup()
+	defer teardown()
+
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	tiff := buildTIFF(binary.LittleEndian, []testIFDEntry{
+		{exifTagModel, exifTypeASCII, "EOS 5D"},
+		{exifTagOrientation, exifTypeShort, uint16(1)},
+	}, "2016:07:12 18:30:05")
+	jpegContents := jpegWithExif(300, 200, tiff)
+
+	photo := Photo{ExternalURL: "http://example.com/landscape.jpg", PostID: 1}
+	httpmock.RegisterResponder("GET", photo.ExternalURL, httpmock.NewBytesResponder(200, jpegContents))
+
+	err := repo.AddPhoto(&photo)
+	checkErrors(t, nil, err)
+	defer removePhotoFiles(&photo)
+
+	photos, err := repo.FindPhotos(PhotoFilter{})
+	checkErrors(t, nil, err)
+	if assert.Equal(t, 1, len(photos)) {
+		dbPhoto := photos[0]
+		assert.Equal(t, 300, dbPhoto.Width)
+		assert.Equal(t, 200, dbPhoto.Height)
+		assert.Equal(t, "image/jpeg", dbPhoto.MimeType)
+		assert.Equal(t, int64(len(jpegContents)), dbPhoto.ByteSize)
+		assert.Equal(t, 1, dbPhoto.Orientation)
+		assert.Equal(t, "EOS 5D", dbPhoto.CameraModel)
+		assert.Equal(t, "", dbPhoto.CameraMake)
+		if assert.NotNil(t, dbPhoto.TakenAt) {
+			assert.True(t, time.Date(2016, 7, 12, 18, 30, 5, 0, time.UTC).Equal(*dbPhoto.TakenAt))
+		}
+		assert.True(t, dbPhoto.PHash.Valid)
+	}
+}
+
+func TestFindPhotos(t *testing.T) {
+	teardown := setup()
+	defer teardown()
+
+	photos := []Photo{
+		{ExternalURL: "http://example.com/1.jpg", Width: 2400, Height: 1600, MimeType: "image/jpeg", ByteSize: 10, CameraModel: "EOS 5D"},
+		{ExternalURL: "http://example.com/2.jpg", Width: 1600, Height: 2400, MimeType: "image/jpeg", ByteSize: 10},
+		{ExternalURL: "http://example.com/3.jpg", Width: 1000, Height: 500, MimeType: "image/png", ByteSize: 10},
+		{ExternalURL: "http://example.com/4.gif", Width: 500, Height: 500, MimeType: "image/gif", ByteSize: 10},
+		{ExternalURL: "http://example.com/5", Width: 0, Height: 0, MimeType: "", ByteSize: 0},
+	}
+	for _, photo := range photos {
+		photo.CreatedAt = time.Now()
+		photo.UpdatedAt = time.Now()
+		DB.NamedExec(
+			`INSERT INTO photos (
+				created_at, updated_at, post_id, caption, sfw, external_url, width, height, mime_type, byte_size, camera_model
+			)
+			VALUES (
+				:created_at, :updated_at, :post_id, :caption, :sfw, :external_url, :width, :height, :mime_type, :byte_size, :camera_model
+			)`,
+			photo,
+		)
+	}
+
+	testCases := []struct {
+		filter   PhotoFilter
+		expected []string
+	}{
+		{PhotoFilter{}, []string{"http://example.com/1.jpg", "http://example.com/2.jpg", "http://example.com/3.jpg", "http://example.com/4.gif", "http://example.com/5"}},
+		{PhotoFilter{MinWidth: 2000, Orientation: "landscape"}, []string{"http://example.com/1.jpg"}},
+		{PhotoFilter{MinHeight: 1000}, []string{"http://example.com/1.jpg", "http://example.com/2.jpg"}},
+		{PhotoFilter{Orientation: "portrait"}, []string{"http://example.com/2.jpg"}},
+		{PhotoFilter{Orientation: "square"}, []string{"http://example.com/4.gif"}},
+		{PhotoFilter{MimeType: "image/jpeg"}, []string{"http://example.com/1.jpg", "http://example.com/2.jpg"}},
+		{PhotoFilter{CameraModel: "EOS 5D"}, []string{"http://example.com/1.jpg"}},
+		{PhotoFilter{ExtensionMismatch: true}, []string{"http://example.com/3.jpg"}},
+	}
+
+	for _, testCase := range testCases {
+		found, err := repo.FindPhotos(testCase.filter)
+		checkErrors(t, nil, err)
+		var urls []string
+		for _, photo := range found {
+			urls = append(urls, photo.ExternalURL)
+		}
+		assert.Equal(t, testCase.expected, urls, "filter %#v", testCase.filter)
+	}
+}
@@ -0,0 +1,54 @@
+package media
+
+import (
+	"fmt"
+	"time"
+)
+
+// Mirror keeps progress of archiving all posts of a blog
+type Mirror struct {
+	BlogName     string     `db:"blog_name"`
+	NewestPostAt *time.Time `db:"newest_post_at"` // posts up to this time are archived, NULL before first complete run
+	SyncedAt     *time.Time `db:"synced_at"`      // time of the last complete run
+	Posts        int        // number of stored posts of the blog, filled by listing
+}
+
+// GetMirror finds progress of archiving the blog
+func (r mediaRepo) GetMirror(blogName string) (Mirror, error) {
+	var mirror Mirror
+	err := r.DB.Get(&mirror, "SELECT blog_name, newest_post_at, synced_at FROM mirrors WHERE blog_name = ?", blogName)
+	if err != nil {
+		return mirror, fmt.Errorf("mirror [%s] not found", blogName)
+	}
+	return mirror, nil
+}
+
+// SaveMirror creates or updates progress of archiving the blog
+func (r mediaRepo) SaveMirror(mirror *Mirror) error {
+	_, err := r.DB.NamedExec(
+		`INSERT OR REPLACE INTO mirrors (blog_name, newest_post_at, synced_at)
+		VALUES (:blog_name, :newest_post_at, :synced_at)`,
+		mirror,
+	)
+	return err
+}
+
+// ListMirrors returns all archived blogs with numbers of their stored posts ordered by name
+func (r mediaRepo) ListMirrors() ([]Mirror, error) {
+	var mirrors []Mirror
+	err := r.DB.Select(
+		&mirrors,
+		`SELECT mirrors.blog_name, mirrors.newest_post_at, mirrors.synced_at, count(posts.id) AS posts
+		FROM mirrors
+		LEFT JOIN posts ON posts.category = mirrors.blog_name AND posts.source = 'tumblr' AND posts.deleted_at IS NULL
+		GROUP BY mirrors.blog_name ORDER BY mirrors.blog_name`,
+	)
+	return mirrors, err
+}
+
+// MirrorsSchema represents schema for "mirrors" table
+var MirrorsSchema = `CREATE TABLE "mirrors" (
+	"blog_name" varchar(255) PRIMARY KEY,
+	"newest_post_at" datetime,
+	"synced_at" datetime
+)`
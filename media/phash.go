@@ -0,0 +1,192 @@
+package media
+
+import (
+	"fmt"
+	"image"
+	"math/bits"
+	"sort"
+	"time"
+)
+
+// DifferenceHash computes 64-bit perceptual difference hash (dHash) of the image.
+// Image is shrunk to 9x8 grayscale grid and every bit tells whether
+// brightness grows between two horizontally adjacent cells.
+func DifferenceHash(img image.Image) uint64 {
+	const width, height = 9, 8
+	grid := shrinkToGray(img, width, height)
+	var hash uint64
+	for y := 0; y < height; y++ {
+		for x := 0; x < width-1; x++ {
+			hash <<= 1
+			if grid[y*width+x] < grid[y*width+x+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// HammingDistance returns number of bits that differ in two hashes
+func HammingDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// shrinkToGray averages luminance of the image over width x height cells
+func shrinkToGray(img image.Image, width, height int) []uint32 {
+	bounds := img.Bounds()
+	grid := make([]uint32, width*height)
+	for cy := 0; cy < height; cy++ {
+		y0 := bounds.Min.Y + cy*bounds.Dy()/height
+		y1 := bounds.Min.Y + (cy+1)*bounds.Dy()/height
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for cx := 0; cx < width; cx++ {
+			x0 := bounds.Min.X + cx*bounds.Dx()/width
+			x1 := bounds.Min.X + (cx+1)*bounds.Dx()/width
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+			var sum, count uint64
+			for y := y0; y < y1 && y < bounds.Max.Y; y++ {
+				for x := x0; x < x1 && x < bounds.Max.X; x++ {
+					r, g, b, _ := img.At(x, y).RGBA()
+					sum += (299*uint64(r) + 587*uint64(g) + 114*uint64(b)) / 1000
+					count++
+				}
+			}
+			if count > 0 {
+				grid[cy*width+cx] = uint32(sum / count)
+			}
+		}
+	}
+	return grid
+}
+
+func (r mediaRepo) ListFingerprintedPhotos() ([]Photo, error) {
+	var photos []Photo
+	err := r.DB.Select(
+		&photos,
+		`SELECT id, created_at, updated_at, post_id, caption, external_url, sfw, phash, width, height, mime_type
+		FROM photos WHERE phash IS NOT NULL AND `+livePosts+` ORDER BY id`,
+	)
+	if err != nil {
+		return photos, err
+	}
+	return photos, r.openCaptions(photos)
+}
+
+// ClusterDuplicates groups photos around the best copies: the highest resolution photo
+// left is a keeper and its cluster takes photos which hashes differ from the keeper's one
+// in at most maxDistance bits, so photos are never chained through their neighbours.
+// Photos of the keeper's post are never treated as its duplicates.
+// Only clusters with more than one photo are returned, the keeper goes first.
+func ClusterDuplicates(photos []Photo, maxDistance int) [][]Photo {
+	sorted := append([]Photo{}, photos...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Resolution() > sorted[j].Resolution()
+	})
+
+	clustered := make([]bool, len(sorted))
+	var clusters [][]Photo
+	for i, keep := range sorted {
+		if clustered[i] {
+			continue
+		}
+		cluster := []Photo{keep}
+		for j := i + 1; j < len(sorted); j++ {
+			if clustered[j] || sorted[j].PostID == keep.PostID {
+				continue
+			}
+			if HammingDistance(uint64(keep.PHash.Int64), uint64(sorted[j].PHash.Int64)) <= maxDistance {
+				cluster = append(cluster, sorted[j])
+				clustered[j] = true
+			}
+		}
+		if len(cluster) > 1 {
+			clustered[i] = true
+			clusters = append(clusters, cluster)
+		}
+	}
+	return clusters
+}
+
+// MergeDuplicatePhotos keeps one photo and removes its duplicates.
+// Tags (suggested ones too) of the duplicates' posts are merged onto the surviving post,
+// posts left without photos are purged like posts removed from the trash.
+func (r mediaRepo) MergeDuplicatePhotos(keep Photo, duplicates []Photo) error {
+	for _, duplicate := range duplicates {
+		if duplicate.PostID == keep.PostID {
+			return fmt.Errorf("photo [%d] is of the same post [%d] as photo [%d]", duplicate.ID, keep.PostID, keep.ID)
+		}
+	}
+	tx, err := r.DB.Beginx()
+	if err != nil {
+		return err
+	}
+	var removedFiles []string
+	for _, duplicate := range duplicates {
+		for _, table := range []string{"posts_tags", "posts_suggested_tags"} {
+			_, err = tx.Exec(fmt.Sprintf(
+				`INSERT OR IGNORE INTO %[1]s (post_id, tag_id)
+				SELECT ?, tag_id FROM %[1]s WHERE post_id = ?`, table),
+				keep.PostID, duplicate.PostID,
+			)
+			if err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+		_, err = tx.Exec("DELETE FROM photos WHERE id = ?", duplicate.ID)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		removedFiles = append(removedFiles, duplicate.FileName())
+		for _, size := range thumbnailSizes() {
+			removedFiles = append(removedFiles, duplicate.ThumbnailFileName(size))
+		}
+
+		var photosLeft int
+		err = tx.Get(&photosLeft, "SELECT count(*) FROM photos WHERE post_id = ?", duplicate.PostID)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		if photosLeft == 0 {
+			_, err = tx.Exec("DELETE FROM posts_tags WHERE post_id = ?", duplicate.PostID)
+			if err == nil {
+				_, err = tx.Exec("DELETE FROM posts_suggested_tags WHERE post_id = ?", duplicate.PostID)
+			}
+			if err == nil {
+				// the surviving post takes place of the removed one in collections
+				_, err = tx.Exec(
+					"UPDATE OR IGNORE collections_posts SET post_id = ? WHERE post_id = ?",
+					keep.PostID, duplicate.PostID,
+				)
+			}
+			if err == nil {
+				_, err = tx.Exec("DELETE FROM collections_posts WHERE post_id = ?", duplicate.PostID)
+			}
+			if err == nil {
+				// the row is kept so that sync doesn't import the post again
+				_, err = tx.Exec(
+					"UPDATE posts SET status = ?, deleted_at = ? WHERE id = ?",
+					postStatusPurged, time.Now(), duplicate.PostID,
+				)
+			}
+			if err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+	}
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+	for _, name := range removedFiles {
+		r.Store.Delete(name)
+	}
+	return nil
+}
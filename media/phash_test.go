@@ -0,0 +1,191 @@
+package media
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	httpmock "gopkg.in/jarcoal/httpmock.v1"
+)
+
+// testImage draws diagonal gradient with a dark square in the top left corner
+func testImage(width, height int, inverted bool) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			value := uint8((x*255/width + y*255/height) / 2)
+			if x < width/3 && y < height/3 {
+				value = 10
+			}
+			if inverted {
+				value = 255 - value
+			}
+			img.Set(x, y, color.RGBA{value, value, value, 255})
+		}
+	}
+	return img
+}
+
+func encodePNG(img image.Image) []byte {
+	var buf bytes.Buffer
+	png.Encode(&buf, img)
+	return buf.Bytes()
+}
+
+func TestDifferenceHash(t *testing.T) {
+	original := DifferenceHash(testImage(400, 300, false))
+
+	testCases := []struct {
+		img         image.Image
+		maxDistance int
+		minDistance int
+	}{
+		{testImage(400, 300, false), 0, 0},
+		{testImage(200, 150, false), 4, 0},
+		{testImage(1280, 960, false), 4, 0},
+		{testImage(400, 300, true), 64, 20},
+	}
+
+	for _, testCase := range testCases {
+		distance := HammingDistance(original, DifferenceHash(testCase.img))
+		if distance > testCase.maxDistance || distance < testCase.minDistance {
+			t.Errorf("Expected distance for %v image to be in [%d, %d], got [%d]",
+				testCase.img.Bounds(), testCase.minDistance, testCase.maxDistance, distance)
+		}
+	}
+}
+
+func TestHammingDistance(t *testing.T) {
+	assert.Equal(t, 0, HammingDistance(0xff00, 0xff00))
+	assert.Equal(t, 8, HammingDistance(0xff00, 0x0000))
+	assert.Equal(t, 64, HammingDistance(0, ^uint64(0)))
+}
+
+func TestClusterDuplicates(t *testing.T) {
+	hash := func(value uint64) sql.NullInt64 {
+		return sql.NullInt64{Int64: int64(value), Valid: true}
+	}
+	photos := []Photo{
+		{ID: 1, PostID: 1, PHash: hash(0xf0f0), Width: 500, Height: 400},
+		{ID: 2, PostID: 2, PHash: hash(0xf0f1), Width: 1280, Height: 1024},
+		{ID: 3, PostID: 3, PHash: hash(0x0f0f0000), Width: 1280, Height: 1024},
+		{ID: 4, PostID: 4, PHash: hash(0xf0f3), Width: 100, Height: 100},
+		{ID: 5, PostID: 3, PHash: hash(0x0f0f0001), Width: 1280, Height: 1024},
+	}
+
+	clusters := ClusterDuplicates(photos, 2)
+
+	assert.Equal(t, 1, len(clusters))
+	assert.Equal(t, 3, len(clusters[0]))
+	assert.Equal(t, uint(2), clusters[0][0].ID, "highest resolution copy should go first")
+	assert.Equal(t, uint(1), clusters[0][1].ID)
+	assert.Equal(t, uint(4), clusters[0][2].ID)
+
+	assert.Equal(t, 0, len(ClusterDuplicates(photos, 0)))
+}
+
+func TestClusterDuplicatesIsNotTransitive(t *testing.T) {
+	hash := func(value uint64) sql.NullInt64 {
+		return sql.NullInt64{Int64: int64(value), Valid: true}
+	}
+	// A ~ B ~ C ~ D, A and C are of the same post, D is close to B and C but far from A
+	photos := []Photo{
+		{ID: 1, PostID: 1, PHash: hash(0x00), Width: 1280, Height: 1024},
+		{ID: 2, PostID: 2, PHash: hash(0x03), Width: 640, Height: 512},
+		{ID: 3, PostID: 1, PHash: hash(0x0f), Width: 500, Height: 400},
+		{ID: 4, PostID: 4, PHash: hash(0x07), Width: 100, Height: 100},
+	}
+
+	clusters := ClusterDuplicates(photos, 2)
+
+	if assert.Equal(t, 2, len(clusters)) {
+		assert.Equal(t, []uint{1, 2}, []uint{clusters[0][0].ID, clusters[0][1].ID})
+		assert.Equal(t, []uint{3, 4}, []uint{clusters[1][0].ID, clusters[1][1].ID})
+	}
+	for _, cluster := range clusters {
+		for _, photo := range cluster[1:] {
+			assert.NotEqual(t, cluster[0].PostID, photo.PostID)
+			assert.True(t, HammingDistance(uint64(cluster[0].PHash.Int64), uint64(photo.PHash.Int64)) <= 2)
+		}
+	}
+}
+
+func TestMergeDuplicatePhotos(t *testing.T) {
+	teardown := setup()
+	defer teardown()
+
+	keepPost := &Post{ExternalID: "1", Type: "photo"}
+	duplicatePost := &Post{ExternalID: "2", Type: "photo"}
+	photosetPost := &Post{ExternalID: "3", Type: "photo"}
+	for _, post := range []*Post{keepPost, duplicatePost, photosetPost} {
+		repo.AddPost(post)
+	}
+	repo.AddTagToPost(keepPost, "cats")
+	repo.AddTagToPost(duplicatePost, "cats")
+	repo.AddTagToPost(duplicatePost, "kittens")
+	repo.AddTagToPost(photosetPost, "photoset")
+
+	var photos []Photo
+	for _, postID := range []uint{keepPost.ID, duplicatePost.ID, photosetPost.ID, photosetPost.ID} {
+		res, _ := DB.Exec("INSERT INTO photos (post_id, external_url) VALUES (?, ?)", postID, "http://example.com/photo.jpg")
+		photoID, _ := res.LastInsertId()
+		photos = append(photos, Photo{ID: uint(photoID), PostID: postID, ExternalURL: "http://example.com/photo.jpg"})
+	}
+
+	err := repo.MergeDuplicatePhotos(photos[2], []Photo{photos[1], photos[3]})
+	checkErrors(t, fmt.Errorf("photo [%d] is of the same post [%d] as photo [%d]", photos[3].ID, photosetPost.ID, photos[2].ID), err)
+
+	err = repo.MergeDuplicatePhotos(photos[0], []Photo{photos[1], photos[2]})
+	checkErrors(t, nil, err)
+
+	var photosCount int
+	DB.Get(&photosCount, "SELECT count(*) FROM photos")
+	assert.Equal(t, 2, photosCount)
+
+	checkPostHasTag(t, keepPost, "cats")
+	checkPostHasTag(t, keepPost, "kittens")
+	checkPostHasTag(t, keepPost, "photoset")
+
+	assert.True(t, repo.PostExistsWithExternalID("2"), "post without photos should be kept for sync")
+	purged, err := repo.GetPost(duplicatePost.ID)
+	checkErrors(t, nil, err)
+	assert.Equal(t, postStatusPurged, purged.Status)
+	assert.NotNil(t, purged.DeletedAt)
+	assert.True(t, repo.PostExistsWithExternalID("3"), "post with remaining photos should be kept")
+	photoset, err := repo.GetPost(photosetPost.ID)
+	checkErrors(t, nil, err)
+	assert.Nil(t, photoset.DeletedAt)
+
+	var postsTagsCount int
+	DB.Get(&postsTagsCount, "SELECT count(*) FROM posts_tags WHERE post_id = ?", duplicatePost.ID)
+	assert.Equal(t, 0, postsTagsCount)
+}
+
+func TestAddPhotoFingerprint(t *testing.T) {
+	teardown := setup()
+	defer teardown()
+
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	photo := Photo{ExternalURL: "http://example.com/real.png", PostID: 1}
+	httpmock.RegisterResponder("GET", photo.ExternalURL,
+		httpmock.NewBytesResponder(200, encodePNG(testImage(64, 48, false))))
+
+	err := repo.AddPhoto(&photo)
+	checkErrors(t, nil, err)
+	defer removePhotoFiles(&photo)
+
+	photos, err := repo.ListFingerprintedPhotos()
+	checkErrors(t, nil, err)
+	assert.Equal(t, 1, len(photos))
+	assert.Equal(t, photo.ID, photos[0].ID)
+	assert.Equal(t, 64, photos[0].Width)
+	assert.Equal(t, 48, photos[0].Height)
+	assert.Equal(t, int64(DifferenceHash(testImage(64, 48, false))), photos[0].PHash.Int64)
+}
@@ -1,25 +1,31 @@
 package media
 
 import (
+	"database/sql"
 	"fmt"
-	"github.com/spf13/viper"
-	"path/filepath"
 	"time"
 )
 
 func (r mediaRepo) AddPhoto(photo *Photo) error {
 	trx := mediaTransaction{
+		store: r.Store,
 		insertCallback: func() error {
 			photo.CreatedAt = time.Now()
 			photo.UpdatedAt = time.Now()
+			record := *photo
+			caption, err := r.sealColumn(photo.Caption)
+			if err != nil {
+				return err
+			}
+			record.Caption = caption
 			res, err := r.DB.NamedExec(
 				`INSERT INTO photos (
-					created_at, updated_at, post_id, caption, external_url, sfw
+					created_at, updated_at, post_id, caption, external_url, sfw, width, height
 				)
 				VALUES (
-					:created_at, :updated_at, :post_id, :caption, :external_url, :sfw
+					:created_at, :updated_at, :post_id, :caption, :external_url, :sfw, :width, :height
 				)`,
-				photo,
+				record,
 			)
 			if err != nil {
 				return err
@@ -31,22 +37,38 @@ func (r mediaRepo) AddPhoto(photo *Photo) error {
 			photo.ID = uint(photoID)
 			return nil
 		},
+		rollbackCallback: func() error {
+			_, err := r.DB.Exec("DELETE FROM photos WHERE id = ?", photo.ID)
+			return err
+		},
 	}
 	trx.validateUrls([]string{photo.ExternalURL})
 	trx.save()
 	trx.downloadAll([]downloadTask{
-		downloadTask{url: photo.ExternalURL, localPath: r.GetPhotoPath(photo)},
+		downloadTask{
+			url:      photo.ExternalURL,
+			name:     func() string { return photo.FileName() },
+			mimeType: &photo.MimeType,
+		},
 	})
+	if trx.err == nil {
+		_, trx.err = r.DB.Exec("UPDATE photos SET mime_type = ? WHERE id = ?", photo.MimeType, photo.ID)
+	}
+	if trx.err == nil {
+		// not every downloaded file is an image we can decode
+		r.analyzePhoto(photo)
+		r.generatePhotoThumbnails(photo)
+	}
 	return trx.err
 }
 
 func (r mediaRepo) GetPhotoPath(photo *Photo) string {
-	return filepath.Join(viper.GetString("media_folder"), photo.FileName())
+	return r.Store.Location(photo.FileName())
 }
 
 // FileName returns local file name where current photo (should be) stored
 func (photo Photo) FileName() string {
-	extension := extension(photo.ExternalURL)
+	extension := fileExtension(photo.MimeType, photo.ExternalURL)
 	return fmt.Sprintf("photo_%d%s", photo.ID, extension)
 }
 
@@ -60,6 +82,21 @@ type Photo struct {
 	Caption     string
 	ExternalURL string `db:"external_url"`
 	SFW         bool
+
+	PHash       sql.NullInt64 `db:"phash"` // perceptual difference hash, NULL until photo is decoded
+	Width       int
+	Height      int
+	MimeType    string     `db:"mime_type"` // type detected on download, defines file extension
+	ByteSize    int64      `db:"byte_size"` // file size, 0 until photo is analyzed
+	Orientation int        // EXIF orientation, 0 when unknown
+	CameraMake  string     `db:"camera_make"`
+	CameraModel string     `db:"camera_model"`
+	TakenAt     *time.Time `db:"taken_at"` // EXIF original date
+}
+
+// Resolution returns number of pixels in the photo
+func (photo Photo) Resolution() int {
+	return photo.Width * photo.Height
 }
 
 // PhotosSchema represents schema for "photos" table
@@ -70,5 +107,27 @@ var PhotosSchema = `CREATE TABLE "photos" (
 	"post_id" integer,
 	"caption" varchar(255),
 	"external_url" varchar(255),
-	"sfw" bool
+	"sfw" bool,
+	"phash" integer,
+	"width" integer DEFAULT 0,
+	"height" integer DEFAULT 0,
+	"mime_type" varchar(255) DEFAULT '',
+	"byte_size" integer DEFAULT 0,
+	"orientation" integer DEFAULT 0,
+	"camera_make" varchar(255) DEFAULT '',
+	"camera_model" varchar(255) DEFAULT '',
+	"taken_at" datetime
 )`
+
+// PhotosMigrations adds columns missing in "photos" tables created by older versions
+var PhotosMigrations = []string{
+	`ALTER TABLE "photos" ADD COLUMN "phash" integer`,
+	`ALTER TABLE "photos" ADD COLUMN "width" integer DEFAULT 0`,
+	`ALTER TABLE "photos" ADD COLUMN "height" integer DEFAULT 0`,
+	`ALTER TABLE "photos" ADD COLUMN "mime_type" varchar(255) DEFAULT ''`,
+	`ALTER TABLE "photos" ADD COLUMN "byte_size" integer DEFAULT 0`,
+	`ALTER TABLE "photos" ADD COLUMN "orientation" integer DEFAULT 0`,
+	`ALTER TABLE "photos" ADD COLUMN "camera_make" varchar(255) DEFAULT ''`,
+	`ALTER TABLE "photos" ADD COLUMN "camera_model" varchar(255) DEFAULT ''`,
+	`ALTER TABLE "photos" ADD COLUMN "taken_at" datetime`,
+}
@@ -0,0 +1,32 @@
+package media
+
+// PostMergePolicy tells which fields of stored post are refreshed from the source
+// when the post is synced again, other fields (status, SFW flag, category...) keep local values
+type PostMergePolicy struct {
+	Likes   bool // note count
+	Summary bool
+	URLs    bool // external and source URLs, source blog
+}
+
+// DefaultPostMergePolicy refreshes everything the source could change after the post was imported
+var DefaultPostMergePolicy = PostMergePolicy{Likes: true, Summary: true, URLs: true}
+
+// Merge copies refreshed fields of fresh post to the stored one, returns names of changed fields
+func (p PostMergePolicy) Merge(stored *Post, fresh Post) []string {
+	changed := []string{}
+	merge := func(name string, refresh bool, local *string, source string) {
+		if refresh && *local != source {
+			*local = source
+			changed = append(changed, name)
+		}
+	}
+	if p.Likes && stored.Likes != fresh.Likes {
+		stored.Likes = fresh.Likes
+		changed = append(changed, "likes")
+	}
+	merge("summary", p.Summary, &stored.Summary, fresh.Summary)
+	merge("external_url", p.URLs, &stored.ExternalURL, fresh.ExternalURL)
+	merge("source_url", p.URLs, &stored.SourceURL, fresh.SourceURL)
+	merge("source_category", p.URLs, &stored.SourceCategory, fresh.SourceCategory)
+	return changed
+}
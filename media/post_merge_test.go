@@ -0,0 +1,34 @@
+package media
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergePost(t *testing.T) {
+	stored := Post{
+		Status: "added", SFW: true, Category: "blog", Likes: 3, Summary: "old",
+		ExternalURL: "http://blog.com/1", SourceURL: "http://source.com/1",
+	}
+	fresh := Post{
+		Status: "queued", SFW: false, Category: "renamed", Likes: 10, Summary: "new",
+		ExternalURL: "http://blog.com/1", SourceURL: "http://source.com/2", SourceCategory: "source",
+	}
+
+	merged := stored
+	changed := DefaultPostMergePolicy.Merge(&merged, fresh)
+	assert.Equal(t, []string{"likes", "summary", "source_url", "source_category"}, changed)
+	assert.Equal(t, Post{
+		Status: "added", SFW: true, Category: "blog", Likes: 10, Summary: "new",
+		ExternalURL: "http://blog.com/1", SourceURL: "http://source.com/2", SourceCategory: "source",
+	}, merged)
+
+	merged = stored
+	changed = PostMergePolicy{Likes: true}.Merge(&merged, fresh)
+	assert.Equal(t, []string{"likes"}, changed)
+	assert.Equal(t, "old", merged.Summary)
+
+	changed = DefaultPostMergePolicy.Merge(&merged, merged)
+	assert.Empty(t, changed)
+}
@@ -1,6 +1,7 @@
 package media
 
 import (
+	"fmt"
 	"time"
 )
 
@@ -10,16 +11,56 @@ func (r mediaRepo) PostExistsWithExternalID(externalID string) bool {
 	return res > 0
 }
 
+// GetPost returns post by its ID
+func (r mediaRepo) GetPost(id uint) (Post, error) {
+	var post Post
+	err := r.DB.Get(&post, "SELECT * FROM posts WHERE id = ?", id)
+	if err != nil {
+		return post, fmt.Errorf("post [%d] not found", id)
+	}
+	return post, nil
+}
+
+// GetPostByExternalID returns post by its ID in the external domain
+func (r mediaRepo) GetPostByExternalID(externalID string) (Post, error) {
+	var post Post
+	err := r.DB.Get(&post, "SELECT * FROM posts WHERE external_id = ?", externalID)
+	if err != nil {
+		return post, fmt.Errorf("post with external id [%s] not found", externalID)
+	}
+	return post, nil
+}
+
+// UpdatePost saves fields of stored post, deletion mark is changed only through the trash
+func (r mediaRepo) UpdatePost(post *Post) error {
+	post.UpdatedAt = time.Now()
+	res, err := r.DB.NamedExec(
+		`UPDATE posts SET
+       updated_at = :updated_at, status = :status, sfw = :sfw, source = :source, type = :type,
+       released_at = :released_at, category = :category, external_url = :external_url,
+       source_url = :source_url, source_category = :source_category, likes = :likes, summary = :summary
+     WHERE id = :id`,
+		post,
+	)
+	if err != nil {
+		return err
+	}
+	if updated, _ := res.RowsAffected(); updated == 0 {
+		return fmt.Errorf("post [%d] not found", post.ID)
+	}
+	return nil
+}
+
 func (r mediaRepo) AddPost(post *Post) error {
 	post.CreatedAt = time.Now()
 	post.UpdatedAt = time.Now()
 	res, err := r.DB.NamedExec(
 		`INSERT INTO posts (
        created_at, updated_at, status, sfw, source, type, released_at, category, external_id,
-       external_url, source_url, source_category, likes, summary)
+       external_url, source_url, source_category, likes, summary, account, blog)
      VALUES (
        :created_at, :updated_at, :status, :sfw, :source, :type, :released_at, :category,
-       :external_id, :external_url, :source_url, :source_category, :likes, :summary
+       :external_id, :external_url, :source_url, :source_category, :likes, :summary, :account, :blog
      )`,
 		post,
 	)
@@ -53,6 +94,11 @@ type Post struct {
 	SourceCategory string `db:"source_category"` // original post author
 	Likes          int    // number of likes
 	Summary        string // caption to the post
+
+	DeletedAt *time.Time `db:"deleted_at"` // when post was moved to the trash, NULL for live posts
+
+	Account string // account the post was synced with
+	Blog    string // blog the post was synced from, empty for likes and feeds
 }
 
 // PostsSchema represents schema for "posts" table
@@ -71,5 +117,15 @@ var PostsSchema = `CREATE TABLE "posts" (
 	"source_url" varchar(255),
 	"source_category" varchar(255),
 	"likes" integer,
-	"summary" varchar(255)
+	"summary" varchar(255),
+	"deleted_at" datetime,
+	"account" varchar(255) DEFAULT '',
+	"blog" varchar(255) DEFAULT ''
 )`
+
+// PostsMigrations adds columns missing in "posts" tables created by older versions
+var PostsMigrations = []string{
+	`ALTER TABLE "posts" ADD COLUMN "deleted_at" datetime`,
+	`ALTER TABLE "posts" ADD COLUMN "account" varchar(255) DEFAULT ''`,
+	`ALTER TABLE "posts" ADD COLUMN "blog" varchar(255) DEFAULT ''`,
+}
@@ -75,3 +75,32 @@ func TestPostExistsWithExternalID(t *testing.T) {
 		}
 	}
 }
+
+func TestUpdatePost(t *testing.T) {
+	teardown := setup()
+	defer teardown()
+
+	post := Post{ExternalID: "5", Status: "queued", Likes: 3, Summary: "old"}
+	checkErrors(t, nil, repo.AddPost(&post))
+	createdAt := post.UpdatedAt
+
+	stored, err := repo.GetPostByExternalID("5")
+	checkErrors(t, nil, err)
+	assert.Equal(t, post.ID, stored.ID)
+	_, err = repo.GetPostByExternalID("6")
+	checkErrors(t, errors.New("post with external id [6] not found"), err)
+
+	stored.Likes = 10
+	stored.Summary = "new"
+	stored.Status = "added"
+	checkErrors(t, nil, repo.UpdatePost(&stored))
+	assert.True(t, stored.UpdatedAt.After(createdAt))
+
+	updated, err := repo.GetPost(post.ID)
+	checkErrors(t, nil, err)
+	assert.Equal(t, 10, updated.Likes)
+	assert.Equal(t, "new", updated.Summary)
+	assert.Equal(t, "added", updated.Status)
+
+	checkErrors(t, errors.New("post [42] not found"), repo.UpdatePost(&Post{ID: 42}))
+}
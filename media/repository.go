@@ -1,13 +1,23 @@
 package media
 
 import (
+	"time"
+
 	"github.com/jmoiron/sqlx"
+	"github.com/spf13/viper"
 )
 
-var tables = []string{PostsSchema, PhotosSchema, VideosSchema, TextsSchema, LinksSchema, TagsSchema, PostsTagsSchema, SubscriptionsSchema}
+var tables = []string{PostsSchema, PhotosSchema, VideosSchema, TextsSchema, LinksSchema, TagsSchema, PostsTagsSchema, PostsSuggestedTagsSchema, TagAliasesSchema, CollectionsSchema, CollectionsPostsSchema, SubscriptionsSchema, SubscriptionGroupsSchema, MirrorsSchema, SyncStateSchema}
+
+// migrations are applied on every start, statements that were already applied fail silently
+var migrations = [][]string{PostsMigrations, PhotosMigrations, VideosMigrations, SubscriptionsMigrations}
 
 type mediaRepo struct {
-	DB *sqlx.DB
+	DB    *sqlx.DB
+	Store Store
+
+	keys           *Keyring // decrypt sensitive columns when set
+	encryptColumns bool
 }
 
 // Repository represents objects that handles media objetcs persistence
@@ -20,20 +30,106 @@ type Repository interface {
 	AddSubscription(*Subscription) error
 	AddTag(*Tag) error
 	AddTagToPost(*Post, string) error
+	AddSuggestedTagToPost(*Post, string) error
+
+	ListTags() ([]TagCount, error)
+	ListPostTags(uint) ([]string, error)
+	ListPostAssignedTags(uint) ([]string, error)
+	ListPostSuggestedTags(uint) ([]string, error)
+	RenameTag(from, to string) error
+	MergeTags(target string, sources []string) error
+	DeleteTag(string) error
+	AddTagAlias(alias, tagName string) error
+	RemoveTagAlias(string) error
+	ListTagAliases() ([]TagAlias, error)
+	ResolveTag(string) string
+	NormalizeTags(TagNormalizer) ([]TagChange, error)
+	ListSuggestedTags() ([]TagCount, error)
+	ListQueuedPosts(suggestedTag string) ([]Post, error)
+	ApprovePost(uint) error
+
+	AddCollection(*Collection) error
+	GetCollection(string) (Collection, error)
+	ListCollections() ([]CollectionCount, error)
+	UpdateCollection(*Collection) error
+	DeleteCollection(string) error
+	AddPostToCollection(name string, postID uint) error
+	RemovePostFromCollection(name string, postID uint) error
+	MovePostInCollection(name string, postID uint, position int) error
+	ListCollectionPosts(string) ([]Post, error)
+	ExportCollections(folder, format string) (int, error)
 
 	ListSubscriptions() ([]Subscription, error)
+	ListInactiveSubscriptions(lastPostBefore time.Time) ([]Subscription, error)
+	UpdateSubscription(*Subscription) error
+	SetSubscriptionNotes(blogName, notes string) error
+	RemoveSubscription(uint) error
+	MarkSubscriptionsSynced(blogNames []string, at time.Time) error
+	RefreshSubscription(sub *Subscription, avatarURL string) error
+	GetSubscriptionAvatarPath(*Subscription) string
+	GetSubscription(string) (Subscription, error)
+	UpdateSubscriptionSettings(*Subscription) error
+	AddSubscriptionToGroup(blogName, group string) error
+	RemoveSubscriptionFromGroup(blogName, group string) error
+	ListSubscriptionGroups() ([]SubscriptionGroup, error)
+	ListGroupSubscriptions(string) ([]Subscription, error)
+	ListSubscriptionGroupNames(string) ([]string, error)
+
+	GetMirror(string) (Mirror, error)
+	SaveMirror(*Mirror) error
+	ListMirrors() ([]Mirror, error)
+	GetSyncState(string) (string, error)
+	SetSyncState(key, value string) error
+
 	GetPhotoPath(*Photo) string
 	GetVideoPath(*Video) string
 	GetVideoThumbnailPath(*Video) string
+	GetPhotoThumbnailPath(*Photo, int) string
+	GetVideoPosterPath(*Video, int) string
+	RebuildThumbnails() (int, int, error)
+	FixExtensions() (int, error)
+	Reencrypt() ([]string, int, error)
+	Fsck(FsckOptions) (FsckReport, error)
 	PostExistsWithExternalID(string) bool
+	GetPost(uint) (Post, error)
+	GetPostByExternalID(string) (Post, error)
+	UpdatePost(*Post) error
+	DeletePost(uint) error
+	RestorePost(uint) error
+	ListTrash() ([]Post, error)
+	EmptyTrash(deletedBefore time.Time) (int, error)
+
+	AnalyzePhotos() (int, error)
+	FindPhotos(PhotoFilter) ([]Photo, error)
+	ListFingerprintedPhotos() ([]Photo, error)
+	MergeDuplicatePhotos(keep Photo, duplicates []Photo) error
 
 	RemoveAllSubscriptions() error
 }
 
-// NewRepository initializes media repository object
+// NewRepository initializes media repository object keeping files in media folder
 func NewRepository(DB *sqlx.DB) Repository {
+	return NewRepositoryWithStore(DB, NewLocalStore(viper.GetString("media_folder")))
+}
+
+// NewRepositoryWithStore initializes media repository object keeping files in the store
+func NewRepositoryWithStore(DB *sqlx.DB, store Store) Repository {
 	for _, table := range tables {
 		DB.Exec(table)
 	}
-	return &mediaRepo{DB}
+	for _, statements := range migrations {
+		for _, statement := range statements {
+			DB.Exec(statement)
+		}
+	}
+	return &mediaRepo{DB: DB, Store: store}
+}
+
+// NewEncryptedRepository initializes media repository sealing files in the store
+// and, when configured, sensitive columns with encryption keys
+func NewEncryptedRepository(DB *sqlx.DB, store Store, encryption Encryption) Repository {
+	repo := NewRepositoryWithStore(DB, &EncryptedStore{store, encryption.Keys}).(*mediaRepo)
+	repo.keys = encryption.Keys
+	repo.encryptColumns = encryption.Columns
+	return repo
 }
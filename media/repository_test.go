@@ -26,6 +26,14 @@ func setup() func() {
 	}
 }
 
+// removePhotoFiles cleans up downloaded photo and its thumbnails
+func removePhotoFiles(photo *Photo) {
+	os.Remove(repo.GetPhotoPath(photo))
+	for _, size := range thumbnailSizes() {
+		os.Remove(repo.GetPhotoThumbnailPath(photo, size))
+	}
+}
+
 func checkErrors(t *testing.T, expectedErr, err error) {
 	if err == nil || expectedErr == nil {
 		if err != expectedErr {
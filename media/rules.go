@@ -0,0 +1,259 @@
+package media
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Rules are evaluated for every new post before it is stored, they are read from rules.yaml:
+//
+//	lists:
+//	  blocklist: [spamblog, adsblog]
+//	rules:
+//	  - name: nsfw
+//	    if:
+//	      any:
+//	        - blog: [x, y]
+//	        - tags: nsfw
+//	    then:
+//	      sfw: false
+//	  - name: art
+//	    if: {category: artblog}
+//	    then: {status: approved, add_tags: [art]}
+//	  - name: blocked videos
+//	    if: {type: video, blog: "@blocklist"}
+//	    then: {skip: true}
+//
+// All matching rules fire in order, later rules override earlier ones.
+type Rules struct {
+	Lists map[string]stringList
+	Rules []Rule
+}
+
+// Rule changes the post when its condition matches
+type Rule struct {
+	Name string
+	If   Condition
+	Then Action
+}
+
+// Condition matches post fields, all given fields should match.
+// Values of a field are alternatives, "@name" refers to a named list.
+type Condition struct {
+	Any []Condition
+	All []Condition
+	Not *Condition
+
+	Blog     stringList // blog name or source blog name
+	Category stringList
+	Source   stringList
+	Type     stringList
+	Tags     stringList // any of the tags, case-insensitive
+}
+
+// Action describes changes made by a rule
+type Action struct {
+	SFW     *bool `yaml:"sfw"`
+	Status  string
+	AddTags stringList `yaml:"add_tags"`
+	Skip    bool
+}
+
+// RulesResult is the outcome of rules evaluation
+type RulesResult struct {
+	Fired []string // names of matched rules
+	Tags  []string // post tags with tags added by rules
+	Skip  bool     // post should not be stored
+}
+
+// stringList is a YAML list of strings which could be written as a single string
+type stringList []string
+
+func (l *stringList) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*l = stringList{node.Value}
+		return nil
+	}
+	var values []string
+	err := node.Decode(&values)
+	*l = values
+	return err
+}
+
+// LoadRules reads rules file, missing file means there are no rules
+func LoadRules(path string) (*Rules, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ParseRules(data)
+}
+
+// ParseRules parses and validates rules in YAML
+func ParseRules(data []byte) (*Rules, error) {
+	rules := &Rules{}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	err := decoder.Decode(rules)
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("can't parse rules: %s", err)
+	}
+	for i := range rules.Rules {
+		rule := &rules.Rules[i]
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("rule #%d", i+1)
+		}
+		switch rule.Then.Status {
+		case "", "added", "queued":
+		case "approved":
+			// approved posts are stored as added ones
+			rule.Then.Status = "added"
+		default:
+			return nil, fmt.Errorf("%s: unknown status [%s]", rule.Name, rule.Then.Status)
+		}
+		err = rules.checkLists(rule.If)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", rule.Name, err)
+		}
+	}
+	return rules, nil
+}
+
+// checkLists makes sure that all named lists used by condition exist
+func (r *Rules) checkLists(condition Condition) error {
+	for _, values := range []stringList{condition.Blog, condition.Category, condition.Source, condition.Type, condition.Tags} {
+		for _, value := range values {
+			name := strings.TrimPrefix(value, "@")
+			if name != value && r.Lists[name] == nil {
+				return fmt.Errorf("unknown list [%s]", name)
+			}
+		}
+	}
+	nested := append(append([]Condition{}, condition.Any...), condition.All...)
+	if condition.Not != nil {
+		nested = append(nested, *condition.Not)
+	}
+	for _, condition := range nested {
+		if err := r.checkLists(condition); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Apply evaluates rules for the post with its tags and changes the post accordingly,
+// evaluation stops at the first rule which skips the post
+func (r *Rules) Apply(post *Post, tags []string) RulesResult {
+	result := RulesResult{Tags: append([]string{}, tags...)}
+	if r == nil {
+		return result
+	}
+	for _, rule := range r.Rules {
+		if !r.matches(rule.If, post, result.Tags) {
+			continue
+		}
+		result.Fired = append(result.Fired, rule.Name)
+		if rule.Then.Skip {
+			result.Skip = true
+			return result
+		}
+		if rule.Then.SFW != nil {
+			post.SFW = *rule.Then.SFW
+		}
+		if rule.Then.Status != "" {
+			post.Status = rule.Then.Status
+		}
+		for _, tag := range rule.Then.AddTags {
+			if !containsFold(result.Tags, tag) {
+				result.Tags = append(result.Tags, tag)
+			}
+		}
+	}
+	return result
+}
+
+func (r *Rules) matches(condition Condition, post *Post, tags []string) bool {
+	for _, nested := range condition.All {
+		if !r.matches(nested, post, tags) {
+			return false
+		}
+	}
+	if len(condition.Any) > 0 {
+		matched := false
+		for _, nested := range condition.Any {
+			matched = matched || r.matches(nested, post, tags)
+		}
+		if !matched {
+			return false
+		}
+	}
+	if condition.Not != nil && r.matches(*condition.Not, post, tags) {
+		return false
+	}
+
+	if len(condition.Blog) > 0 && !r.matchesOne(condition.Blog, post.Category, post.SourceCategory) {
+		return false
+	}
+	if len(condition.Category) > 0 && !r.matchesOne(condition.Category, post.Category) {
+		return false
+	}
+	if len(condition.Source) > 0 && !r.matchesOne(condition.Source, post.Source) {
+		return false
+	}
+	if len(condition.Type) > 0 && !r.matchesOne(condition.Type, post.Type) {
+		return false
+	}
+	if len(condition.Tags) > 0 {
+		matched := false
+		for _, value := range r.expand(condition.Tags) {
+			matched = matched || containsFold(tags, value)
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesOne tells whether any of the fields equals to any of the values
+func (r *Rules) matchesOne(values stringList, fields ...string) bool {
+	for _, value := range r.expand(values) {
+		for _, field := range fields {
+			if field != "" && field == value {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// expand replaces "@name" references with values of the named lists
+func (r *Rules) expand(values stringList) []string {
+	var expanded []string
+	for _, value := range values {
+		if strings.HasPrefix(value, "@") {
+			expanded = append(expanded, r.Lists[strings.TrimPrefix(value, "@")]...)
+		} else {
+			expanded = append(expanded, value)
+		}
+	}
+	return expanded
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
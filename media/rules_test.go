@@ -0,0 +1,95 @@
+package media
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testRules = `
+lists:
+  blocklist: [spamblog, adsblog]
+rules:
+  - name: nsfw
+    if:
+      any:
+        - blog: [x, y]
+        - tags: nsfw
+    then:
+      sfw: false
+  - name: safe art
+    if: {category: artblog, not: {tags: nsfw}}
+    then: {sfw: true, status: approved, add_tags: [art]}
+  - if: {type: video, blog: "@blocklist"}
+    then: {skip: true}
+`
+
+func TestApplyRules(t *testing.T) {
+	rules, err := ParseRules([]byte(testRules))
+	checkErrors(t, nil, err)
+
+	post := Post{Category: "artblog", Type: "photo", Status: "queued"}
+	result := rules.Apply(&post, []string{"painting"})
+	assert.Equal(t, RulesResult{Fired: []string{"safe art"}, Tags: []string{"painting", "art"}}, result)
+	assert.True(t, post.SFW)
+	assert.Equal(t, "added", post.Status)
+
+	post = Post{Category: "artblog", Type: "photo", Status: "queued", SFW: true}
+	result = rules.Apply(&post, []string{"NSFW", "art"})
+	assert.Equal(t, RulesResult{Fired: []string{"nsfw"}, Tags: []string{"NSFW", "art"}}, result)
+	assert.False(t, post.SFW)
+	assert.Equal(t, "queued", post.Status)
+
+	post = Post{Category: "reblogger", SourceCategory: "y", SFW: true}
+	result = rules.Apply(&post, nil)
+	assert.Equal(t, []string{"nsfw"}, result.Fired)
+	assert.False(t, post.SFW)
+
+	post = Post{Category: "adsblog", Type: "video"}
+	result = rules.Apply(&post, nil)
+	assert.Equal(t, []string{"rule #3"}, result.Fired)
+	assert.True(t, result.Skip)
+
+	post = Post{Category: "adsblog", Type: "photo", SFW: true}
+	result = rules.Apply(&post, []string{"cats"})
+	assert.Equal(t, RulesResult{Tags: []string{"cats"}}, result)
+	assert.True(t, post.SFW)
+
+	var noRules *Rules
+	assert.Equal(t, RulesResult{Tags: []string{"cats"}}, noRules.Apply(&post, []string{"cats"}))
+}
+
+func TestParseRulesErrors(t *testing.T) {
+	_, err := ParseRules([]byte("rules:\n  - then: {status: published}\n"))
+	checkErrors(t, errors.New("rule #1: unknown status [published]"), err)
+
+	_, err = ParseRules([]byte("rules:\n  - name: blocked\n    if: {any: [{blog: '@missing'}]}\n"))
+	checkErrors(t, errors.New("blocked: unknown list [missing]"), err)
+
+	_, err = ParseRules([]byte("rules:\n  - if: {color: red}\n"))
+	assert.Contains(t, err.Error(), "field color not found")
+
+	rules, err := ParseRules([]byte{})
+	checkErrors(t, nil, err)
+	assert.Empty(t, rules.Rules)
+}
+
+func TestLoadRules(t *testing.T) {
+	folder, err := ioutil.TempDir("", "bellboy_rules")
+	checkErrors(t, nil, err)
+	defer os.RemoveAll(folder)
+
+	rules, err := LoadRules(filepath.Join(folder, "rules.yaml"))
+	checkErrors(t, nil, err)
+	assert.Nil(t, rules)
+
+	ioutil.WriteFile(filepath.Join(folder, "rules.yaml"), []byte(testRules), 0644)
+	rules, err = LoadRules(filepath.Join(folder, "rules.yaml"))
+	checkErrors(t, nil, err)
+	assert.Len(t, rules.Rules, 3)
+	assert.Equal(t, stringList{"spamblog", "adsblog"}, rules.Lists["blocklist"])
+}
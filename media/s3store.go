@@ -0,0 +1,280 @@
+package media
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	s3Service       = "s3"
+	s3Algorithm     = "AWS4-HMAC-SHA256"
+	s3AmzDateFormat = "20060102T150405Z"
+)
+
+// S3Store keeps files in a bucket of S3-compatible service (AWS, MinIO).
+// Buckets are addressed by path, requests are signed with AWS Signature Version 4.
+type S3Store struct {
+	Endpoint  string // service URL like http://localhost:9000
+	Bucket    string
+	Region    string
+	AccessKey string
+	SecretKey string
+	Prefix    string       // prepended to names of all files in the bucket
+	Client    *http.Client // http.DefaultClient is used when empty
+}
+
+func (s *S3Store) Put(name string, contents io.Reader) error {
+	// request should be signed with payload hash and have known length,
+	// so contents are spooled to temporary file first
+	spool, err := ioutil.TempFile("", "bellboy_s3")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(spool, hash), contents)
+	if err != nil {
+		return err
+	}
+	_, err = spool.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.do("PUT", name, nil, spool, size, hex.EncodeToString(hash.Sum(nil)))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *S3Store) Open(name string) (io.ReadCloser, error) {
+	resp, err := s.do("GET", name, nil, nil, 0, emptyPayloadHash)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (s *S3Store) Stat(name string) (FileInfo, error) {
+	resp, err := s.do("HEAD", name, nil, nil, 0, emptyPayloadHash)
+	if err != nil {
+		return FileInfo{}, err
+	}
+	resp.Body.Close()
+	modTime, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+	return FileInfo{Name: name, Size: resp.ContentLength, ModTime: modTime}, nil
+}
+
+func (s *S3Store) Delete(name string) error {
+	resp, err := s.do("DELETE", name, nil, nil, 0, emptyPayloadHash)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// s3ListResult is a page of ListObjectsV2 response
+type s3ListResult struct {
+	IsTruncated           bool
+	NextContinuationToken string
+	Contents              []struct {
+		Key          string
+		Size         int64
+		LastModified time.Time
+	}
+}
+
+// List returns all files under the prefix, names are returned without it
+func (s *S3Store) List() ([]FileInfo, error) {
+	files := []FileInfo{}
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {s.Prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		resp, err := s.do("GET", "", query, nil, 0, emptyPayloadHash)
+		if err != nil {
+			return nil, err
+		}
+		var result s3ListResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, object := range result.Contents {
+			files = append(files, FileInfo{
+				Name:    strings.TrimPrefix(object.Key, s.Prefix),
+				Size:    object.Size,
+				ModTime: object.LastModified,
+			})
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return files, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+func (s *S3Store) Location(name string) string {
+	return s.objectURL(name)
+}
+
+// objectURL returns URL of the object, URL of the bucket for empty name
+func (s *S3Store) objectURL(name string) string {
+	path := "/" + s.Bucket + "/"
+	if name != "" {
+		path += s.Prefix + name
+	}
+	return strings.TrimRight(s.Endpoint, "/") + s3EscapePath(path)
+}
+
+// do sends signed request, missing objects are reported with os.ErrNotExist
+func (s *S3Store) do(method, name string, query url.Values, body io.Reader, size int64, payloadHash string) (*http.Response, error) {
+	req, err := http.NewRequest(method, s.objectURL(name), body)
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = size
+	if size == 0 {
+		req.Body = nil
+	}
+	req.URL.RawQuery = s3CanonicalQuery(query)
+	signV4(req, payloadHash, s.AccessKey, s.SecretKey, s.Region, time.Now())
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, &os.PathError{Op: strings.ToLower(method), Path: s.Location(name), Err: os.ErrNotExist}
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("s3 %s of [%s] failed with status [%s]", method, s.Location(name), resp.Status)
+	}
+	return resp, nil
+}
+
+// emptyPayloadHash is SHA256 of empty request body
+var emptyPayloadHash = hex.EncodeToString(sha256.New().Sum(nil))
+
+// signV4 adds AWS Signature Version 4 headers to the request.
+// Host, x-amz-* headers and range are signed.
+func signV4(req *http.Request, payloadHash, accessKey, secretKey, region string, now time.Time) {
+	amzDate := now.UTC().Format(s3AmzDateFormat)
+	date := amzDate[:8]
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		if strings.HasPrefix(name, "x-amz-") || name == "range" || name == "content-type" || name == "content-md5" {
+			headers[name] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := strings.Join([]string{date, region, s3Service, "aws4_request"}, "/")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{s3Algorithm, amzDate, scope, hex.EncodeToString(requestHash[:])}, "\n")
+
+	key := []byte("AWS4" + secretKey)
+	for _, part := range []string{date, region, s3Service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s3Algorithm, accessKey, scope, signedHeaders, signature,
+	))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3Escape encodes everything except unreserved characters as signature requires
+func s3Escape(value string, keepSlash bool) string {
+	var escaped strings.Builder
+	for _, b := range []byte(value) {
+		switch {
+		case 'A' <= b && b <= 'Z', 'a' <= b && b <= 'z', '0' <= b && b <= '9',
+			b == '-', b == '_', b == '.', b == '~', keepSlash && b == '/':
+			escaped.WriteByte(b)
+		default:
+			fmt.Fprintf(&escaped, "%%%02X", b)
+		}
+	}
+	return escaped.String()
+}
+
+func s3EscapePath(path string) string {
+	return s3Escape(path, true)
+}
+
+// s3CanonicalQuery encodes query parameters sorted by name
+func s3CanonicalQuery(query url.Values) string {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var params []string
+	for _, name := range names {
+		values := append([]string{}, query[name]...)
+		sort.Strings(values)
+		for _, value := range values {
+			params = append(params, s3Escape(name, false)+"="+s3Escape(value, false))
+		}
+	}
+	return strings.Join(params, "&")
+}
@@ -0,0 +1,138 @@
+package media
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Store keeps media files by their names, names never contain folders
+type Store interface {
+	Put(name string, contents io.Reader) error
+	Open(name string) (io.ReadCloser, error)
+	Stat(name string) (FileInfo, error) // fails with os.ErrNotExist for missing files
+	Delete(name string) error
+	List() ([]FileInfo, error)
+	Location(name string) string // local path or URL of the file
+}
+
+// FileInfo describes one stored file
+type FileInfo struct {
+	Name    string
+	Size    int64
+	ModTime time.Time
+}
+
+// NewStore creates store configured by "storage" section, local media folder is used by default
+func NewStore() (Store, error) {
+	switch storageType := viper.GetString("storage.type"); storageType {
+	case "", "local":
+		return NewLocalStore(viper.GetString("media_folder")), nil
+	case "s3":
+		store := &S3Store{
+			Endpoint:  viper.GetString("storage.endpoint"),
+			Bucket:    viper.GetString("storage.bucket"),
+			Region:    viper.GetString("storage.region"),
+			AccessKey: viper.GetString("storage.access_key"),
+			SecretKey: viper.GetString("storage.secret_key"),
+			Prefix:    viper.GetString("storage.prefix"),
+		}
+		if store.Region == "" {
+			store.Region = "us-east-1"
+		}
+		for setting, value := range map[string]string{
+			"storage.endpoint": store.Endpoint, "storage.bucket": store.Bucket,
+			"storage.access_key": store.AccessKey, "storage.secret_key": store.SecretKey,
+		} {
+			if value == "" {
+				return nil, fmt.Errorf("s3 storage requires [%s] setting", setting)
+			}
+		}
+		return store, nil
+	default:
+		return nil, fmt.Errorf("unknown storage type [%s]", storageType)
+	}
+}
+
+// moveFile renames stored file, stores have no rename so contents are copied
+func moveFile(store Store, from, to string) error {
+	if local, ok := store.(*LocalStore); ok {
+		return os.Rename(local.Location(from), local.Location(to))
+	}
+	file, err := store.Open(from)
+	if err != nil {
+		return err
+	}
+	err = store.Put(to, file)
+	file.Close()
+	if err != nil {
+		return err
+	}
+	return store.Delete(from)
+}
+
+// LocalStore keeps files in a local (or mounted) folder
+type LocalStore struct {
+	Folder string
+}
+
+// NewLocalStore creates store in the folder
+func NewLocalStore(folder string) *LocalStore {
+	return &LocalStore{Folder: folder}
+}
+
+func (s *LocalStore) Put(name string, contents io.Reader) error {
+	file, err := os.Create(s.Location(name))
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(file, contents)
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(s.Location(name))
+	}
+	return err
+}
+
+func (s *LocalStore) Open(name string) (io.ReadCloser, error) {
+	return os.Open(s.Location(name))
+}
+
+func (s *LocalStore) Stat(name string) (FileInfo, error) {
+	info, err := os.Stat(s.Location(name))
+	if err != nil {
+		return FileInfo{}, err
+	}
+	return FileInfo{Name: name, Size: info.Size(), ModTime: info.ModTime()}, nil
+}
+
+func (s *LocalStore) Delete(name string) error {
+	return os.Remove(s.Location(name))
+}
+
+// List returns regular files of the folder, subfolders are skipped
+func (s *LocalStore) List() ([]FileInfo, error) {
+	entries, err := ioutil.ReadDir(s.Folder)
+	if err != nil {
+		return nil, err
+	}
+	files := []FileInfo{}
+	for _, entry := range entries {
+		if entry.Mode().IsRegular() {
+			files = append(files, FileInfo{Name: entry.Name(), Size: entry.Size(), ModTime: entry.ModTime()})
+		}
+	}
+	return files, nil
+}
+
+func (s *LocalStore) Location(name string) string {
+	return filepath.Join(s.Folder, name)
+}
@@ -0,0 +1,131 @@
+package media
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SubscriptionSettings change how posts of subscribed blog are synced
+type SubscriptionSettings struct {
+	AutoApprove bool   `db:"auto_approve"` // liked posts are added instead of queued
+	SFW         *bool  // default SFW flag of posts, NULL keeps the sync default
+	DefaultTags string `db:"default_tags"` // comma separated tags added to every post
+	Archive     bool   // posts are stored but never removed from tumblr
+}
+
+// Tags returns list of default tags
+func (s SubscriptionSettings) Tags() []string {
+	var tags []string
+	for _, tag := range strings.Split(s.DefaultTags, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// SubscriptionGroup is a label of subscriptions with number of subscriptions in it
+type SubscriptionGroup struct {
+	Name          string
+	Subscriptions int
+}
+
+// GetSubscription finds subscription by blog name
+func (r mediaRepo) GetSubscription(blogName string) (Subscription, error) {
+	var sub Subscription
+	err := r.DB.Get(&sub, "SELECT "+subscriptionColumns+" FROM subscriptions WHERE blog_name = ?", blogName)
+	if err != nil {
+		return sub, fmt.Errorf("subscription [%s] not found", blogName)
+	}
+	return sub, nil
+}
+
+// UpdateSubscriptionSettings saves sync settings of the subscription
+func (r mediaRepo) UpdateSubscriptionSettings(sub *Subscription) error {
+	sub.UpdatedAt = time.Now()
+	res, err := r.DB.NamedExec(
+		`UPDATE subscriptions SET updated_at = :updated_at, auto_approve = :auto_approve, sfw = :sfw,
+		default_tags = :default_tags, archive = :archive WHERE id = :id`,
+		sub,
+	)
+	if err != nil {
+		return err
+	}
+	if updated, _ := res.RowsAffected(); updated == 0 {
+		return fmt.Errorf("subscription [%d] not found", sub.ID)
+	}
+	return nil
+}
+
+// AddSubscriptionToGroup puts subscribed blog to the group, groups exist while they have subscriptions
+func (r mediaRepo) AddSubscriptionToGroup(blogName, group string) error {
+	if strings.TrimSpace(group) == "" {
+		return fmt.Errorf("group name is empty")
+	}
+	sub, err := r.GetSubscription(blogName)
+	if err != nil {
+		return err
+	}
+	_, err = r.DB.Exec(
+		"INSERT OR IGNORE INTO subscription_groups (subscription_id, name) VALUES (?, ?)", sub.ID, group,
+	)
+	return err
+}
+
+// RemoveSubscriptionFromGroup takes subscribed blog out of the group
+func (r mediaRepo) RemoveSubscriptionFromGroup(blogName, group string) error {
+	sub, err := r.GetSubscription(blogName)
+	if err != nil {
+		return err
+	}
+	res, err := r.DB.Exec("DELETE FROM subscription_groups WHERE subscription_id = ? AND name = ?", sub.ID, group)
+	if err != nil {
+		return err
+	}
+	if removed, _ := res.RowsAffected(); removed == 0 {
+		return fmt.Errorf("subscription [%s] is not in group [%s]", blogName, group)
+	}
+	return nil
+}
+
+// ListSubscriptionGroups returns all groups with numbers of their subscriptions ordered by name
+func (r mediaRepo) ListSubscriptionGroups() ([]SubscriptionGroup, error) {
+	var groups []SubscriptionGroup
+	err := r.DB.Select(
+		&groups,
+		"SELECT name, count(*) AS subscriptions FROM subscription_groups GROUP BY name ORDER BY name",
+	)
+	return groups, err
+}
+
+// ListGroupSubscriptions returns subscriptions of the group
+func (r mediaRepo) ListGroupSubscriptions(group string) ([]Subscription, error) {
+	var subscriptions []Subscription
+	err := r.DB.Select(
+		&subscriptions,
+		`SELECT `+subscriptionColumns+` FROM subscriptions
+		WHERE id IN (SELECT subscription_id FROM subscription_groups WHERE name = ?) ORDER BY id`,
+		group,
+	)
+	return subscriptions, err
+}
+
+// ListSubscriptionGroupNames returns names of groups the subscribed blog belongs to
+func (r mediaRepo) ListSubscriptionGroupNames(blogName string) ([]string, error) {
+	sub, err := r.GetSubscription(blogName)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	err = r.DB.Select(&names, "SELECT name FROM subscription_groups WHERE subscription_id = ? ORDER BY name", sub.ID)
+	return names, err
+}
+
+// SubscriptionGroupsSchema represents schema for "subscription_groups" table
+// that labels subscriptions with group names
+var SubscriptionGroupsSchema = `CREATE TABLE "subscription_groups" (
+	"subscription_id" integer,
+	"name" varchar(255),
+	PRIMARY KEY ("subscription_id","name")
+)`
@@ -0,0 +1,67 @@
+package media
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubscriptionSettings(t *testing.T) {
+	teardown := setup()
+	defer teardown()
+	sub := &Subscription{BlogName: "blog"}
+	repo.AddSubscription(sub)
+
+	stored, err := repo.GetSubscription("blog")
+	checkErrors(t, nil, err)
+	assert.Nil(t, stored.SFW)
+	assert.False(t, stored.AutoApprove)
+	assert.Empty(t, stored.Tags())
+
+	sfw := false
+	stored.SubscriptionSettings = SubscriptionSettings{AutoApprove: true, SFW: &sfw, DefaultTags: " art,, cats ", Archive: true}
+	checkErrors(t, nil, repo.UpdateSubscriptionSettings(&stored))
+
+	stored, err = repo.GetSubscription("blog")
+	checkErrors(t, nil, err)
+	assert.True(t, stored.AutoApprove)
+	assert.False(t, *stored.SFW)
+	assert.True(t, stored.Archive)
+	assert.Equal(t, []string{"art", "cats"}, stored.Tags())
+
+	_, err = repo.GetSubscription("missing")
+	checkErrors(t, errors.New("subscription [missing] not found"), err)
+}
+
+func TestSubscriptionGroups(t *testing.T) {
+	teardown := setup()
+	defer teardown()
+	repo.AddSubscription(&Subscription{BlogName: "a"})
+	repo.AddSubscription(&Subscription{BlogName: "b"})
+
+	checkErrors(t, nil, repo.AddSubscriptionToGroup("a", "art"))
+	checkErrors(t, nil, repo.AddSubscriptionToGroup("a", "art"))
+	checkErrors(t, nil, repo.AddSubscriptionToGroup("b", "art"))
+	checkErrors(t, nil, repo.AddSubscriptionToGroup("a", "photo"))
+	checkErrors(t, errors.New("subscription [c] not found"), repo.AddSubscriptionToGroup("c", "art"))
+	checkErrors(t, errors.New("group name is empty"), repo.AddSubscriptionToGroup("a", " "))
+
+	groups, err := repo.ListSubscriptionGroups()
+	checkErrors(t, nil, err)
+	assert.Equal(t, []SubscriptionGroup{{"art", 2}, {"photo", 1}}, groups)
+
+	names, _ := repo.ListSubscriptionGroupNames("a")
+	assert.Equal(t, []string{"art", "photo"}, names)
+
+	checkErrors(t, nil, repo.RemoveSubscriptionFromGroup("a", "art"))
+	checkErrors(t, errors.New("subscription [a] is not in group [art]"), repo.RemoveSubscriptionFromGroup("a", "art"))
+	subs, _ := repo.ListGroupSubscriptions("art")
+	assert.Equal(t, 1, len(subs))
+	assert.Equal(t, "b", subs[0].BlogName)
+
+	sub, _ := repo.GetSubscription("b")
+	checkErrors(t, nil, repo.RemoveSubscription(sub.ID))
+	groups, _ = repo.ListSubscriptionGroups()
+	assert.Equal(t, []SubscriptionGroup{{"photo", 1}}, groups)
+}
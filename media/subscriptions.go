@@ -1,7 +1,11 @@
 package media
 
 import (
+	"fmt"
+	"os"
 	"time"
+
+	"github.com/jmoiron/sqlx"
 )
 
 func (r mediaRepo) AddSubscription(sub *Subscription) error {
@@ -9,10 +13,10 @@ func (r mediaRepo) AddSubscription(sub *Subscription) error {
 	sub.UpdatedAt = time.Now()
 	res, err := r.DB.NamedExec(
 		`INSERT INTO subscriptions (
-       created_at, updated_at, blog_name, url, source, description, title
+       created_at, updated_at, blog_name, url, source, description, title, last_post_at, synced_at
 		 )
      VALUES (
-       :created_at, :updated_at, :blog_name, :url, :source, :description, :title
+       :created_at, :updated_at, :blog_name, :url, :source, :description, :title, :last_post_at, :synced_at
      )`,
 		sub,
 	)
@@ -31,16 +35,162 @@ func (r mediaRepo) ListSubscriptions() ([]Subscription, error) {
 	var subscriptions []Subscription
 	err := r.DB.Select(
 		&subscriptions,
-		"SELECT id, created_at, updated_at, url, blog_name, source, title, description FROM subscriptions",
+		"SELECT "+subscriptionColumns+" FROM subscriptions ORDER BY id",
 	)
 	return subscriptions, err
 }
 
-func (r mediaRepo) RemoveAllSubscriptions() error {
-	_, err := r.DB.Exec("DELETE FROM subscriptions")
+// ListInactiveSubscriptions returns subscribed blogs without posts since given time, least active go first.
+// Blogs which activity is unknown are skipped.
+func (r mediaRepo) ListInactiveSubscriptions(lastPostBefore time.Time) ([]Subscription, error) {
+	var subscriptions []Subscription
+	err := r.DB.Select(
+		&subscriptions,
+		"SELECT "+subscriptionColumns+" FROM subscriptions WHERE last_post_at < ? ORDER BY last_post_at, id",
+		lastPostBefore,
+	)
+	return subscriptions, err
+}
+
+// UpdateSubscription saves fields of subscription coming from the source, local notes are kept
+func (r mediaRepo) UpdateSubscription(sub *Subscription) error {
+	sub.UpdatedAt = time.Now()
+	_, err := r.DB.NamedExec(
+		`UPDATE subscriptions SET
+       updated_at = :updated_at, blog_name = :blog_name, url = :url, source = :source,
+       description = :description, title = :title
+     WHERE id = :id`,
+		sub,
+	)
 	return err
 }
 
+// MarkSubscriptionsSynced remembers that blogs were followed remotely at given time,
+// it is the base for the next diff
+func (r mediaRepo) MarkSubscriptionsSynced(blogNames []string, at time.Time) error {
+	return r.inTransaction(func(tx *sqlx.Tx) error {
+		for _, blogName := range blogNames {
+			_, err := tx.Exec("UPDATE subscriptions SET synced_at = ? WHERE blog_name = ?", at, blogName)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// SetSubscriptionNotes saves local notes about subscribed blog
+func (r mediaRepo) SetSubscriptionNotes(blogName, notes string) error {
+	res, err := r.DB.Exec(
+		"UPDATE subscriptions SET notes = ?, updated_at = ? WHERE blog_name = ?", notes, time.Now(), blogName,
+	)
+	if err != nil {
+		return err
+	}
+	if updated, _ := res.RowsAffected(); updated == 0 {
+		return fmt.Errorf("subscription [%s] not found", blogName)
+	}
+	return nil
+}
+
+// RefreshSubscription saves activity and post count of subscribed blog,
+// avatar is downloaded when its URL is given and replaces the previous one
+func (r mediaRepo) RefreshSubscription(sub *Subscription, avatarURL string) error {
+	if avatarURL != "" {
+		previous := ""
+		if sub.AvatarMimeType != "" {
+			previous = sub.AvatarFileName()
+		}
+		err := download(r.Store, downloadTask{
+			url:      avatarURL,
+			name:     func() string { return sub.AvatarFileName() },
+			mimeType: &sub.AvatarMimeType,
+		})
+		if err != nil {
+			return err
+		}
+		// avatar of another type gets another extension
+		if previous != "" && previous != sub.AvatarFileName() {
+			r.Store.Delete(previous)
+		}
+	}
+	sub.UpdatedAt = time.Now()
+	_, err := r.DB.NamedExec(
+		`UPDATE subscriptions SET
+       updated_at = :updated_at, last_post_at = :last_post_at, post_count = :post_count,
+       avatar_mime_type = :avatar_mime_type
+     WHERE id = :id`,
+		sub,
+	)
+	return err
+}
+
+// GetSubscriptionAvatarPath returns location of downloaded avatar, empty when there is no avatar
+func (r mediaRepo) GetSubscriptionAvatarPath(sub *Subscription) string {
+	if sub.AvatarMimeType == "" {
+		return ""
+	}
+	return r.Store.Location(sub.AvatarFileName())
+}
+
+// AvatarFileName returns local file name where avatar of subscribed blog (should be) stored
+func (sub Subscription) AvatarFileName() string {
+	return fmt.Sprintf("avatar_%d%s", sub.ID, fileExtension(sub.AvatarMimeType, ""))
+}
+
+// RemoveSubscription removes subscription with its groups and downloaded avatar
+func (r mediaRepo) RemoveSubscription(id uint) error {
+	var subs []Subscription
+	err := r.DB.Select(&subs, "SELECT id, avatar_mime_type FROM subscriptions WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	err = r.inTransaction(func(tx *sqlx.Tx) error {
+		_, err := tx.Exec("DELETE FROM subscription_groups WHERE subscription_id = ?", id)
+		if err == nil {
+			_, err = tx.Exec("DELETE FROM subscriptions WHERE id = ?", id)
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return r.removeAvatars(subs)
+}
+
+func (r mediaRepo) RemoveAllSubscriptions() error {
+	var subs []Subscription
+	err := r.DB.Select(&subs, "SELECT id, avatar_mime_type FROM subscriptions")
+	if err != nil {
+		return err
+	}
+	err = r.inTransaction(func(tx *sqlx.Tx) error {
+		_, err := tx.Exec("DELETE FROM subscription_groups")
+		if err == nil {
+			_, err = tx.Exec("DELETE FROM subscriptions")
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return r.removeAvatars(subs)
+}
+
+// removeAvatars deletes downloaded avatars of removed subscriptions
+func (r mediaRepo) removeAvatars(subs []Subscription) error {
+	for _, sub := range subs {
+		if sub.AvatarMimeType == "" {
+			continue
+		}
+		err := r.Store.Delete(sub.AvatarFileName())
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
 // Subscription represents particular blog that user is subscribed to
 type Subscription struct {
 	ID        uint
@@ -52,8 +202,19 @@ type Subscription struct {
 	URL         string `db:"url"`
 	Description string
 	Title       string
+	Notes       string     // local only, never synced
+	SyncedAt    *time.Time `db:"synced_at"` // last time blog was seen followed remotely, NULL for local additions
+
+	LastPostAt     *time.Time `db:"last_post_at"` // NULL until blog activity is known
+	PostCount      int        `db:"post_count"`
+	AvatarMimeType string     `db:"avatar_mime_type"` // empty until avatar is downloaded
+
+	SubscriptionSettings
 }
 
+const subscriptionColumns = `id, created_at, updated_at, url, blog_name, source, title, description, notes,
+	synced_at, last_post_at, post_count, avatar_mime_type, auto_approve, sfw, default_tags, archive`
+
 // SubscriptionsSchema represents schema for "subscriptions" table
 var SubscriptionsSchema = `CREATE TABLE "subscriptions" (
 	"id" integer PRIMARY KEY AUTOINCREMENT,
@@ -63,5 +224,27 @@ var SubscriptionsSchema = `CREATE TABLE "subscriptions" (
 	"source" varchar(255),
 	"url" varchar(255),
 	"description" varchar(255),
-	"title" varchar(255)
+	"title" varchar(255),
+	"notes" text DEFAULT '',
+	"synced_at" datetime,
+	"last_post_at" datetime,
+	"post_count" integer DEFAULT 0,
+	"avatar_mime_type" varchar(255) DEFAULT '',
+	"auto_approve" bool DEFAULT false,
+	"sfw" bool,
+	"default_tags" text DEFAULT '',
+	"archive" bool DEFAULT false
 )`
+
+// SubscriptionsMigrations adds columns missing in "subscriptions" tables created by older versions
+var SubscriptionsMigrations = []string{
+	`ALTER TABLE "subscriptions" ADD COLUMN "notes" text DEFAULT ''`,
+	`ALTER TABLE "subscriptions" ADD COLUMN "synced_at" datetime`,
+	`ALTER TABLE "subscriptions" ADD COLUMN "last_post_at" datetime`,
+	`ALTER TABLE "subscriptions" ADD COLUMN "post_count" integer DEFAULT 0`,
+	`ALTER TABLE "subscriptions" ADD COLUMN "avatar_mime_type" varchar(255) DEFAULT ''`,
+	`ALTER TABLE "subscriptions" ADD COLUMN "auto_approve" bool DEFAULT false`,
+	`ALTER TABLE "subscriptions" ADD COLUMN "sfw" bool`,
+	`ALTER TABLE "subscriptions" ADD COLUMN "default_tags" text DEFAULT ''`,
+	`ALTER TABLE "subscriptions" ADD COLUMN "archive" bool DEFAULT false`,
+}
@@ -0,0 +1,65 @@
+package media
+
+// SubscriptionChange is a subscription known on both sides with different metadata
+type SubscriptionChange struct {
+	Local  Subscription
+	Remote Subscription
+	Fields []string // names of changed fields
+}
+
+// SubscriptionsDiff describes how remote subscriptions differ from local ones,
+// subscriptions are matched by blog name
+type SubscriptionsDiff struct {
+	Added     []Subscription // only remote
+	Removed   []Subscription // only local, but were remote when synced last time
+	LocalOnly []Subscription // only local and were never synced
+	Changed   []SubscriptionChange
+}
+
+// Empty tells whether both sides are the same
+func (d SubscriptionsDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.LocalOnly) == 0 && len(d.Changed) == 0
+}
+
+// DiffSubscriptions compares local subscriptions with remote ones, local notes are not compared.
+// Local subscriptions synced before are the base of the diff: the ones missing remotely are
+// removed there, others were added locally.
+func DiffSubscriptions(local, remote []Subscription) SubscriptionsDiff {
+	diff := SubscriptionsDiff{}
+	remoteByName := map[string]Subscription{}
+	for _, sub := range remote {
+		remoteByName[sub.BlogName] = sub
+	}
+	localByName := map[string]bool{}
+	for _, sub := range local {
+		localByName[sub.BlogName] = true
+		remoteSub, ok := remoteByName[sub.BlogName]
+		if !ok {
+			if sub.SyncedAt != nil {
+				diff.Removed = append(diff.Removed, sub)
+			} else {
+				diff.LocalOnly = append(diff.LocalOnly, sub)
+			}
+			continue
+		}
+		var fields []string
+		if sub.URL != remoteSub.URL {
+			fields = append(fields, "url")
+		}
+		if sub.Title != remoteSub.Title {
+			fields = append(fields, "title")
+		}
+		if sub.Description != remoteSub.Description {
+			fields = append(fields, "description")
+		}
+		if len(fields) > 0 {
+			diff.Changed = append(diff.Changed, SubscriptionChange{Local: sub, Remote: remoteSub, Fields: fields})
+		}
+	}
+	for _, sub := range remote {
+		if !localByName[sub.BlogName] {
+			diff.Added = append(diff.Added, sub)
+		}
+	}
+	return diff
+}
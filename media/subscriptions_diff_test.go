@@ -0,0 +1,35 @@
+package media
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffSubscriptions(t *testing.T) {
+	synced := time.Now()
+	local := []Subscription{
+		{ID: 1, BlogName: "same", URL: "http://same.com", Title: "Same", Notes: "local notes"},
+		{ID: 2, BlogName: "changed", URL: "http://changed.com", Title: "Old"},
+		{ID: 3, BlogName: "local", URL: "http://local.com"},
+		{ID: 4, BlogName: "unfollowed", URL: "http://unfollowed.com", SyncedAt: &synced},
+	}
+	remote := []Subscription{
+		{BlogName: "remote", URL: "http://remote.com"},
+		{BlogName: "changed", URL: "http://changed.com", Title: "New", Description: "Description"},
+		{BlogName: "same", URL: "http://same.com", Title: "Same"},
+	}
+
+	diff := DiffSubscriptions(local, remote)
+	assert.False(t, diff.Empty())
+	assert.Equal(t, []Subscription{remote[0]}, diff.Added)
+	assert.Equal(t, []Subscription{local[3]}, diff.Removed)
+	assert.Equal(t, []Subscription{local[2]}, diff.LocalOnly)
+	assert.Equal(t, []SubscriptionChange{
+		{Local: local[1], Remote: remote[1], Fields: []string{"title", "description"}},
+	}, diff.Changed)
+
+	assert.True(t, DiffSubscriptions(local[:1], remote[2:]).Empty())
+	assert.True(t, DiffSubscriptions(nil, nil).Empty())
+}
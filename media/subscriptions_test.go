@@ -1,7 +1,11 @@
 package media
 
 import (
+	"errors"
+	"io/ioutil"
+	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -89,3 +93,62 @@ func TestListSubscriptions(t *testing.T) {
 	assert.Equal(t, "a", subs[1].BlogName)
 	assert.Equal(t, "tumblr", subs[1].Source)
 }
+
+func TestUpdateSubscription(t *testing.T) {
+	teardown := setup()
+	defer teardown()
+	sub := &Subscription{URL: "blog.tumblr.com", BlogName: "blog", Source: "tumblr", Title: "old"}
+	repo.AddSubscription(sub)
+
+	checkErrors(t, nil, repo.SetSubscriptionNotes("blog", "local notes"))
+	checkErrors(t, errors.New("subscription [missing] not found"), repo.SetSubscriptionNotes("missing", "notes"))
+
+	checkErrors(t, nil, repo.UpdateSubscription(&Subscription{
+		ID: sub.ID, URL: "new.tumblr.com", BlogName: "blog", Source: "tumblr", Title: "new",
+	}))
+	subs, _ := repo.ListSubscriptions()
+	assert.Equal(t, 1, len(subs))
+	assert.Equal(t, "new.tumblr.com", subs[0].URL)
+	assert.Equal(t, "new", subs[0].Title)
+	assert.Equal(t, "local notes", subs[0].Notes)
+
+	checkErrors(t, nil, repo.RemoveSubscription(sub.ID))
+	subs, _ = repo.ListSubscriptions()
+	assert.Empty(t, subs)
+}
+
+func TestRemoveSubscriptionDeletesAvatar(t *testing.T) {
+	teardown := setup()
+	defer teardown()
+
+	sub := &Subscription{URL: "blog.tumblr.com", BlogName: "blog", Source: "tumblr", AvatarMimeType: "image/png"}
+	checkErrors(t, nil, repo.AddSubscription(sub))
+	DB.Exec("UPDATE subscriptions SET avatar_mime_type = ? WHERE id = ?", sub.AvatarMimeType, sub.ID)
+	avatarPath := repo.GetSubscriptionAvatarPath(sub)
+	checkErrors(t, nil, ioutil.WriteFile(avatarPath, []byte("avatar"), 0644))
+	defer os.Remove(avatarPath)
+
+	checkErrors(t, nil, repo.RemoveSubscription(sub.ID))
+	_, err := os.Stat(avatarPath)
+	assert.True(t, os.IsNotExist(err))
+
+	// subscriptions without downloaded avatars are removed as well
+	sub = &Subscription{URL: "new.tumblr.com", BlogName: "new", Source: "tumblr"}
+	checkErrors(t, nil, repo.AddSubscription(sub))
+	checkErrors(t, nil, repo.RemoveSubscription(sub.ID))
+}
+
+func TestListInactiveSubscriptions(t *testing.T) {
+	teardown := setup()
+	defer teardown()
+	old := time.Now().AddDate(-2, 0, 0)
+	recent := time.Now().AddDate(0, -1, 0)
+	repo.AddSubscription(&Subscription{BlogName: "recent", LastPostAt: &recent})
+	repo.AddSubscription(&Subscription{BlogName: "unknown"})
+	repo.AddSubscription(&Subscription{BlogName: "old", LastPostAt: &old})
+
+	subs, err := repo.ListInactiveSubscriptions(time.Now().AddDate(-1, 0, 0))
+	checkErrors(t, nil, err)
+	assert.Equal(t, 1, len(subs))
+	assert.Equal(t, "old", subs[0].BlogName)
+}
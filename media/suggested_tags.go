@@ -0,0 +1,91 @@
+package media
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// AddSuggestedTagToPost stores tag found on queued post,
+// suggested tags become real tags of the post when it is approved
+func (r mediaRepo) AddSuggestedTagToPost(post *Post, name string) error {
+	tag, err := r.findOrAddTag(name)
+	if err != nil {
+		return err
+	}
+	_, err = r.DB.Exec(
+		"INSERT OR IGNORE INTO posts_suggested_tags (post_id, tag_id) VALUES (?, ?)",
+		post.ID, tag.ID,
+	)
+	return err
+}
+
+// ListPostSuggestedTags returns names of tags suggested for the post
+func (r mediaRepo) ListPostSuggestedTags(postID uint) ([]string, error) {
+	return r.listPostTagsIn("posts_suggested_tags", postID)
+}
+
+// ListSuggestedTags returns tags suggested for queued posts with numbers of the posts
+func (r mediaRepo) ListSuggestedTags() ([]TagCount, error) {
+	var tags []TagCount
+	err := r.DB.Select(
+		&tags,
+		`SELECT tags.id, tags.name, count(posts.id) AS posts
+		FROM tags
+		INNER JOIN posts_suggested_tags ON posts_suggested_tags.tag_id = tags.id
+		INNER JOIN posts ON posts.id = posts_suggested_tags.post_id
+			AND posts.status = 'queued' AND posts.deleted_at IS NULL
+		GROUP BY tags.id ORDER BY posts DESC, tags.name`,
+	)
+	return tags, err
+}
+
+// ListQueuedPosts returns queued posts, only posts with the suggested tag when it's not empty
+func (r mediaRepo) ListQueuedPosts(suggestedTag string) ([]Post, error) {
+	var posts []Post
+	err := r.DB.Select(
+		&posts,
+		`SELECT * FROM posts WHERE status = 'queued' AND deleted_at IS NULL AND (? = '' OR EXISTS (
+			SELECT 1 FROM posts_suggested_tags
+			INNER JOIN tags ON tags.id = posts_suggested_tags.tag_id
+			WHERE posts_suggested_tags.post_id = posts.id AND tags.name = ?
+		)) ORDER BY id`,
+		suggestedTag, suggestedTag,
+	)
+	return posts, err
+}
+
+// ApprovePost marks queued post as added and promotes its suggested tags to real ones
+func (r mediaRepo) ApprovePost(postID uint) error {
+	return r.inTransaction(func(tx *sqlx.Tx) error {
+		res, err := tx.Exec(
+			"UPDATE posts SET status = 'added', updated_at = ? WHERE id = ? AND status = 'queued'",
+			time.Now(), postID,
+		)
+		if err != nil {
+			return err
+		}
+		if updated, _ := res.RowsAffected(); updated == 0 {
+			return fmt.Errorf("queued post [%d] not found", postID)
+		}
+		_, err = tx.Exec(
+			`INSERT OR IGNORE INTO posts_tags (post_id, tag_id)
+			SELECT post_id, tag_id FROM posts_suggested_tags WHERE post_id = ?`,
+			postID,
+		)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec("DELETE FROM posts_suggested_tags WHERE post_id = ?", postID)
+		return err
+	})
+}
+
+// PostsSuggestedTagsSchema represents schema for "posts_suggested_tags" table
+// that connects queued posts with tags they were tagged with in the source
+var PostsSuggestedTagsSchema = `CREATE TABLE "posts_suggested_tags" (
+	"post_id" integer,
+	"tag_id" integer,
+	PRIMARY KEY ("post_id","tag_id")
+)`
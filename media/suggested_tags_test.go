@@ -0,0 +1,85 @@
+package media
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func suggestedTags(post Post) []string {
+	var names []string
+	DB.Select(&names, `SELECT tags.name FROM tags
+		INNER JOIN posts_suggested_tags ON posts_suggested_tags.tag_id = tags.id
+		WHERE posts_suggested_tags.post_id = ? ORDER BY tags.name`, post.ID)
+	return names
+}
+
+func TestSuggestedTags(t *testing.T) {
+	teardown := setup()
+	defer teardown()
+
+	cats := Post{Status: "queued", Source: "tumblr", ExternalID: "1"}
+	dogs := Post{Status: "queued", Source: "tumblr", ExternalID: "2"}
+	added := Post{Status: "added", Source: "tumblr", ExternalID: "3"}
+	for _, post := range []*Post{&cats, &dogs, &added} {
+		checkErrors(t, nil, repo.AddPost(post))
+	}
+	checkErrors(t, nil, repo.AddTagToPost(&added, "cats"))
+	checkErrors(t, nil, repo.AddSuggestedTagToPost(&cats, "cats"))
+	checkErrors(t, nil, repo.AddSuggestedTagToPost(&cats, "cute"))
+	checkErrors(t, nil, repo.AddSuggestedTagToPost(&cats, "cute"))
+	checkErrors(t, nil, repo.AddSuggestedTagToPost(&dogs, "cute"))
+	checkErrors(t, nil, repo.AddSuggestedTagToPost(&dogs, "dogs"))
+
+	tags, err := repo.ListSuggestedTags()
+	checkErrors(t, nil, err)
+	assert.Equal(t, []string{"cute", "cats", "dogs"}, []string{tags[0].Name, tags[1].Name, tags[2].Name})
+	assert.Equal(t, []int{2, 1, 1}, []int{tags[0].Posts, tags[1].Posts, tags[2].Posts})
+
+	// suggested tags are not real tags of the post
+	assert.Equal(t, map[uint][]string{added.ID: {"cats"}}, postTags())
+
+	posts, err := repo.ListQueuedPosts("")
+	checkErrors(t, nil, err)
+	assert.Len(t, posts, 2)
+	posts, err = repo.ListQueuedPosts("dogs")
+	checkErrors(t, nil, err)
+	if assert.Len(t, posts, 1) {
+		assert.Equal(t, dogs.ID, posts[0].ID)
+	}
+
+	checkErrors(t, nil, repo.ApprovePost(cats.ID))
+	assert.Equal(t, map[uint][]string{added.ID: {"cats"}, cats.ID: {"cats", "cute"}}, postTags())
+	assert.Empty(t, suggestedTags(cats))
+	var status string
+	DB.Get(&status, "SELECT status FROM posts WHERE id = ?", cats.ID)
+	assert.Equal(t, "added", status)
+
+	checkErrors(t, errors.New("queued post [3] not found"), repo.ApprovePost(added.ID))
+	checkErrors(t, errors.New("queued post [42] not found"), repo.ApprovePost(42))
+
+	posts, err = repo.ListQueuedPosts("cute")
+	checkErrors(t, nil, err)
+	assert.Len(t, posts, 1)
+}
+
+func TestTagManagementKeepsSuggestedTags(t *testing.T) {
+	teardown := setup()
+	defer teardown()
+
+	post := Post{Status: "queued", Source: "tumblr", ExternalID: "1"}
+	checkErrors(t, nil, repo.AddPost(&post))
+	checkErrors(t, nil, repo.AddSuggestedTagToPost(&post, "Cats"))
+	checkErrors(t, nil, repo.AddSuggestedTagToPost(&post, "kitty"))
+	checkErrors(t, nil, repo.AddSuggestedTagToPost(&post, "reblog"))
+
+	checkErrors(t, nil, repo.MergeTags("cats", []string{"Cats", "kitty"}))
+	checkErrors(t, nil, repo.DeleteTag("reblog"))
+	assert.Equal(t, []string{"cats"}, suggestedTags(post))
+	assert.Equal(t, []string{"cats"}, tagNames())
+
+	report, err := repo.Fsck(FsckOptions{})
+	checkErrors(t, nil, err)
+	assert.Empty(t, issuesOfKind(report, IssueUnusedTag))
+}
@@ -0,0 +1,26 @@
+package media
+
+import "database/sql"
+
+// GetSyncState returns value saved under the key, empty string when nothing is saved
+func (r mediaRepo) GetSyncState(key string) (string, error) {
+	var value string
+	err := r.DB.Get(&value, "SELECT value FROM sync_state WHERE key = ?", key)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return value, err
+}
+
+// SetSyncState saves value under the key replacing previous one
+func (r mediaRepo) SetSyncState(key, value string) error {
+	_, err := r.DB.Exec("INSERT OR REPLACE INTO sync_state (key, value) VALUES (?, ?)", key, value)
+	return err
+}
+
+// SyncStateSchema represents schema for "sync_state" table
+// that keeps progress of incremental imports
+var SyncStateSchema = `CREATE TABLE "sync_state" (
+	"key" varchar(255) PRIMARY KEY,
+	"value" text
+)`
@@ -0,0 +1,22 @@
+package media
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSyncState(t *testing.T) {
+	teardown := setup()
+	defer teardown()
+
+	value, err := repo.GetSyncState("key")
+	checkErrors(t, nil, err)
+	assert.Equal(t, "", value)
+
+	checkErrors(t, nil, repo.SetSyncState("key", "1"))
+	checkErrors(t, nil, repo.SetSyncState("key", "2"))
+	value, err = repo.GetSyncState("key")
+	checkErrors(t, nil, err)
+	assert.Equal(t, "2", value)
+}
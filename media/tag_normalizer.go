@@ -0,0 +1,81 @@
+package media
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/spf13/viper"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// TagNormalizer cleans up tag names before they are stored, every step is optional.
+// Steps are applied in order: Unicode NFKC, case folding, whitespace collapsing,
+// prefix stripping, length cutoff and stop-list check.
+type TagNormalizer struct {
+	Unicode        bool
+	CaseFold       bool
+	CollapseSpaces bool
+	StripPrefixes  []string
+	MaxLength      int // in characters, 0 means no limit
+	StopList       []string
+}
+
+// LoadTagNormalizer reads "tags.normalize" config section, missing section turns normalization off
+func LoadTagNormalizer() TagNormalizer {
+	return TagNormalizer{
+		Unicode:        viper.GetBool("tags.normalize.unicode"),
+		CaseFold:       viper.GetBool("tags.normalize.case_fold"),
+		CollapseSpaces: viper.GetBool("tags.normalize.collapse_spaces"),
+		StripPrefixes:  viper.GetStringSlice("tags.normalize.strip_prefixes"),
+		MaxLength:      viper.GetInt("tags.normalize.max_length"),
+		StopList:       viper.GetStringSlice("tags.normalize.stop_list"),
+	}
+}
+
+// Normalize returns normalized tag name, empty name means the tag should be dropped
+func (n TagNormalizer) Normalize(tag string) string {
+	tag = n.clean(tag)
+	for _, stopTag := range n.StopList {
+		if tag == n.clean(stopTag) {
+			return ""
+		}
+	}
+	return tag
+}
+
+func (n TagNormalizer) clean(tag string) string {
+	if n.Unicode {
+		tag = norm.NFKC.String(tag)
+	}
+	if n.CaseFold {
+		tag = cases.Fold().String(tag)
+	}
+	if n.CollapseSpaces {
+		tag = strings.Join(strings.Fields(tag), " ")
+	}
+	tag = n.stripPrefixes(tag)
+	if n.MaxLength > 0 {
+		if runes := []rune(tag); len(runes) > n.MaxLength {
+			tag = strings.TrimRightFunc(string(runes[:n.MaxLength]), unicode.IsSpace)
+		}
+	}
+	return tag
+}
+
+// stripPrefixes removes prefixes until none of them is left, so "##art" becomes "art"
+func (n TagNormalizer) stripPrefixes(tag string) string {
+	for stripped := true; stripped; {
+		stripped = false
+		for _, prefix := range n.StripPrefixes {
+			if n.CaseFold {
+				prefix = cases.Fold().String(prefix)
+			}
+			if prefix != "" && strings.HasPrefix(tag, prefix) {
+				tag = strings.TrimLeftFunc(strings.TrimPrefix(tag, prefix), unicode.IsSpace)
+				stripped = true
+			}
+		}
+	}
+	return tag
+}
@@ -0,0 +1,60 @@
+package media
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeTag(t *testing.T) {
+	normalizer := TagNormalizer{
+		Unicode:        true,
+		CaseFold:       true,
+		CollapseSpaces: true,
+		StripPrefixes:  []string{"#", "Tag:"},
+		MaxLength:      12,
+		StopList:       []string{"Reblog", "#nsfw"},
+	}
+	cases := map[string]string{
+		"cats":                   "cats",
+		"Cats":                   "cats",
+		"  Black   \t Cats ":     "black cats",
+		"#cats":                  "cats",
+		"## cats":                "cats",
+		"TAG: cats":              "cats",
+		"ｃａｔｓ":                   "cats",
+		"ﬁsh":                    "fish",
+		"Straße":                 "strasse",
+		"very long tag for cats": "very long ta",
+		"long tag   is cut":      "long tag is",
+		"reblog":                 "",
+		"REBLOG":                 "",
+		"nsfw":                   "",
+		"#":                      "",
+		"🐱 cats":                 "🐱 cats",
+	}
+	for tag, expected := range cases {
+		assert.Equal(t, expected, normalizer.Normalize(tag), tag)
+	}
+
+	assert.Equal(t, "  #Cats ", TagNormalizer{}.Normalize("  #Cats "))
+}
+
+func TestLoadTagNormalizer(t *testing.T) {
+	defer viper.Set("tags", nil)
+
+	viper.Set("tags", map[string]interface{}{
+		"normalize": map[string]interface{}{
+			"unicode": true, "case_fold": true, "strip_prefixes": []string{"#"},
+			"max_length": 64, "stop_list": []string{"reblog"},
+		},
+	})
+	assert.Equal(t, TagNormalizer{
+		Unicode:       true,
+		CaseFold:      true,
+		StripPrefixes: []string{"#"},
+		MaxLength:     64,
+		StopList:      []string{"reblog"},
+	}, LoadTagNormalizer())
+}
@@ -1,7 +1,10 @@
 package media
 
 import (
+	"fmt"
 	"time"
+
+	"github.com/jmoiron/sqlx"
 )
 
 func (r mediaRepo) AddTag(tag *Tag) error {
@@ -23,20 +26,9 @@ func (r mediaRepo) AddTag(tag *Tag) error {
 }
 
 func (r mediaRepo) AddTagToPost(post *Post, externalTag string) error {
-	tag, err := r.findTag(externalTag)
+	tag, err := r.findOrAddTag(externalTag)
 	if err != nil {
-		tag = Tag{Name: externalTag}
-		err = r.AddTag(&tag)
-
-		if err != nil {
-			return err
-		}
-
-		tag, err = r.findTag(externalTag)
-
-		if err != nil {
-			return err
-		}
+		return err
 	}
 	_, err = r.DB.Exec(
 		"INSERT INTO posts_tags (post_id, tag_id) VALUES (?, ?)",
@@ -54,12 +46,280 @@ type Tag struct {
 	Name string
 }
 
+// TagCount is a tag with number of posts it's assigned to
+type TagCount struct {
+	Tag
+	Posts int
+}
+
+// TagAlias maps tag name found in the wild to the name used in the archive
+type TagAlias struct {
+	Alias   string
+	TagName string `db:"tag_name"`
+}
+
+// ListPostTags returns names of tags and suggested tags of the post
+func (r mediaRepo) ListPostTags(postID uint) ([]string, error) {
+	var names []string
+	err := r.DB.Select(
+		&names,
+		`SELECT tags.name FROM tags WHERE tags.id IN (
+			SELECT tag_id FROM posts_tags WHERE post_id = ?
+			UNION SELECT tag_id FROM posts_suggested_tags WHERE post_id = ?
+		) ORDER BY tags.name`,
+		postID, postID,
+	)
+	return names, err
+}
+
+// ListPostAssignedTags returns names of tags of the post without suggested ones
+func (r mediaRepo) ListPostAssignedTags(postID uint) ([]string, error) {
+	return r.listPostTagsIn("posts_tags", postID)
+}
+
+// listPostTagsIn returns names of the post tags connected by the table
+func (r mediaRepo) listPostTagsIn(table string, postID uint) ([]string, error) {
+	var names []string
+	err := r.DB.Select(
+		&names,
+		fmt.Sprintf(`SELECT tags.name FROM tags
+		INNER JOIN %[1]s ON %[1]s.tag_id = tags.id
+		WHERE %[1]s.post_id = ? ORDER BY tags.name`, table),
+		postID,
+	)
+	return names, err
+}
+
+// ListTags returns all tags with their posts counts, most used go first,
+// deleted posts are not counted
+func (r mediaRepo) ListTags() ([]TagCount, error) {
+	var tags []TagCount
+	err := r.DB.Select(
+		&tags,
+		`SELECT tags.id, tags.name, count(posts.id) AS posts
+		FROM tags
+		LEFT JOIN posts_tags ON posts_tags.tag_id = tags.id
+		LEFT JOIN posts ON posts.id = posts_tags.post_id AND posts.deleted_at IS NULL
+		GROUP BY tags.id ORDER BY posts DESC, tags.name`,
+	)
+	return tags, err
+}
+
+// RenameTag changes name of the tag, aliases of the tag follow it.
+// Renaming to the name of another existing tag fails, such tags should be merged.
+func (r mediaRepo) RenameTag(from, to string) error {
+	tag, err := r.findTag(from)
+	if err != nil {
+		return fmt.Errorf("tag [%s] not found", from)
+	}
+	if _, err := r.findTag(to); err == nil {
+		return fmt.Errorf("tag [%s] already exists, merge tags instead", to)
+	}
+	return r.inTransaction(func(tx *sqlx.Tx) error {
+		_, err := tx.Exec("UPDATE tags SET name = ?, updated_at = ? WHERE id = ?", to, time.Now(), tag.ID)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec("UPDATE tag_aliases SET tag_name = ? WHERE tag_name = ?", to, from)
+		return err
+	})
+}
+
+// MergeTags moves posts (suggestions included) of source tags to the target tag and removes source tags.
+// Target tag is created when missing, posts that already have it keep one link.
+// Aliases of source tags are re-pointed to the target.
+func (r mediaRepo) MergeTags(target string, sources []string) error {
+	return r.inTransaction(func(tx *sqlx.Tx) error {
+		targetTag := Tag{}
+		err := tx.Get(&targetTag, "SELECT id, name FROM tags WHERE name = ?", target)
+		if err != nil {
+			res, err := tx.Exec(
+				"INSERT INTO tags (created_at, updated_at, name) VALUES (?, ?, ?)",
+				time.Now(), time.Now(), target,
+			)
+			if err != nil {
+				return err
+			}
+			tagID, err := res.LastInsertId()
+			if err != nil {
+				return err
+			}
+			targetTag = Tag{ID: uint(tagID), Name: target}
+		}
+
+		merged := map[string]bool{target: true}
+		for _, source := range sources {
+			if merged[source] {
+				continue
+			}
+			merged[source] = true
+			sourceTag := Tag{}
+			err := tx.Get(&sourceTag, "SELECT id, name FROM tags WHERE name = ?", source)
+			if err != nil {
+				return fmt.Errorf("tag [%s] not found", source)
+			}
+
+			for _, table := range []string{"posts_tags", "posts_suggested_tags"} {
+				_, err = tx.Exec(fmt.Sprintf(
+					`INSERT OR IGNORE INTO %[1]s (post_id, tag_id)
+					SELECT post_id, ? FROM %[1]s WHERE tag_id = ?`, table),
+					targetTag.ID, sourceTag.ID,
+				)
+				if err == nil {
+					_, err = tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE tag_id = ?", table), sourceTag.ID)
+				}
+				if err != nil {
+					return err
+				}
+			}
+			_, err = tx.Exec("DELETE FROM tags WHERE id = ?", sourceTag.ID)
+			if err == nil {
+				_, err = tx.Exec("UPDATE tag_aliases SET tag_name = ? WHERE tag_name = ?", target, source)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// DeleteTag removes the tag from all posts, its aliases are removed as well
+func (r mediaRepo) DeleteTag(name string) error {
+	tag, err := r.findTag(name)
+	if err != nil {
+		return fmt.Errorf("tag [%s] not found", name)
+	}
+	return r.inTransaction(func(tx *sqlx.Tx) error {
+		for _, statement := range []string{
+			"DELETE FROM posts_tags WHERE tag_id = ?",
+			"DELETE FROM posts_suggested_tags WHERE tag_id = ?",
+			"DELETE FROM tags WHERE id = ?",
+		} {
+			if _, err := tx.Exec(statement, tag.ID); err != nil {
+				return err
+			}
+		}
+		_, err := tx.Exec("DELETE FROM tag_aliases WHERE tag_name = ?", name)
+		return err
+	})
+}
+
+// AddTagAlias makes synced posts tagged with alias get the tag instead, existing alias is replaced.
+// Aliases of the alias are re-pointed to the tag, so aliases never chain.
+func (r mediaRepo) AddTagAlias(alias, tagName string) error {
+	tagName = r.ResolveTag(tagName)
+	if alias == tagName {
+		return fmt.Errorf("tag [%s] can't be an alias of itself", alias)
+	}
+	return r.inTransaction(func(tx *sqlx.Tx) error {
+		_, err := tx.Exec("INSERT OR REPLACE INTO tag_aliases (alias, tag_name) VALUES (?, ?)", alias, tagName)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec("UPDATE tag_aliases SET tag_name = ? WHERE tag_name = ?", tagName, alias)
+		return err
+	})
+}
+
+func (r mediaRepo) RemoveTagAlias(alias string) error {
+	res, err := r.DB.Exec("DELETE FROM tag_aliases WHERE alias = ?", alias)
+	if err != nil {
+		return err
+	}
+	if removed, _ := res.RowsAffected(); removed == 0 {
+		return fmt.Errorf("alias [%s] not found", alias)
+	}
+	return nil
+}
+
+func (r mediaRepo) ListTagAliases() ([]TagAlias, error) {
+	var aliases []TagAlias
+	err := r.DB.Select(&aliases, "SELECT alias, tag_name FROM tag_aliases ORDER BY tag_name, alias")
+	return aliases, err
+}
+
+// ResolveTag returns tag name the alias points to, other names are returned as is
+func (r mediaRepo) ResolveTag(name string) string {
+	var tagName string
+	err := r.DB.Get(&tagName, "SELECT tag_name FROM tag_aliases WHERE alias = ?", name)
+	if err != nil {
+		return name
+	}
+	return tagName
+}
+
+// TagChange is a tag renamed or merged by normalization, empty To means the tag was deleted
+type TagChange struct {
+	From string
+	To   string
+}
+
+// NormalizeTags applies normalizer to stored tags the way sync applies it to new ones.
+// Tags are merged into the tag with normalized name (created when missing),
+// tags normalized to nothing are deleted.
+func (r mediaRepo) NormalizeTags(normalizer TagNormalizer) ([]TagChange, error) {
+	var names []string
+	err := r.DB.Select(&names, "SELECT name FROM tags ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	changes := []TagChange{}
+	for _, name := range names {
+		to := normalizer.Normalize(name)
+		if to != "" {
+			to = r.ResolveTag(to)
+		}
+		if to == name {
+			continue
+		}
+		if to == "" {
+			err = r.DeleteTag(name)
+		} else {
+			err = r.MergeTags(to, []string{name})
+		}
+		if err != nil {
+			return changes, err
+		}
+		changes = append(changes, TagChange{From: name, To: to})
+	}
+	return changes, nil
+}
+
+func (r mediaRepo) inTransaction(body func(tx *sqlx.Tx) error) error {
+	tx, err := r.DB.Beginx()
+	if err != nil {
+		return err
+	}
+	err = body(tx)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
 func (r mediaRepo) findTag(tagName string) (Tag, error) {
 	tag := Tag{}
 	err := r.DB.Get(&tag, "SELECT id, name from tags where name = ?", tagName)
 	return tag, err
 }
 
+func (r mediaRepo) findOrAddTag(tagName string) (Tag, error) {
+	tag, err := r.findTag(tagName)
+	if err != nil {
+		tag = Tag{Name: tagName}
+		err = r.AddTag(&tag)
+
+		if err != nil {
+			return tag, err
+		}
+
+		tag, err = r.findTag(tagName)
+	}
+	return tag, err
+}
+
 // TagsSchema represents schema for "tags" table
 var TagsSchema = `CREATE TABLE "tags" (
 	"id" integer PRIMARY KEY AUTOINCREMENT,
@@ -75,3 +335,9 @@ var PostsTagsSchema = `CREATE TABLE "posts_tags" (
 	"tag_id" integer,
 	PRIMARY KEY ("post_id","tag_id")
 )`
+
+// TagAliasesSchema represents schema for "tag_aliases" table
+var TagAliasesSchema = `CREATE TABLE "tag_aliases" (
+	"alias" varchar(255) PRIMARY KEY,
+	"tag_name" varchar(255)
+)`
@@ -2,7 +2,9 @@ package media
 
 import (
 	"errors"
+	"fmt"
 	"github.com/stretchr/testify/assert"
+	"strconv"
 	"testing"
 )
 
@@ -62,3 +64,217 @@ func TestAddTagToPost(t *testing.T) {
 		t.Errorf("Expected tags count to be [2], got [%d]", tagsCount)
 	}
 }
+
+// tagsFixture creates posts tagged with messy variants of the same tag:
+// post 1 [cats Cats], post 2 [Cats #cats], post 3 [cat], post 4 [dogs]
+func tagsFixture(t *testing.T) []*Post {
+	var posts []*Post
+	for i, tags := range [][]string{{"cats", "Cats"}, {"Cats", "#cats"}, {"cat"}, {"dogs"}} {
+		post := &Post{Status: "added", Source: "tumblr", ExternalID: strconv.Itoa(i + 1)}
+		checkErrors(t, nil, repo.AddPost(post))
+		for _, tag := range tags {
+			checkErrors(t, nil, repo.AddTagToPost(post, tag))
+		}
+		posts = append(posts, post)
+	}
+	return posts
+}
+
+// postTags returns tag names of every post by post ID
+func postTags() map[uint][]string {
+	var rows []struct {
+		PostID uint `db:"post_id"`
+		Name   string
+	}
+	DB.Select(&rows, `SELECT posts_tags.post_id, tags.name FROM posts_tags
+		INNER JOIN tags ON tags.id = posts_tags.tag_id ORDER BY posts_tags.post_id, tags.name`)
+	tags := map[uint][]string{}
+	for _, row := range rows {
+		tags[row.PostID] = append(tags[row.PostID], row.Name)
+	}
+	return tags
+}
+
+func tagNames() []string {
+	var names []string
+	DB.Select(&names, "SELECT name FROM tags ORDER BY name")
+	return names
+}
+
+func TestListTags(t *testing.T) {
+	teardown := setup()
+	defer teardown()
+
+	tagsFixture(t)
+	repo.AddTag(&Tag{Name: "unused"})
+
+	tags, err := repo.ListTags()
+	checkErrors(t, nil, err)
+	var counts []string
+	for _, tag := range tags {
+		counts = append(counts, fmt.Sprintf("%s:%d", tag.Name, tag.Posts))
+	}
+	assert.Equal(t, []string{"Cats:2", "#cats:1", "cat:1", "cats:1", "dogs:1", "unused:0"}, counts)
+}
+
+func TestRenameTag(t *testing.T) {
+	teardown := setup()
+	defer teardown()
+
+	posts := tagsFixture(t)
+	repo.AddTagAlias("doggy", "dogs")
+
+	checkErrors(t, nil, repo.RenameTag("dogs", "dog"))
+	assert.Equal(t, []string{"dog"}, postTags()[posts[3].ID])
+	assert.Equal(t, "dog", repo.ResolveTag("doggy"))
+
+	checkErrors(t, errors.New("tag [cat] already exists, merge tags instead"), repo.RenameTag("dog", "cat"))
+	checkErrors(t, errors.New("tag [birds] not found"), repo.RenameTag("birds", "bird"))
+}
+
+func TestMergeTags(t *testing.T) {
+	teardown := setup()
+	defer teardown()
+
+	posts := tagsFixture(t)
+	repo.AddTagAlias("kitty", "Cats")
+	repo.AddTagAlias("kitten", "cat")
+
+	checkErrors(t, nil, repo.MergeTags("cats", []string{"Cats", "#cats", "cat", "Cats", "cats"}))
+
+	// posts with several merged tags get exactly one link to the target
+	tags := postTags()
+	assert.Equal(t, []string{"cats"}, tags[posts[0].ID])
+	assert.Equal(t, []string{"cats"}, tags[posts[1].ID])
+	assert.Equal(t, []string{"cats"}, tags[posts[2].ID])
+	assert.Equal(t, []string{"dogs"}, tags[posts[3].ID])
+	assert.Equal(t, []string{"cats", "dogs"}, tagNames())
+
+	var links int
+	DB.Get(&links, "SELECT count(*) FROM posts_tags")
+	assert.Equal(t, 4, links)
+
+	// aliases of merged tags point to the target
+	assert.Equal(t, "cats", repo.ResolveTag("kitty"))
+	assert.Equal(t, "cats", repo.ResolveTag("kitten"))
+}
+
+func TestMergeTagsIntoNewTag(t *testing.T) {
+	teardown := setup()
+	defer teardown()
+
+	posts := tagsFixture(t)
+
+	checkErrors(t, nil, repo.MergeTags("animals", []string{"cat", "dogs"}))
+
+	tags := postTags()
+	assert.Equal(t, []string{"Cats", "cats"}, tags[posts[0].ID])
+	assert.Equal(t, []string{"animals"}, tags[posts[2].ID])
+	assert.Equal(t, []string{"animals"}, tags[posts[3].ID])
+	assert.Equal(t, []string{"#cats", "Cats", "animals", "cats"}, tagNames())
+}
+
+func TestMergeTagsRollsBackOnError(t *testing.T) {
+	teardown := setup()
+	defer teardown()
+
+	tagsFixture(t)
+	before := postTags()
+
+	err := repo.MergeTags("felines", []string{"cats", "Cats", "missing"})
+	checkErrors(t, errors.New("tag [missing] not found"), err)
+
+	assert.Equal(t, before, postTags())
+	assert.Equal(t, []string{"#cats", "Cats", "cat", "cats", "dogs"}, tagNames())
+}
+
+func TestDeleteTag(t *testing.T) {
+	teardown := setup()
+	defer teardown()
+
+	posts := tagsFixture(t)
+	repo.AddTagAlias("kitty", "Cats")
+
+	checkErrors(t, nil, repo.DeleteTag("Cats"))
+	tags := postTags()
+	assert.Equal(t, []string{"cats"}, tags[posts[0].ID])
+	assert.Equal(t, []string{"#cats"}, tags[posts[1].ID])
+	assert.NotContains(t, tagNames(), "Cats")
+	assert.Equal(t, "kitty", repo.ResolveTag("kitty"))
+
+	checkErrors(t, errors.New("tag [Cats] not found"), repo.DeleteTag("Cats"))
+}
+
+func TestTagAliases(t *testing.T) {
+	teardown := setup()
+	defer teardown()
+
+	checkErrors(t, nil, repo.AddTagAlias("Cats", "cats"))
+	checkErrors(t, nil, repo.AddTagAlias("kitty", "Cats"))
+	checkErrors(t, nil, repo.AddTagAlias("#cats", "cat"))
+	checkErrors(t, nil, repo.AddTagAlias("#cats", "cats"))
+	checkErrors(t, errors.New("tag [cats] can't be an alias of itself"), repo.AddTagAlias("cats", "Cats"))
+
+	assert.Equal(t, "cats", repo.ResolveTag("cats"))
+	assert.Equal(t, "cats", repo.ResolveTag("Cats"))
+	assert.Equal(t, "cats", repo.ResolveTag("kitty"))
+	assert.Equal(t, "CATS", repo.ResolveTag("CATS"))
+	assert.Equal(t, "dogs", repo.ResolveTag("dogs"))
+
+	aliases, err := repo.ListTagAliases()
+	checkErrors(t, nil, err)
+	assert.Equal(t, []TagAlias{{"#cats", "cats"}, {"Cats", "cats"}, {"kitty", "cats"}}, aliases)
+
+	checkErrors(t, nil, repo.RemoveTagAlias("kitty"))
+	checkErrors(t, errors.New("alias [kitty] not found"), repo.RemoveTagAlias("kitty"))
+	assert.Equal(t, "kitty", repo.ResolveTag("kitty"))
+}
+
+func TestChainedTagAliases(t *testing.T) {
+	teardown := setup()
+	defer teardown()
+
+	checkErrors(t, nil, repo.AddTagAlias("kitty", "kitten"))
+	checkErrors(t, nil, repo.AddTagAlias("kitten", "cat"))
+	checkErrors(t, nil, repo.AddTagAlias("cat", "cats"))
+	checkErrors(t, errors.New("tag [cats] can't be an alias of itself"), repo.AddTagAlias("cats", "kitty"))
+
+	assert.Equal(t, "cats", repo.ResolveTag("kitty"))
+	assert.Equal(t, "cats", repo.ResolveTag("kitten"))
+	assert.Equal(t, "cats", repo.ResolveTag("cat"))
+
+	aliases, err := repo.ListTagAliases()
+	checkErrors(t, nil, err)
+	assert.Equal(t, []TagAlias{{"cat", "cats"}, {"kitten", "cats"}, {"kitty", "cats"}}, aliases)
+}
+
+func TestNormalizeTags(t *testing.T) {
+	teardown := setup()
+	defer teardown()
+
+	posts := tagsFixture(t)
+	checkErrors(t, nil, repo.AddTagToPost(posts[3], "Reblog"))
+	checkErrors(t, nil, repo.AddTagAlias("cat", "cats"))
+
+	normalizer := TagNormalizer{CaseFold: true, StripPrefixes: []string{"#"}, StopList: []string{"reblog"}}
+	changes, err := repo.NormalizeTags(normalizer)
+	checkErrors(t, nil, err)
+	assert.Equal(t, []TagChange{
+		{From: "#cats", To: "cats"},
+		{From: "Cats", To: "cats"},
+		{From: "Reblog", To: ""},
+		{From: "cat", To: "cats"},
+	}, changes)
+
+	assert.Equal(t, []string{"cats", "dogs"}, tagNames())
+	assert.Equal(t, map[uint][]string{
+		posts[0].ID: {"cats"},
+		posts[1].ID: {"cats"},
+		posts[2].ID: {"cats"},
+		posts[3].ID: {"dogs"},
+	}, postTags())
+
+	changes, err = repo.NormalizeTags(normalizer)
+	checkErrors(t, nil, err)
+	assert.Empty(t, changes)
+}
@@ -7,6 +7,12 @@ import (
 func (r mediaRepo) AddText(text *Text) error {
 	text.CreatedAt = time.Now()
 	text.UpdatedAt = time.Now()
+	record := *text
+	body, err := r.sealColumn(text.Body)
+	if err != nil {
+		return err
+	}
+	record.Body = body
 	res, err := r.DB.NamedExec(
 		`INSERT INTO texts (
 	    created_at, updated_at, post_id, title, body
@@ -14,7 +20,7 @@ func (r mediaRepo) AddText(text *Text) error {
 	  VALUES (
 	    :created_at, :updated_at, :post_id, :title, :body
 	  )`,
-		text,
+		record,
 	)
 	if err != nil {
 		return err
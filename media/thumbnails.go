@@ -0,0 +1,151 @@
+package media
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+
+	"github.com/HugoSmits86/nativewebp"
+	"github.com/spf13/viper"
+	"golang.org/x/image/draw"
+)
+
+const thumbnailJPEGQuality = 85
+
+// thumbnailSizes returns configured sizes (longest side in pixels) of generated thumbnails
+func thumbnailSizes() []int {
+	sizes := viper.GetIntSlice("thumbnails.sizes")
+	if len(sizes) == 0 {
+		return []int{256}
+	}
+	return sizes
+}
+
+// thumbnailFormat returns configured format of generated thumbnails: jpeg or webp
+func thumbnailFormat() string {
+	if viper.GetString("thumbnails.format") == "webp" {
+		return "webp"
+	}
+	return "jpeg"
+}
+
+func thumbnailExtension() string {
+	if thumbnailFormat() == "webp" {
+		return ".webp"
+	}
+	return ".jpg"
+}
+
+// ThumbnailFileName returns local file name where photo thumbnail of given size (should be) stored
+func (photo Photo) ThumbnailFileName(size int) string {
+	return fmt.Sprintf("photo_%d_thumb_%d%s", photo.ID, size, thumbnailExtension())
+}
+
+// PosterFileName returns local file name where video poster of given size (should be) stored
+func (video Video) PosterFileName(size int) string {
+	return fmt.Sprintf("video_%d_poster_%d%s", video.ID, size, thumbnailExtension())
+}
+
+func (r mediaRepo) GetPhotoThumbnailPath(photo *Photo, size int) string {
+	return r.Store.Location(photo.ThumbnailFileName(size))
+}
+
+func (r mediaRepo) GetVideoPosterPath(video *Video, size int) string {
+	return r.Store.Location(video.PosterFileName(size))
+}
+
+// renderThumbnail scales image down so that its longest side fits the size
+// and encodes it in configured format. Images are never scaled up.
+func renderThumbnail(img image.Image, w io.Writer, size int, format string) error {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width > size || height > size {
+		if width >= height {
+			width, height = size, height*size/width
+		} else {
+			width, height = width*size/height, size
+		}
+	}
+	if width < 1 {
+		width = 1
+	}
+	if height < 1 {
+		height = 1
+	}
+
+	thumbnail := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(thumbnail, thumbnail.Bounds(), img, bounds, draw.Src, nil)
+
+	if format == "webp" {
+		return nativewebp.Encode(w, thumbnail, nil)
+	}
+	return jpeg.Encode(w, thumbnail, &jpeg.Options{Quality: thumbnailJPEGQuality})
+}
+
+// generateThumbnails renders thumbnails of source image file in every configured size
+func generateThumbnails(store Store, sourceName string, thumbnailName func(size int) string) error {
+	source, err := store.Open(sourceName)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	img, _, err := image.Decode(source)
+	if err != nil {
+		return err
+	}
+	for _, size := range thumbnailSizes() {
+		var thumbnail bytes.Buffer
+		err = renderThumbnail(img, &thumbnail, size, thumbnailFormat())
+		if err != nil {
+			return err
+		}
+		err = store.Put(thumbnailName(size), &thumbnail)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r mediaRepo) generatePhotoThumbnails(photo *Photo) error {
+	return generateThumbnails(r.Store, photo.FileName(), photo.ThumbnailFileName)
+}
+
+func (r mediaRepo) generateVideoPosters(video *Video) error {
+	return generateThumbnails(r.Store, video.ThumbnailFileName(), video.PosterFileName)
+}
+
+// RebuildThumbnails renders thumbnails of all stored photos and video posters,
+// returns number of media objects processed successfully and failed ones
+func (r mediaRepo) RebuildThumbnails() (int, int, error) {
+	var photos []Photo
+	err := r.DB.Select(&photos, "SELECT id, post_id, external_url, mime_type FROM photos WHERE "+livePosts+" ORDER BY id")
+	if err != nil {
+		return 0, 0, err
+	}
+	var videos []Video
+	err = r.DB.Select(&videos, "SELECT id, post_id, external_url, thumbnail_url, mime_type, thumbnail_mime_type FROM videos WHERE "+livePosts+" ORDER BY id")
+	if err != nil {
+		return 0, 0, err
+	}
+
+	rebuilt, failed := 0, 0
+	for i := range photos {
+		if r.generatePhotoThumbnails(&photos[i]) != nil {
+			failed++
+			continue
+		}
+		rebuilt++
+	}
+	for i := range videos {
+		if r.generateVideoPosters(&videos[i]) != nil {
+			failed++
+			continue
+		}
+		rebuilt++
+	}
+	return rebuilt, failed, nil
+}
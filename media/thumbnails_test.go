@@ -0,0 +1,109 @@
+package media
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"os"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	httpmock "gopkg.in/jarcoal/httpmock.v1"
+)
+
+func TestThumbnailFileNames(t *testing.T) {
+	assert.Equal(t, "photo_12_thumb_256.jpg", Photo{ID: 12}.ThumbnailFileName(256))
+	assert.Equal(t, "video_3_poster_640.jpg", Video{ID: 3}.PosterFileName(640))
+
+	viper.Set("thumbnails.format", "webp")
+	defer viper.Set("thumbnails.format", "")
+
+	assert.Equal(t, "photo_12_thumb_256.webp", Photo{ID: 12}.ThumbnailFileName(256))
+	assert.Equal(t, "video_3_poster_640.webp", Video{ID: 3}.PosterFileName(640))
+}
+
+func TestRenderThumbnail(t *testing.T) {
+	testCases := []struct {
+		img            image.Image
+		size           int
+		expectedWidth  int
+		expectedHeight int
+	}{
+		{testImage(400, 300, false), 128, 128, 96},
+		{testImage(300, 400, false), 128, 96, 128},
+		{testImage(1000, 10, false), 100, 100, 1},
+		{testImage(64, 48, false), 256, 64, 48},
+	}
+
+	for _, testCase := range testCases {
+		var buf bytes.Buffer
+		err := renderThumbnail(testCase.img, &buf, testCase.size, "jpeg")
+		checkErrors(t, nil, err)
+
+		thumbnail, err := jpeg.Decode(&buf)
+		checkErrors(t, nil, err)
+		assert.Equal(t, testCase.expectedWidth, thumbnail.Bounds().Dx())
+		assert.Equal(t, testCase.expectedHeight, thumbnail.Bounds().Dy())
+	}
+}
+
+func TestRenderThumbnailWebP(t *testing.T) {
+	var buf bytes.Buffer
+	err := renderThumbnail(testImage(400, 300, false), &buf, 128, "webp")
+	checkErrors(t, nil, err)
+
+	contents := buf.Bytes()
+	if assert.True(t, len(contents) > 12) {
+		assert.Equal(t, "RIFF", string(contents[0:4]))
+		assert.Equal(t, "WEBP", string(contents[8:12]))
+	}
+}
+
+func TestAddPhotoThumbnails(t *testing.T) {
+	teardown := setup()
+	defer teardown()
+
+	viper.Set("thumbnails.sizes", []int{32, 128})
+	defer viper.Set("thumbnails.sizes", nil)
+
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	photo := Photo{ExternalURL: "http://example.com/thumbnailed.png", PostID: 1}
+	httpmock.RegisterResponder("GET", photo.ExternalURL,
+		httpmock.NewBytesResponder(200, encodePNG(testImage(400, 200, false))))
+
+	err := repo.AddPhoto(&photo)
+	checkErrors(t, nil, err)
+	defer removePhotoFiles(&photo)
+
+	checkThumbnail := func(size, expectedWidth, expectedHeight int) {
+		file, err := os.Open(repo.GetPhotoThumbnailPath(&photo, size))
+		if err != nil {
+			t.Errorf("Thumbnail of size [%d] is not generated: [%s]", size, err)
+			return
+		}
+		defer file.Close()
+		config, err := jpeg.DecodeConfig(file)
+		checkErrors(t, nil, err)
+		assert.Equal(t, expectedWidth, config.Width)
+		assert.Equal(t, expectedHeight, config.Height)
+	}
+	checkThumbnail(32, 32, 16)
+	checkThumbnail(128, 128, 64)
+
+	// thumbnails are regenerated for existing files
+	removePhotoFiles(&photo)
+	httpmock.Reset()
+	file, _ := os.Create(repo.GetPhotoPath(&photo))
+	file.Write(encodePNG(testImage(100, 400, false)))
+	file.Close()
+
+	rebuilt, failed, err := repo.RebuildThumbnails()
+	checkErrors(t, nil, err)
+	assert.Equal(t, 1, rebuilt)
+	assert.Equal(t, 0, failed)
+	checkThumbnail(32, 8, 32)
+	checkThumbnail(128, 32, 128)
+}
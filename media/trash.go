@@ -0,0 +1,184 @@
+package media
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// trashPrefix is prepended to names of media files of deleted posts,
+// such files don't look like media files to fsck and are kept until trash is emptied
+const trashPrefix = "trash_"
+
+// postStatusPurged marks posts removed from the trash, their rows are kept
+// so that sync doesn't import them again
+const postStatusPurged = "purged"
+
+// livePosts is SQL condition on "post_id" column which skips media of deleted posts
+const livePosts = "post_id NOT IN (SELECT id FROM posts WHERE deleted_at IS NOT NULL)"
+
+// DeletePost marks post as deleted and moves its media files to the trash,
+// deleted posts are hidden from listings until they are restored
+func (r mediaRepo) DeletePost(id uint) error {
+	post, err := r.GetPost(id)
+	if err != nil {
+		return err
+	}
+	if post.DeletedAt != nil {
+		return fmt.Errorf("post [%d] is already deleted", id)
+	}
+	names, err := r.postFileNames(id)
+	if err != nil {
+		return err
+	}
+	return r.moveInTransaction(names, "", trashPrefix, func(tx *sqlx.Tx) error {
+		_, err := tx.Exec("UPDATE posts SET deleted_at = ? WHERE id = ?", time.Now(), id)
+		return err
+	})
+}
+
+// RestorePost brings deleted post and its media files back from the trash
+func (r mediaRepo) RestorePost(id uint) error {
+	post, err := r.GetPost(id)
+	if err != nil {
+		return err
+	}
+	if post.DeletedAt == nil {
+		return fmt.Errorf("post [%d] is not deleted", id)
+	}
+	if post.Status == postStatusPurged {
+		return fmt.Errorf("post [%d] is purged from the trash", id)
+	}
+	names, err := r.postFileNames(id)
+	if err != nil {
+		return err
+	}
+	return r.moveInTransaction(names, trashPrefix, "", func(tx *sqlx.Tx) error {
+		_, err := tx.Exec("UPDATE posts SET deleted_at = NULL WHERE id = ?", id)
+		return err
+	})
+}
+
+// ListTrash returns deleted posts which could be restored, recently deleted go first
+func (r mediaRepo) ListTrash() ([]Post, error) {
+	var posts []Post
+	err := r.DB.Select(
+		&posts,
+		"SELECT * FROM posts WHERE deleted_at IS NOT NULL AND status != ? ORDER BY deleted_at DESC, id",
+		postStatusPurged,
+	)
+	return posts, err
+}
+
+// EmptyTrash purges posts deleted before given time: their media files, media records,
+// tags and collection entries are removed. Post rows are kept marked as purged.
+// Returns number of purged posts.
+func (r mediaRepo) EmptyTrash(deletedBefore time.Time) (int, error) {
+	var ids []uint
+	err := r.DB.Select(
+		&ids, "SELECT id FROM posts WHERE deleted_at < ? AND status != ? ORDER BY id",
+		deletedBefore, postStatusPurged,
+	)
+	if err != nil {
+		return 0, err
+	}
+	for i, id := range ids {
+		names, err := r.postFileNames(id)
+		if err != nil {
+			return i, err
+		}
+		for _, name := range names {
+			err = r.Store.Delete(trashPrefix + name)
+			if err != nil && !os.IsNotExist(err) {
+				return i, err
+			}
+		}
+		err = r.inTransaction(func(tx *sqlx.Tx) error {
+			for _, table := range []string{"photos", "videos", "texts", "links", "posts_tags", "posts_suggested_tags", "collections_posts"} {
+				if _, err := tx.Exec("DELETE FROM "+table+" WHERE post_id = ?", id); err != nil {
+					return err
+				}
+			}
+			_, err := tx.Exec("UPDATE posts SET status = ? WHERE id = ?", postStatusPurged, id)
+			return err
+		})
+		if err != nil {
+			return i, err
+		}
+	}
+	return len(ids), nil
+}
+
+// postFileNames returns names of all media files of the post: photos, videos and their thumbnails
+func (r mediaRepo) postFileNames(postID uint) ([]string, error) {
+	var photos []Photo
+	err := r.DB.Select(&photos, "SELECT id, external_url, mime_type FROM photos WHERE post_id = ? ORDER BY id", postID)
+	if err != nil {
+		return nil, err
+	}
+	var videos []Video
+	err = r.DB.Select(
+		&videos,
+		"SELECT id, external_url, thumbnail_url, mime_type, thumbnail_mime_type FROM videos WHERE post_id = ? ORDER BY id",
+		postID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, photo := range photos {
+		names = append(names, photo.FileName())
+		for _, size := range thumbnailSizes() {
+			names = append(names, photo.ThumbnailFileName(size))
+		}
+	}
+	for _, video := range videos {
+		names = append(names, video.FileName(), video.ThumbnailFileName())
+		for _, size := range thumbnailSizes() {
+			names = append(names, video.PosterFileName(size))
+		}
+	}
+	return names, nil
+}
+
+// moveExistingFile moves file when it exists, missing files are not an error
+// moveInTransaction updates rows and moves files changing their prefixes in one transaction:
+// the update is rolled back when any file can't be moved and moved files are put back
+// when the transaction fails
+func (r mediaRepo) moveInTransaction(names []string, fromPrefix, toPrefix string, update func(tx *sqlx.Tx) error) error {
+	moved := 0
+	err := r.inTransaction(func(tx *sqlx.Tx) error {
+		err := update(tx)
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			err = moveExistingFile(r.Store, fromPrefix+name, toPrefix+name)
+			if err != nil {
+				return err
+			}
+			moved++
+		}
+		return nil
+	})
+	if err != nil {
+		for _, name := range names[:moved] {
+			moveExistingFile(r.Store, toPrefix+name, fromPrefix+name)
+		}
+	}
+	return err
+}
+
+func moveExistingFile(store Store, from, to string) error {
+	_, err := store.Stat(from)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return moveFile(store, from, to)
+}
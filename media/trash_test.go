@@ -0,0 +1,149 @@
+package media
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupTrash(t *testing.T) (string, Post, func()) {
+	teardown := setup()
+
+	folder, err := ioutil.TempDir("", "bellboy_trash")
+	checkErrors(t, nil, err)
+	viper.Set("media_folder", folder)
+	repo = NewRepository(DB)
+
+	post := Post{Status: "added", Source: "tumblr", Type: "photo", ExternalID: "100"}
+	checkErrors(t, nil, repo.AddPost(&post))
+	DB.Exec(`INSERT INTO photos (id, created_at, updated_at, post_id, caption, external_url, sfw, mime_type, phash, byte_size)
+		VALUES (1, ?, ?, ?, '', 'http://example.com/photo.gif', 0, 'image/gif', 42, 10)`, time.Now(), time.Now(), post.ID)
+	checkErrors(t, nil, repo.AddTagToPost(&post, "cats"))
+	checkErrors(t, nil, repo.AddCollection(&Collection{Name: "best"}))
+	checkErrors(t, nil, repo.AddPostToCollection("best", post.ID))
+	for _, name := range []string{"photo_1.gif", "photo_1_thumb_256.jpg"} {
+		checkErrors(t, nil, ioutil.WriteFile(filepath.Join(folder, name), []byte(name), 0644))
+	}
+
+	return folder, post, func() {
+		viper.Set("media_folder", "./")
+		os.RemoveAll(folder)
+		teardown()
+	}
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func TestDeleteAndRestorePost(t *testing.T) {
+	folder, post, teardown := setupTrash(t)
+	defer teardown()
+
+	checkErrors(t, nil, repo.DeletePost(post.ID))
+	checkErrors(t, errors.New("post [1] is already deleted"), repo.DeletePost(post.ID))
+	checkErrors(t, errors.New("post [42] not found"), repo.DeletePost(42))
+	assert.False(t, fileExists(filepath.Join(folder, "photo_1.gif")))
+	assert.True(t, fileExists(filepath.Join(folder, "trash_photo_1.gif")))
+	assert.True(t, fileExists(filepath.Join(folder, "trash_photo_1_thumb_256.jpg")))
+
+	// deleted posts are hidden from listings but are known to sync
+	assert.True(t, repo.PostExistsWithExternalID("100"))
+	photos, err := repo.FindPhotos(PhotoFilter{})
+	checkErrors(t, nil, err)
+	assert.Empty(t, photos)
+	photos, err = repo.ListFingerprintedPhotos()
+	checkErrors(t, nil, err)
+	assert.Empty(t, photos)
+	posts, err := repo.ListCollectionPosts("best")
+	checkErrors(t, nil, err)
+	assert.Empty(t, posts)
+	tags, err := repo.ListTags()
+	checkErrors(t, nil, err)
+	assert.Equal(t, 0, tags[0].Posts)
+	report, err := repo.Fsck(FsckOptions{})
+	checkErrors(t, nil, err)
+	assert.Empty(t, report.Issues)
+
+	trash, err := repo.ListTrash()
+	checkErrors(t, nil, err)
+	if assert.Len(t, trash, 1) {
+		assert.Equal(t, post.ID, trash[0].ID)
+		assert.NotNil(t, trash[0].DeletedAt)
+	}
+
+	checkErrors(t, nil, repo.RestorePost(post.ID))
+	checkErrors(t, errors.New("post [1] is not deleted"), repo.RestorePost(post.ID))
+	assert.True(t, fileExists(filepath.Join(folder, "photo_1.gif")))
+	assert.True(t, fileExists(filepath.Join(folder, "photo_1_thumb_256.jpg")))
+	assert.False(t, fileExists(filepath.Join(folder, "trash_photo_1.gif")))
+	photos, err = repo.FindPhotos(PhotoFilter{})
+	checkErrors(t, nil, err)
+	assert.Len(t, photos, 1)
+	trash, err = repo.ListTrash()
+	checkErrors(t, nil, err)
+	assert.Empty(t, trash)
+}
+
+func TestDeletePostRollsBack(t *testing.T) {
+	folder, post, teardown := setupTrash(t)
+	defer teardown()
+
+	// thumbnail can't be moved over non-empty folder
+	blocker := filepath.Join(folder, "trash_photo_1_thumb_256.jpg")
+	checkErrors(t, nil, os.MkdirAll(filepath.Join(blocker, "file"), 0755))
+
+	assert.NotNil(t, repo.DeletePost(post.ID))
+	assert.True(t, fileExists(filepath.Join(folder, "photo_1.gif")))
+	assert.True(t, fileExists(filepath.Join(folder, "photo_1_thumb_256.jpg")))
+	assert.False(t, fileExists(filepath.Join(folder, "trash_photo_1.gif")))
+	stored, err := repo.GetPost(post.ID)
+	checkErrors(t, nil, err)
+	assert.Nil(t, stored.DeletedAt)
+
+	checkErrors(t, nil, os.RemoveAll(blocker))
+	checkErrors(t, nil, repo.DeletePost(post.ID))
+	assert.True(t, fileExists(filepath.Join(folder, "trash_photo_1_thumb_256.jpg")))
+}
+
+func TestEmptyTrash(t *testing.T) {
+	folder, post, teardown := setupTrash(t)
+	defer teardown()
+
+	checkErrors(t, nil, repo.DeletePost(post.ID))
+
+	purged, err := repo.EmptyTrash(time.Now().Add(-time.Hour))
+	checkErrors(t, nil, err)
+	assert.Equal(t, 0, purged)
+	assert.True(t, fileExists(filepath.Join(folder, "trash_photo_1.gif")))
+
+	purged, err = repo.EmptyTrash(time.Now().Add(time.Second))
+	checkErrors(t, nil, err)
+	assert.Equal(t, 1, purged)
+	assert.False(t, fileExists(filepath.Join(folder, "trash_photo_1.gif")))
+	assert.False(t, fileExists(filepath.Join(folder, "trash_photo_1_thumb_256.jpg")))
+
+	var count int
+	DB.Get(&count, "SELECT count(*) FROM photos")
+	assert.Equal(t, 0, count)
+	DB.Get(&count, "SELECT count(*) FROM posts_tags")
+	assert.Equal(t, 0, count)
+	DB.Get(&count, "SELECT count(*) FROM collections_posts")
+	assert.Equal(t, 0, count)
+
+	assert.True(t, repo.PostExistsWithExternalID("100"))
+	trash, err := repo.ListTrash()
+	checkErrors(t, nil, err)
+	assert.Empty(t, trash)
+	checkErrors(t, errors.New("post [1] is purged from the trash"), repo.RestorePost(post.ID))
+	purged, err = repo.EmptyTrash(time.Now().Add(time.Second))
+	checkErrors(t, nil, err)
+	assert.Equal(t, 0, purged)
+}
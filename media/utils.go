@@ -1,31 +1,43 @@
 package media
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
-	"os"
 	"regexp"
 )
 
-func download(url, filePath string) error {
-	file, err := os.Create(filePath)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-	resp, err := http.Get(url)
+// download saves remote file to the store, its content type is sniffed from
+// the first bytes before file name is chosen so the name could depend on it
+func download(store Store, task downloadTask) error {
+	resp, err := http.Get(task.url)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("download of [%s] failed with status [%s]", task.url, resp.Status)
+	}
 
-	_, err = io.Copy(file, resp.Body)
-	if err != nil {
+	head := make([]byte, sniffLength)
+	n, err := io.ReadFull(resp.Body, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
 		return err
 	}
-	return nil
+	// short body is read completely already
+	complete := err != nil
+	head = head[:n]
+	if task.mimeType != nil {
+		*task.mimeType = sniffMimeType(head, resp.Header.Get("Content-Type"))
+	}
+
+	var contents io.Reader = bytes.NewReader(head)
+	if !complete {
+		contents = io.MultiReader(contents, resp.Body)
+	}
+	return store.Put(task.name(), contents)
 }
 
 func extension(url string) string {
@@ -38,8 +50,10 @@ func extension(url string) string {
 }
 
 type mediaTransaction struct {
-	err            error
-	insertCallback func() error
+	store            Store
+	err              error
+	insertCallback   func() error
+	rollbackCallback func() error // removes inserted record when download fails
 }
 
 func (trx *mediaTransaction) validateUrls(urls []string) error {
@@ -61,15 +75,25 @@ func (trx *mediaTransaction) downloadAll(objects []downloadTask) error {
 		return trx.err
 	}
 	for _, object := range objects {
-		err := download(object.url, object.localPath)
+		err := download(trx.store, object)
 		if err != nil {
 			trx.err = err
+			trx.rollback(objects)
 			return err
 		}
 	}
 	return nil
 }
 
+func (trx *mediaTransaction) rollback(objects []downloadTask) {
+	for _, object := range objects {
+		trx.store.Delete(object.name())
+	}
+	if trx.rollbackCallback != nil {
+		trx.rollbackCallback()
+	}
+}
+
 func (trx *mediaTransaction) save() error {
 	if trx.err != nil {
 		return trx.err
@@ -83,6 +107,7 @@ func (trx *mediaTransaction) save() error {
 }
 
 type downloadTask struct {
-	url       string
-	localPath string
+	url      string
+	name     func() string // called when content type of the file is known
+	mimeType *string       // receives sniffed content type, could be nil
 }
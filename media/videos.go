@@ -2,13 +2,12 @@ package media
 
 import (
 	"fmt"
-	"github.com/spf13/viper"
-	"path/filepath"
 	"time"
 )
 
 func (r mediaRepo) AddVideo(video *Video) error {
 	trx := mediaTransaction{
+		store: r.Store,
 		insertCallback: func() error {
 			video.CreatedAt = time.Now()
 			video.UpdatedAt = time.Now()
@@ -31,33 +30,54 @@ func (r mediaRepo) AddVideo(video *Video) error {
 			video.ID = uint(videoID)
 			return nil
 		},
+		rollbackCallback: func() error {
+			_, err := r.DB.Exec("DELETE FROM videos WHERE id = ?", video.ID)
+			return err
+		},
 	}
 	trx.validateUrls([]string{video.ExternalURL, video.ThumbnailURL})
 	trx.save()
 	trx.downloadAll([]downloadTask{
-		downloadTask{url: video.ExternalURL, localPath: r.GetVideoPath(video)},
-		downloadTask{url: video.ThumbnailURL, localPath: r.GetVideoThumbnailPath(video)},
+		downloadTask{
+			url:      video.ExternalURL,
+			name:     func() string { return video.FileName() },
+			mimeType: &video.MimeType,
+		},
+		downloadTask{
+			url:      video.ThumbnailURL,
+			name:     func() string { return video.ThumbnailFileName() },
+			mimeType: &video.ThumbnailMimeType,
+		},
 	})
+	if trx.err == nil {
+		_, trx.err = r.DB.Exec(
+			"UPDATE videos SET mime_type = ?, thumbnail_mime_type = ? WHERE id = ?",
+			video.MimeType, video.ThumbnailMimeType, video.ID,
+		)
+	}
+	if trx.err == nil {
+		r.generateVideoPosters(video)
+	}
 	return trx.err
 }
 
 func (r mediaRepo) GetVideoPath(video *Video) string {
-	return filepath.Join(viper.GetString("media_folder"), video.FileName())
+	return r.Store.Location(video.FileName())
 }
 
 func (r mediaRepo) GetVideoThumbnailPath(video *Video) string {
-	return filepath.Join(viper.GetString("media_folder"), video.ThumbnailFileName())
+	return r.Store.Location(video.ThumbnailFileName())
 }
 
 // FileName returns local file name where current video (should be) stored
 func (video Video) FileName() string {
-	extension := extension(video.ExternalURL)
+	extension := fileExtension(video.MimeType, video.ExternalURL)
 	return fmt.Sprintf("video_%d%s", video.ID, extension)
 }
 
 // ThumbnailFileName returns local file name where current video thumbnail (should be) stored
 func (video Video) ThumbnailFileName() string {
-	extension := extension(video.ThumbnailURL)
+	extension := fileExtension(video.ThumbnailMimeType, video.ThumbnailURL)
 	return fmt.Sprintf("video_%d_thumbnail%s", video.ID, extension)
 }
 
@@ -70,6 +90,9 @@ type Video struct {
 	PostID       uint   `db:"post_id"`
 	ExternalURL  string `db:"external_url"`
 	ThumbnailURL string `db:"thumbnail_url"`
+
+	MimeType          string `db:"mime_type"`           // type detected on download, defines file extension
+	ThumbnailMimeType string `db:"thumbnail_mime_type"` // type of the thumbnail detected on download
 }
 
 // VideosSchema represents schema for "videos" table
@@ -79,5 +102,13 @@ var VideosSchema = `CREATE TABLE "videos" (
 	"updated_at" datetime,
 	"post_id" integer,
 	"external_url" varchar(255),
-	"thumbnail_url" varchar(255)
+	"thumbnail_url" varchar(255),
+	"mime_type" varchar(255) DEFAULT '',
+	"thumbnail_mime_type" varchar(255) DEFAULT ''
 )`
+
+// VideosMigrations adds columns missing in "videos" tables created by older versions
+var VideosMigrations = []string{
+	`ALTER TABLE "videos" ADD COLUMN "mime_type" varchar(255) DEFAULT ''`,
+	`ALTER TABLE "videos" ADD COLUMN "thumbnail_mime_type" varchar(255) DEFAULT ''`,
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/altmer/bellboy/tumblr"
+	"github.com/spf13/cobra"
+)
+
+func mirrorCommand(syncer tumblr.Syncer) *cobra.Command {
+	var list bool
+	cmd := &cobra.Command{
+		Use:   "mirror [blog]...",
+		Short: "Archives all posts of blogs without removing them from tumblr, known blogs are updated when none given",
+		Run: func(cmd *cobra.Command, args []string) {
+			mirrors, err := syncer.Repo.ListMirrors()
+			panicOnError(err)
+			if list {
+				for _, mirror := range mirrors {
+					synced := "never"
+					if mirror.SyncedAt != nil {
+						synced = mirror.SyncedAt.Format("2006-01-02 15:04")
+					}
+					fmt.Printf("%s: %d posts, synced %s\n", mirror.BlogName, mirror.Posts, synced)
+				}
+				return
+			}
+			if len(args) == 0 {
+				for _, mirror := range mirrors {
+					args = append(args, mirror.BlogName)
+				}
+			}
+			for _, blogName := range args {
+				syncer.Mirror(blogName)
+			}
+		},
+	}
+	cmd.Flags().BoolVar(&list, "list", false, "only list mirrored blogs")
+	return cmd
+}
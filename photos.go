@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/altmer/bellboy/media"
+	"github.com/spf13/cobra"
+)
+
+func photosCommand(repo media.Repository) *cobra.Command {
+	var filter media.PhotoFilter
+
+	cmd := &cobra.Command{
+		Use:   "photos",
+		Short: "Lists photos matching given metadata filters",
+		Run: func(cmd *cobra.Command, args []string) {
+			analyzed, err := repo.AnalyzePhotos()
+			panicOnError(err)
+			if analyzed > 0 {
+				fmt.Printf("%d new photos analyzed\n", analyzed)
+			}
+
+			photos, err := repo.FindPhotos(filter)
+			panicOnError(err)
+			for _, photo := range photos {
+				fmt.Printf("[%d] %dx%d %s %d bytes %s\n",
+					photo.ID, photo.Width, photo.Height, photo.MimeType, photo.ByteSize, repo.GetPhotoPath(&photo))
+			}
+			fmt.Printf("%d photos found\n", len(photos))
+		},
+	}
+	cmd.Flags().IntVar(&filter.MinWidth, "min-width", 0, "minimum width in pixels")
+	cmd.Flags().IntVar(&filter.MinHeight, "min-height", 0, "minimum height in pixels")
+	cmd.Flags().StringVar(&filter.Orientation, "orientation", "", "landscape, portrait or square")
+	cmd.Flags().StringVar(&filter.MimeType, "type", "", "detected MIME type, f.ex. image/png")
+	cmd.Flags().StringVar(&filter.CameraModel, "camera", "", "camera model from EXIF")
+	cmd.Flags().BoolVar(&filter.ExtensionMismatch, "mismatched", false, "only photos which extension doesn't match their real format")
+	return cmd
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// profileFlag returns value of --profile flag. The flag is read before commands are parsed
+// because the profile defines the client shared by all commands.
+func profileFlag(args []string) string {
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+		if arg == "--profile" && i+1 < len(args) {
+			return args[i+1]
+		}
+		if strings.HasPrefix(arg, "--profile=") {
+			return strings.TrimPrefix(arg, "--profile=")
+		}
+	}
+	return ""
+}
+
+// tumblrConfigKey returns key of tumblr config section of the profile,
+// top-level "tumblr" section is used without profile
+func tumblrConfigKey(profile string) string {
+	if profile == "" {
+		return "tumblr"
+	}
+	key := "profiles." + profile + ".tumblr"
+	if !viper.IsSet(key) {
+		panic(fmt.Errorf("profile [%s] not found", profile))
+	}
+	return key
+}
+
+// profileAccount returns account name recorded in synced posts:
+// "account" setting of the section, profile name or "default"
+func profileAccount(profile, key string) string {
+	if account := viper.GetString(key + ".account"); account != "" {
+		return account
+	}
+	if profile != "" {
+		return profile
+	}
+	return "default"
+}
+
+// profileBlogs returns main blog and all blogs synced for the profile
+func profileBlogs(key string) (string, []string) {
+	blogs := viper.GetStringSlice(key + ".blogs")
+	blog := viper.GetString(key + ".blog")
+	if blog == "" && len(blogs) > 0 {
+		blog = blogs[0]
+	}
+	return blog, blogs
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/altmer/bellboy/media"
+	"github.com/spf13/cobra"
+)
+
+func queueCommand(repo media.Repository) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "queue",
+		Short: "Triages queued (liked) posts",
+	}
+
+	var tag string
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "Lists queued posts",
+		Run: func(cmd *cobra.Command, args []string) {
+			posts, err := repo.ListQueuedPosts(tag)
+			panicOnError(err)
+			for _, post := range posts {
+				fmt.Printf("[%d] %s %s %s\n", post.ID, post.Type, post.Category, post.ExternalURL)
+			}
+			fmt.Printf("%d queued posts found\n", len(posts))
+		},
+	}
+	list.Flags().StringVarP(&tag, "tag", "t", "", "only posts with the suggested tag")
+	cmd.AddCommand(list)
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "tags",
+		Short: "Lists tags suggested for queued posts with numbers of posts",
+		Run: func(cmd *cobra.Command, args []string) {
+			tags, err := repo.ListSuggestedTags()
+			panicOnError(err)
+			for _, tag := range tags {
+				fmt.Printf("%6d  %s\n", tag.Posts, tag.Name)
+			}
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "approve <post id>...",
+		Short: "Approves queued posts, their suggested tags become real tags",
+		Args:  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			for _, id := range parsePostIDs(args) {
+				panicOnError(repo.ApprovePost(id))
+				fmt.Printf("Post [%d] approved\n", id)
+			}
+		},
+	})
+	return cmd
+}
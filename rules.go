@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/altmer/bellboy/media"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func rulesPath() string {
+	viper.SetDefault("rules", filepath.Join(bellboyDirPath(), "rules.yaml"))
+	return viper.GetString("rules")
+}
+
+func rulesCommand(repo media.Repository, rules *media.Rules) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "rules",
+		Short: "Inspects rules applied to new posts",
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "test <post id>",
+		Short: "Shows which rules fire for stored post and what they change, nothing is saved",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			id, err := strconv.ParseUint(args[0], 10, 64)
+			panicOnError(err)
+			post, err := repo.GetPost(uint(id))
+			panicOnError(err)
+			tags, err := repo.ListPostTags(post.ID)
+			panicOnError(err)
+
+			changed := post
+			result := rules.Apply(&changed, tags)
+			if len(result.Fired) == 0 {
+				fmt.Printf("No rules of [%s] fire for post [%d]\n", rulesPath(), post.ID)
+				return
+			}
+			for _, name := range result.Fired {
+				fmt.Printf("Rule [%s] fires\n", name)
+			}
+			if result.Skip {
+				fmt.Println("Post would be skipped")
+				return
+			}
+			fmt.Printf("sfw: %t -> %t\n", post.SFW, changed.SFW)
+			fmt.Printf("status: %s -> %s\n", post.Status, changed.Status)
+			fmt.Printf("tags: [%s] -> [%s]\n", strings.Join(tags, ", "), strings.Join(result.Tags, ", "))
+		},
+	})
+	return cmd
+}
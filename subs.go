@@ -0,0 +1,184 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/altmer/bellboy/media"
+	"github.com/altmer/bellboy/tumblr"
+	"github.com/spf13/cobra"
+)
+
+func subsCommand(syncer tumblr.Syncer) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "subs",
+		Short: "Manages subscriptions",
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "diff",
+		Short: "Shows how tumblr subscriptions differ from local ones",
+		Run: func(cmd *cobra.Command, args []string) {
+			syncer.DryRun = true
+			syncer.SubsDown()
+		},
+	})
+
+	var inactive, group string
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "Lists subscriptions with their activity",
+		Run: func(cmd *cobra.Command, args []string) {
+			var subs []media.Subscription
+			var err error
+			switch {
+			case group != "":
+				subs, err = syncer.Repo.ListGroupSubscriptions(group)
+			case inactive == "":
+				subs, err = syncer.Repo.ListSubscriptions()
+			default:
+				age, ageErr := parseAge(inactive)
+				panicOnError(ageErr)
+				subs, err = syncer.Repo.ListInactiveSubscriptions(time.Now().Add(-age))
+			}
+			panicOnError(err)
+			for _, sub := range subs {
+				lastPost := "unknown"
+				if sub.LastPostAt != nil {
+					lastPost = sub.LastPostAt.Format("2006-01-02")
+				}
+				fmt.Printf("%s [%s] last post %s, %d posts %s %s\n",
+					sub.BlogName, sub.URL, lastPost, sub.PostCount, syncer.Repo.GetSubscriptionAvatarPath(&sub), sub.Notes)
+			}
+			fmt.Printf("%d subscriptions\n", len(subs))
+		},
+	}
+	list.Flags().StringVar(&inactive, "inactive", "", "only blogs without posts for that long, f.ex. 1y")
+	list.Flags().StringVar(&group, "group", "", "only blogs of the group")
+	cmd.AddCommand(list)
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "refresh",
+		Short: "Fetches last post time, post count and avatar of subscribed blogs",
+		Run: func(cmd *cobra.Command, args []string) {
+			syncer.SubsRefresh()
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "note <blog> <notes>...",
+		Short: "Saves local notes about subscribed blog, notes are never synced",
+		Args:  cobra.MinimumNArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			panicOnError(syncer.Repo.SetSubscriptionNotes(args[0], strings.Join(args[1:], " ")))
+			fmt.Printf("Notes of [%s] saved\n", args[0])
+		},
+	})
+	cmd.AddCommand(subsGroupsCommand(syncer.Repo), subsShowCommand(syncer.Repo), subsSetCommand(syncer.Repo))
+	return cmd
+}
+
+func subsGroupsCommand(repo media.Repository) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "groups",
+		Short: "Lists subscription groups, adds and removes blogs",
+		Run: func(cmd *cobra.Command, args []string) {
+			groups, err := repo.ListSubscriptionGroups()
+			panicOnError(err)
+			for _, group := range groups {
+				fmt.Printf("%s (%d)\n", group.Name, group.Subscriptions)
+			}
+		},
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "add <group> <blog>...",
+		Short: "Adds blogs to the group",
+		Args:  cobra.MinimumNArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			for _, blog := range args[1:] {
+				panicOnError(repo.AddSubscriptionToGroup(blog, args[0]))
+			}
+			fmt.Printf("%d blogs added to group [%s]\n", len(args)-1, args[0])
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "remove <group> <blog>...",
+		Short: "Removes blogs from the group",
+		Args:  cobra.MinimumNArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			for _, blog := range args[1:] {
+				panicOnError(repo.RemoveSubscriptionFromGroup(blog, args[0]))
+			}
+			fmt.Printf("%d blogs removed from group [%s]\n", len(args)-1, args[0])
+		},
+	})
+	return cmd
+}
+
+func subsShowCommand(repo media.Repository) *cobra.Command {
+	return &cobra.Command{
+		Use:   "show <blog>",
+		Short: "Shows subscription with its groups and sync settings",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			sub, err := repo.GetSubscription(args[0])
+			panicOnError(err)
+			groups, err := repo.ListSubscriptionGroupNames(sub.BlogName)
+			panicOnError(err)
+			sfw := "default"
+			if sub.SFW != nil {
+				sfw = strconv.FormatBool(*sub.SFW)
+			}
+			fmt.Printf("%s [%s] %s\n", sub.BlogName, sub.URL, sub.Title)
+			fmt.Printf("Notes: %s\n", sub.Notes)
+			fmt.Printf("Groups: %s\n", strings.Join(groups, ", "))
+			fmt.Printf("Auto approve: %t\n", sub.AutoApprove)
+			fmt.Printf("SFW: %s\n", sfw)
+			fmt.Printf("Default tags: %s\n", strings.Join(sub.Tags(), ", "))
+			fmt.Printf("Archive: %t\n", sub.Archive)
+		},
+	}
+}
+
+func subsSetCommand(repo media.Repository) *cobra.Command {
+	var autoApprove, archive bool
+	var sfw, tags string
+	cmd := &cobra.Command{
+		Use:   "set <blog>",
+		Short: "Changes sync settings of subscribed blog, only given settings are changed",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			sub, err := repo.GetSubscription(args[0])
+			panicOnError(err)
+			flags := cmd.Flags()
+			if flags.Changed("auto-approve") {
+				sub.AutoApprove = autoApprove
+			}
+			if flags.Changed("archive") {
+				sub.Archive = archive
+			}
+			if flags.Changed("tags") {
+				sub.DefaultTags = tags
+			}
+			if flags.Changed("sfw") {
+				sub.SFW = nil
+				if sfw != "default" {
+					value, err := strconv.ParseBool(sfw)
+					panicOnError(err)
+					sub.SFW = &value
+				}
+			}
+			panicOnError(repo.UpdateSubscriptionSettings(&sub))
+			fmt.Printf("Settings of [%s] saved\n", sub.BlogName)
+		},
+	}
+	cmd.Flags().BoolVar(&autoApprove, "auto-approve", false, "add liked posts of the blog instead of queueing them")
+	cmd.Flags().StringVar(&sfw, "sfw", "default", "SFW flag of new posts: true, false or default")
+	cmd.Flags().StringVar(&tags, "tags", "", "comma separated tags added to new posts")
+	cmd.Flags().BoolVar(&archive, "archive", false, "keep posts of the blog on tumblr after they are synced")
+	return cmd
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/altmer/bellboy/media"
+	"github.com/spf13/cobra"
+)
+
+func tagsCommand(repo media.Repository, normalizer media.TagNormalizer) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "tags",
+		Short: "Cleans up tags: list, rename, merge, delete, alias and normalize them",
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "Lists tags with numbers of tagged posts",
+		Run: func(cmd *cobra.Command, args []string) {
+			tags, err := repo.ListTags()
+			panicOnError(err)
+			for _, tag := range tags {
+				fmt.Printf("%6d  %s\n", tag.Posts, tag.Name)
+			}
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "rename <tag> <new name>",
+		Short: "Renames tag",
+		Args:  cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			panicOnError(repo.RenameTag(args[0], args[1]))
+			fmt.Printf("Tag [%s] renamed to [%s]\n", args[0], args[1])
+		},
+	})
+
+	var alias bool
+	merge := &cobra.Command{
+		Use:   "merge <target tag> <tag>...",
+		Short: "Moves posts of the tags to the target tag and removes the tags",
+		Args:  cobra.MinimumNArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			target, sources := args[0], args[1:]
+			panicOnError(repo.MergeTags(target, sources))
+			fmt.Printf("%d tags merged into [%s]\n", len(sources), target)
+			if !alias {
+				return
+			}
+			for _, source := range sources {
+				if source != target {
+					panicOnError(repo.AddTagAlias(source, target))
+				}
+			}
+		},
+	}
+	merge.Flags().BoolVarP(&alias, "alias", "a", false, "make merged tags aliases of the target for future syncs")
+	cmd.AddCommand(merge)
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "delete <tag>",
+		Short: "Removes tag from all posts",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			panicOnError(repo.DeleteTag(args[0]))
+			fmt.Printf("Tag [%s] deleted\n", args[0])
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "alias <alias> <tag>",
+		Short: "Synced posts tagged with alias get the tag instead",
+		Args:  cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			panicOnError(repo.AddTagAlias(args[0], args[1]))
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "unalias <alias>",
+		Short: "Removes tag alias",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			panicOnError(repo.RemoveTagAlias(args[0]))
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "aliases",
+		Short: "Lists tag aliases",
+		Run: func(cmd *cobra.Command, args []string) {
+			aliases, err := repo.ListTagAliases()
+			panicOnError(err)
+			for _, alias := range aliases {
+				fmt.Printf("%s -> %s\n", alias.Alias, alias.TagName)
+			}
+		},
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:   "normalize",
+		Short: "Applies configured tag normalization to stored tags",
+		Run: func(cmd *cobra.Command, args []string) {
+			changes, err := repo.NormalizeTags(normalizer)
+			for _, change := range changes {
+				if change.To == "" {
+					fmt.Printf("[%s] deleted\n", change.From)
+				} else {
+					fmt.Printf("[%s] -> [%s]\n", change.From, change.To)
+				}
+			}
+			panicOnError(err)
+			fmt.Printf("%d tags changed\n", len(changes))
+		},
+	})
+	return cmd
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/altmer/bellboy/media"
+	"github.com/spf13/cobra"
+)
+
+func thumbsCommand(repo media.Repository) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "thumbs",
+		Short: "Manages local thumbnails of photos and video posters",
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "rebuild",
+		Short: "Renders thumbnails for all existing photos and videos in configured sizes",
+		Run: func(cmd *cobra.Command, args []string) {
+			rebuilt, failed, err := repo.RebuildThumbnails()
+			panicOnError(err)
+			fmt.Printf("Thumbnails rebuilt for %d media objects, %d failed\n", rebuilt, failed)
+		},
+	})
+	return cmd
+}
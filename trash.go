@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/altmer/bellboy/media"
+	"github.com/spf13/cobra"
+)
+
+func trashCommand(repo media.Repository) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "trash",
+		Short: "Manages deleted posts: lists, restores and purges them",
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "delete <post id>...",
+		Short: "Moves posts with their media files to the trash",
+		Args:  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			for _, id := range parsePostIDs(args) {
+				panicOnError(repo.DeletePost(id))
+				fmt.Printf("Post [%d] moved to the trash\n", id)
+			}
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "Lists deleted posts",
+		Run: func(cmd *cobra.Command, args []string) {
+			posts, err := repo.ListTrash()
+			panicOnError(err)
+			for _, post := range posts {
+				fmt.Printf("[%d] deleted %s %s %s %s\n",
+					post.ID, post.DeletedAt.Format("2006-01-02 15:04"), post.Type, post.Category, post.ExternalURL)
+			}
+			fmt.Printf("%d posts in the trash\n", len(posts))
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "restore <post id>...",
+		Short: "Restores deleted posts with their media files",
+		Args:  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			for _, id := range parsePostIDs(args) {
+				panicOnError(repo.RestorePost(id))
+				fmt.Printf("Post [%d] restored\n", id)
+			}
+		},
+	})
+
+	var olderThan string
+	empty := &cobra.Command{
+		Use:   "empty",
+		Short: "Purges media files and records of deleted posts, purged posts are not synced again",
+		Run: func(cmd *cobra.Command, args []string) {
+			age, err := parseAge(olderThan)
+			panicOnError(err)
+			purged, err := repo.EmptyTrash(time.Now().Add(-age))
+			panicOnError(err)
+			fmt.Printf("%d posts purged\n", purged)
+		},
+	}
+	empty.Flags().StringVar(&olderThan, "older-than", "0s", "purge only posts deleted earlier than that, f.ex. 30d")
+	cmd.AddCommand(empty)
+	return cmd
+}
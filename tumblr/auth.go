@@ -0,0 +1,268 @@
+package tumblr
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kurrik/oauth1a"
+)
+
+const (
+	oauth2AuthorizeUrl = "https://www.tumblr.com/oauth2/authorize" // oauth2 authorize URL
+	oauth2TokenUrl     = "https://api.tumblr.com/v2/oauth2/token"  // oauth2 token URL
+	oauth2Scope        = "basic write offline_access"              // offline access gives refresh token
+)
+
+// Authenticator authorizes requests to tumblr API
+type Authenticator interface {
+	Authorize(*http.Request) error
+}
+
+// Refresher is implemented by authenticators which could renew credentials rejected by tumblr
+type Refresher interface {
+	Refresh() error
+}
+
+// AuthError is failure to authorize requests to tumblr, it is not fixed by retrying requests
+type AuthError struct {
+	Err error
+}
+
+func (e *AuthError) Error() string {
+	return "tumblr authorization failed: " + e.Err.Error()
+}
+
+// NewAuthenticator picks authenticator by "auth" setting: "oauth1" (default) or "oauth2".
+// Refreshed OAuth2 tokens are passed to save.
+func NewAuthenticator(params map[string]string, save func(OAuth2Token) error) (Authenticator, error) {
+	switch params["auth"] {
+	case "", "oauth1":
+		return NewOAuth1Authenticator(
+			params["consumer_key"], params["consumer_secret"], params["oauth_key"], params["oauth_secret"],
+		), nil
+	case "oauth2":
+		token := OAuth2Token{
+			AccessToken:  params["oauth2_access_token"],
+			RefreshToken: params["oauth2_refresh_token"],
+		}
+		if expiry := params["oauth2_expiry"]; expiry != "" {
+			var err error
+			token.Expiry, err = time.Parse(time.RFC3339, expiry)
+			if err != nil {
+				return nil, fmt.Errorf("can't parse oauth2_expiry: %s", err)
+			}
+		}
+		return &OAuth2Authenticator{
+			ClientID:     params["consumer_key"],
+			ClientSecret: params["consumer_secret"],
+			TokenURL:     oauth2TokenUrl,
+			Token:        token,
+			Save:         save,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown auth [%s]", params["auth"])
+	}
+}
+
+// OAuth1Authenticator signs requests with OAuth 1.0a HMAC-SHA1 signature
+type OAuth1Authenticator struct {
+	service oauth1a.Service    // oauth service used to sign HTTP requests
+	config  oauth1a.UserConfig // used within the oauth HTTP signing
+}
+
+// NewOAuth1Authenticator creates authenticator for application and user tokens
+func NewOAuth1Authenticator(consumerKey, consumerSecret, token, tokenSecret string) *OAuth1Authenticator {
+	return &OAuth1Authenticator{
+		service: oauth1a.Service{
+			RequestURL:   requestTokenUrl,
+			AuthorizeURL: authorizeUrl,
+			AccessURL:    accessTokenUrl,
+			ClientConfig: &oauth1a.ClientConfig{
+				ConsumerKey:    consumerKey,
+				ConsumerSecret: consumerSecret,
+			},
+			Signer: new(oauth1a.HmacSha1Signer),
+		},
+		config: *oauth1a.NewAuthorizedConfig(token, tokenSecret),
+	}
+}
+
+// Authorize signs the request
+func (a *OAuth1Authenticator) Authorize(request *http.Request) error {
+	return a.service.Sign(request, &a.config)
+}
+
+// OAuth2Token is OAuth2 bearer token with refresh token
+type OAuth2Token struct {
+	AccessToken  string
+	RefreshToken string
+	Expiry       time.Time // zero when token doesn't expire
+}
+
+// expired tells whether token should be refreshed, tokens are refreshed a bit earlier
+func (t OAuth2Token) expired() bool {
+	return !t.Expiry.IsZero() && time.Now().Add(30*time.Second).After(t.Expiry)
+}
+
+// OAuth2Authenticator adds bearer token to requests, expired token is refreshed and saved
+type OAuth2Authenticator struct {
+	ClientID     string
+	ClientSecret string
+	TokenURL     string
+	Token        OAuth2Token
+	Save         func(OAuth2Token) error // persists refreshed token, could be nil
+
+	mutex sync.Mutex
+}
+
+// Authorize adds bearer token to the request
+func (a *OAuth2Authenticator) Authorize(request *http.Request) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.Token.expired() && a.Token.RefreshToken != "" {
+		err := a.refresh()
+		if err != nil {
+			return err
+		}
+	}
+	if a.Token.AccessToken == "" {
+		return errors.New("no oauth2 access token, run login")
+	}
+	request.Header.Set("Authorization", "Bearer "+a.Token.AccessToken)
+	return nil
+}
+
+// Refresh renews access token rejected by tumblr before its expiry
+func (a *OAuth2Authenticator) Refresh() error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.Token.RefreshToken == "" {
+		return errors.New("oauth2 access token is rejected and there is no refresh token, run login")
+	}
+	return a.refresh()
+}
+
+func (a *OAuth2Authenticator) refresh() error {
+	token, err := requestOAuth2Token(a.TokenURL, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {a.Token.RefreshToken},
+		"client_id":     {a.ClientID},
+		"client_secret": {a.ClientSecret},
+	})
+	if err != nil {
+		return fmt.Errorf("can't refresh oauth2 token: %s", err)
+	}
+	if token.RefreshToken == "" {
+		token.RefreshToken = a.Token.RefreshToken
+	}
+	a.Token = token
+	if a.Save != nil {
+		return a.Save(token)
+	}
+	return nil
+}
+
+// OAuth2Endpoints are URLs of OAuth2 authorization code flow
+type OAuth2Endpoints struct {
+	AuthorizeURL string
+	TokenURL     string
+}
+
+// TumblrOAuth2Endpoints are OAuth2 endpoints of tumblr
+var TumblrOAuth2Endpoints = OAuth2Endpoints{
+	AuthorizeURL: oauth2AuthorizeUrl,
+	TokenURL:     oauth2TokenUrl,
+}
+
+// CodeAuthorizer shows authorize URL to the user and returns code and state passed back by the provider
+type CodeAuthorizer func(authorizeURL string) (code, state string, err error)
+
+// LoginOAuth2 gets token of the user with OAuth2 authorization code flow protected with PKCE
+func LoginOAuth2(endpoints OAuth2Endpoints, clientID, clientSecret, redirectURL string, authorize CodeAuthorizer) (OAuth2Token, error) {
+	verifier, err := randomString()
+	if err != nil {
+		return OAuth2Token{}, err
+	}
+	state, err := randomString()
+	if err != nil {
+		return OAuth2Token{}, err
+	}
+	challenge := sha256.Sum256([]byte(verifier))
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {clientID},
+		"redirect_uri":          {redirectURL},
+		"scope":                 {oauth2Scope},
+		"state":                 {state},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	code, returnedState, err := authorize(endpoints.AuthorizeURL + "?" + query.Encode())
+	if err != nil {
+		return OAuth2Token{}, err
+	}
+	if returnedState != state {
+		return OAuth2Token{}, errors.New("authorization state doesn't match")
+	}
+	token, err := requestOAuth2Token(endpoints.TokenURL, url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"client_id":     {clientID},
+		"client_secret": {clientSecret},
+		"redirect_uri":  {redirectURL},
+		"code_verifier": {verifier},
+	})
+	if err != nil {
+		return OAuth2Token{}, fmt.Errorf("can't get oauth2 token: %s", err)
+	}
+	return token, nil
+}
+
+// requestOAuth2Token posts the form to token endpoint and reads token from the answer
+func requestOAuth2Token(tokenURL string, form url.Values) (OAuth2Token, error) {
+	response, err := http.Post(tokenURL, "application/x-www-form-urlencoded", strings.NewReader(form.Encode()))
+	if err != nil {
+		return OAuth2Token{}, err
+	}
+	defer response.Body.Close()
+	var answer struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+		ExpiresIn    int    `json:"expires_in"` // seconds
+		Error        string `json:"error"`
+	}
+	err = json.NewDecoder(response.Body).Decode(&answer)
+	if response.StatusCode != http.StatusOK {
+		if answer.Error != "" {
+			return OAuth2Token{}, fmt.Errorf("token endpoint response: %s %s", response.Status, answer.Error)
+		}
+		return OAuth2Token{}, fmt.Errorf("token endpoint response: %s", response.Status)
+	}
+	if err != nil {
+		return OAuth2Token{}, err
+	}
+	if answer.AccessToken == "" {
+		return OAuth2Token{}, errors.New("no access token in response")
+	}
+	token := OAuth2Token{AccessToken: answer.AccessToken, RefreshToken: answer.RefreshToken}
+	if answer.ExpiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(answer.ExpiresIn) * time.Second)
+	}
+	return token, nil
+}
+
+// randomString returns URL-safe string of 32 random bytes
+func randomString() (string, error) {
+	bytes := make([]byte, 32)
+	_, err := rand.Read(bytes)
+	return base64.RawURLEncoding.EncodeToString(bytes), err
+}
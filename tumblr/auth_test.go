@@ -0,0 +1,175 @@
+package tumblr
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewAuthenticator(t *testing.T) {
+	auth, err := NewAuthenticator(map[string]string{"consumer_key": "key", "oauth_key": "token"}, nil)
+	assert.Nil(t, err)
+	request, _ := http.NewRequest("GET", "http://api.tumblr.com/v2/user/info", nil)
+	assert.Nil(t, auth.Authorize(request))
+	assert.Contains(t, request.Header.Get("Authorization"), `oauth_token="token"`)
+
+	auth, err = NewAuthenticator(map[string]string{
+		"auth":                "oauth2",
+		"oauth2_access_token": "access",
+		"oauth2_expiry":       "2100-01-02T15:04:05Z",
+	}, nil)
+	assert.Nil(t, err)
+	request, _ = http.NewRequest("GET", "http://api.tumblr.com/v2/user/info", nil)
+	assert.Nil(t, auth.Authorize(request))
+	assert.Equal(t, "Bearer access", request.Header.Get("Authorization"))
+
+	_, err = NewAuthenticator(map[string]string{"auth": "magic"}, nil)
+	assert.EqualError(t, err, "unknown auth [magic]")
+}
+
+// fakeOAuth2Provider issues tokens for code "code" checking PKCE verifier and refreshes token "refresh"
+func fakeOAuth2Provider(t *testing.T) *httptest.Server {
+	var challenge, redirectURL string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		assert.Equal(t, "S256", query.Get("code_challenge_method"))
+		challenge = query.Get("code_challenge")
+		redirectURL = query.Get("redirect_uri")
+		http.Redirect(w, r, redirectURL+"?code=code&state="+url.QueryEscape(query.Get("state")), http.StatusFound)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		answer := map[string]interface{}{"expires_in": 3600}
+		switch r.Form.Get("grant_type") {
+		case "authorization_code":
+			verified := sha256.Sum256([]byte(r.Form.Get("code_verifier")))
+			if r.Form.Get("code") != "code" || r.Form.Get("redirect_uri") != redirectURL ||
+				base64.RawURLEncoding.EncodeToString(verified[:]) != challenge {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+				return
+			}
+			answer["access_token"] = "access"
+			answer["refresh_token"] = "refresh"
+		case "refresh_token":
+			if r.Form.Get("refresh_token") != "refresh" {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+				return
+			}
+			answer["access_token"] = "refreshed"
+			answer["refresh_token"] = "refresh2"
+		}
+		json.NewEncoder(w).Encode(answer)
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestLoginOAuth2(t *testing.T) {
+	provider := fakeOAuth2Provider(t)
+	defer provider.Close()
+	callback, err := NewCallbackServer()
+	assert.Nil(t, err)
+	defer callback.Close()
+	endpoints := OAuth2Endpoints{AuthorizeURL: provider.URL + "/authorize", TokenURL: provider.URL + "/token"}
+
+	token, err := LoginOAuth2(endpoints, "client", "secret", callback.URL,
+		func(authorizeURL string) (string, string, error) {
+			response, err := http.Get(authorizeURL)
+			assert.Nil(t, err)
+			response.Body.Close()
+			query, err := callback.Wait(time.Second)
+			return query.Get("code"), query.Get("state"), err
+		})
+	assert.Nil(t, err)
+	assert.Equal(t, "access", token.AccessToken)
+	assert.Equal(t, "refresh", token.RefreshToken)
+	assert.True(t, token.Expiry.After(time.Now()))
+
+	_, err = LoginOAuth2(endpoints, "client", "secret", callback.URL,
+		func(authorizeURL string) (string, string, error) {
+			return "code", "forged", nil
+		})
+	assert.EqualError(t, err, "authorization state doesn't match")
+}
+
+func TestOAuth2AuthenticatorRefreshesToken(t *testing.T) {
+	provider := fakeOAuth2Provider(t)
+	defer provider.Close()
+
+	var saved []OAuth2Token
+	auth := &OAuth2Authenticator{
+		ClientID: "client",
+		TokenURL: provider.URL + "/token",
+		Token:    OAuth2Token{AccessToken: "access", RefreshToken: "refresh", Expiry: time.Now().Add(-time.Minute)},
+		Save: func(token OAuth2Token) error {
+			saved = append(saved, token)
+			return nil
+		},
+	}
+	request, _ := http.NewRequest("GET", "http://api.tumblr.com/v2/user/info", nil)
+	assert.Nil(t, auth.Authorize(request))
+	assert.Equal(t, "Bearer refreshed", request.Header.Get("Authorization"))
+	assert.Equal(t, 1, len(saved))
+	assert.Equal(t, "refresh2", saved[0].RefreshToken)
+
+	// fresh token is not refreshed again
+	request, _ = http.NewRequest("GET", "http://api.tumblr.com/v2/user/info", nil)
+	assert.Nil(t, auth.Authorize(request))
+	assert.Equal(t, 1, len(saved))
+
+	auth.Token = OAuth2Token{AccessToken: "access", RefreshToken: "revoked", Expiry: time.Now()}
+	assert.Error(t, auth.Authorize(request))
+}
+
+func TestClientRefreshesRejectedToken(t *testing.T) {
+	provider := fakeOAuth2Provider(t)
+	defer provider.Close()
+	var bodies []string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		if r.Header.Get("Authorization") != "Bearer refreshed" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"meta": {"status": 401, "msg": "Unauthorized"}}`))
+			return
+		}
+		w.Write([]byte(`{"meta": {"status": 200, "msg": "OK"}, "response": {}}`))
+	}))
+	defer api.Close()
+
+	// token is rejected before its expiry
+	auth := &OAuth2Authenticator{ClientID: "client", TokenURL: provider.URL + "/token", Token: OAuth2Token{AccessToken: "access", RefreshToken: "refresh"}}
+	tumblr := client{auth: auth}
+	assert.Equal(t, 200, tumblr.post(api.URL, "id=1").Meta.Status)
+	assert.Equal(t, "refreshed", auth.Token.AccessToken)
+	assert.Equal(t, []string{"id=1", "id=1"}, bodies)
+	assert.Equal(t, 200, tumblr.get(api.URL).Meta.Status)
+
+	authError := func(request func()) (err error) {
+		defer func() {
+			if authErr, ok := recover().(*AuthError); ok {
+				err = authErr
+			}
+		}()
+		request()
+		return nil
+	}
+	auth.Token = OAuth2Token{AccessToken: "access", RefreshToken: "revoked"}
+	assert.EqualError(t, authError(func() { tumblr.get(api.URL) }),
+		"tumblr authorization failed: can't refresh oauth2 token: token endpoint response: 400 Bad Request invalid_grant")
+	auth.Token = OAuth2Token{AccessToken: "access"}
+	assert.EqualError(t, authError(func() { tumblr.get(api.URL) }),
+		"tumblr authorization failed: oauth2 access token is rejected and there is no refresh token, run login")
+	auth.Token = OAuth2Token{AccessToken: "access", RefreshToken: "revoked", Expiry: time.Now()}
+	assert.Error(t, authError(func() { tumblr.post(api.URL, "id=1") }), "failed refresh of expired token should not be ignored")
+}
@@ -3,8 +3,6 @@ package tumblr
 import (
 	"net/url"
 	"strconv"
-
-	"github.com/kurrik/oauth1a"
 )
 
 const (
@@ -18,45 +16,68 @@ const (
 
 // cient represents api client structure
 type client struct {
-	oauthService oauth1a.Service    // oauth service used to sign HTTP requests
-	config       oauth1a.UserConfig // used within the oauth HTTP signing
-	apiKey       string             // consumer key used for certain API requests
+	auth   Authenticator // authorizes HTTP requests
+	apiKey string        // consumer key used for certain API requests
 }
 
 // API represents all the available methods for interacting with tumblr
 type API interface {
+	BlogInfo(string) BlogInfo
+	BlogAvatar(string, int) BlogAvatar
 	BlogPosts(string, map[string]string) BlogPosts
 	PostDelete(string, int) Meta
 
 	UserLikes(map[string]string) Likes
 	UserUnlike(int, string) Meta
 
+	Tagged(string, map[string]string) []Post
+
+	UserDashboard(map[string]string) BlogList
 	UserFollowing(map[string]string) UserFollowing
 	UserFollow(string) Meta
+	UserUnfollow(string) Meta
 }
 
 // New is the initialization method.
 // An easy way to get the credentials is to access the interactive console:
 // https://api.tumblr.com/console
 func New(params map[string]string) API {
-	service := &oauth1a.Service{
-		RequestURL:   requestTokenUrl,
-		AuthorizeURL: authorizeUrl,
-		AccessURL:    accessTokenUrl,
-		ClientConfig: &oauth1a.ClientConfig{
-			ConsumerKey:    params["consumer_key"],
-			ConsumerSecret: params["consumer_secret"],
-		},
-		Signer: new(oauth1a.HmacSha1Signer),
-	}
-	config := oauth1a.NewAuthorizedConfig(params["oauth_key"], params["oauth_secret"])
+	return NewWithAuthenticator(
+		NewOAuth1Authenticator(params["consumer_key"], params["consumer_secret"], params["oauth_key"], params["oauth_secret"]),
+		params["consumer_key"],
+	)
+}
+
+// NewWithAuthenticator creates client authorizing requests with the authenticator,
+// api key is the consumer key of the application
+func NewWithAuthenticator(auth Authenticator, apiKey string) API {
 	return &client{
-		oauthService: *service,
-		config:       *config,
-		apiKey:       params["consumer_key"],
+		auth:   auth,
+		apiKey: apiKey,
 	}
 }
 
+// BlogInfo method retrieves general information about the blog, such as the title, number of posts, and other high-level data
+// blogHostname - The standard or custom blog hostname (e.g., example.tumblr.com, example.com)
+func (api client) BlogInfo(blogHostname string) BlogInfo {
+	var blogInfo BlogInfo
+	requestURL := apiBlogUrl + blogHostname + "/info?"
+	urlParams := url.Values{}
+	urlParams.Set("api_key", api.apiKey)
+	requestURL = requestURL + urlParams.Encode()
+	api.info(requestURL, &blogInfo)
+	return blogInfo
+}
+
+// BlogAvatar method retrieves the URL of the blog's avatar, tumblr redirects to the image
+// blogHostname - The standard or custom blog hostname (e.g., example.tumblr.com, example.com)
+// size - The size of the avatar (square, one value for both length and width). Must be one of the values:
+//          16, 24, 30, 40, 48, 64, 96, 128, 512
+func (api client) BlogAvatar(blogHostname string, size int) BlogAvatar {
+	requestURL := apiBlogUrl + blogHostname + "/avatar/" + strconv.Itoa(size)
+	return BlogAvatar{AvatarURL: api.redirectLocation(requestURL)}
+}
+
 // BlogPosts method retrieves a list of a blog's published posts
 // blogHostname - The standard or custom blog hostname (e.g., example.tumblr.com, example.com)
 // params - A map of the params that are included in this request. Possible parameters:
@@ -92,6 +113,45 @@ func (api client) PostDelete(blogHostname string, id int) Meta {
 	return response.Meta
 }
 
+// Tagged method retrieves posts with the tag from all blogs, newest first
+// tag - The tag on the posts you'd like to retrieve
+// params - A map of the params that are included in this request. Possible parameters:
+//          * before - The timestamp of when you'd like to see posts before
+//          * limit - The number of results to return: 1–20, inclusive
+//          * filter - Specifies the post format to return, other than HTML (text or raw)
+func (api client) Tagged(tag string, params map[string]string) []Post {
+	var posts []Post
+	urlParams := url.Values{}
+	urlParams.Set("api_key", api.apiKey)
+	urlParams.Set("tag", tag)
+	for key, value := range params {
+		urlParams.Set(key, value)
+	}
+	requestURL := apiTaggedUrl + urlParams.Encode()
+	api.info(requestURL, &posts)
+	return posts
+}
+
+// UserDashboard method is used to retrieve the dashboard that matches the OAuth credentials submitted with the request
+// params - A map of the params that are included in this request. Possible parameters:
+//          * limit - The number of results to return: 1–20, inclusive
+//          * offset - Post number to start at
+//          * type - The type of post to return. text, quote, link, answer, video, audio, photo, chat
+//          * since_id - Return posts that have appeared after this ID
+//          * reblog_info - Indicates whether to return reblog information (specify true or false)
+//          * notes_info - Indicates whether to return notes information (specify true or false)
+func (api client) UserDashboard(params map[string]string) BlogList {
+	var dashboard BlogList
+	requestURL := apiUserUrl + "dashboard?"
+	urlParams := url.Values{}
+	for key, value := range params {
+		urlParams.Set(key, value)
+	}
+	requestURL = requestURL + urlParams.Encode()
+	api.info(requestURL, &dashboard)
+	return dashboard
+}
+
 // UserInfo method is used to retrieve the user's account information that matches
 // the OAuth credentials submitted with the request.
 func (api client) UserInfo() UserInfo {
@@ -0,0 +1,61 @@
+package tumblr
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// dashboardStateKey keeps ID of the newest dashboard post seen by the previous run
+const dashboardStateKey = "tumblr.dashboard.last_post_id"
+
+// Dashboard stores dashboard posts accepted by the filter as queued posts for review.
+// Only posts newer than the ones seen by the previous run are fetched. Returns number of stored posts.
+func (s Syncer) Dashboard(filter PostFilter) int {
+	state, err := s.Repo.GetSyncState(dashboardStateKey)
+	if err != nil {
+		panic(err)
+	}
+	lastID, _ := strconv.Atoi(state)
+	fmt.Println("Fetching dashboard...")
+
+	newestID := lastID
+	stored := 0
+	limit := 20
+	for offset := 0; ; offset += limit {
+		params := map[string]string{
+			"offset": strconv.Itoa(offset),
+			"limit":  strconv.Itoa(limit),
+		}
+		if lastID > 0 {
+			params["since_id"] = strconv.Itoa(lastID)
+		}
+		posts := s.Client.UserDashboard(params).Posts
+		if len(posts) == 0 {
+			break
+		}
+		for _, post := range posts {
+			if post.ID > newestID {
+				newestID = post.ID
+			}
+			if post.ID <= lastID || !filter.accepts(post) {
+				continue
+			}
+			externalID := strconv.Itoa(post.ID)
+			if s.Repo.PostExistsWithExternalID(externalID) {
+				continue
+			}
+			s.syncBlogPost(&post, "queued", "")
+			// rules could skip the post
+			if s.Repo.PostExistsWithExternalID(externalID) {
+				stored++
+			}
+		}
+	}
+
+	err = s.Repo.SetSyncState(dashboardStateKey, strconv.Itoa(newestID))
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("%d dashboard posts queued\n", stored)
+	return stored
+}
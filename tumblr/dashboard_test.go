@@ -0,0 +1,53 @@
+package tumblr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func dashboardPost(id int, blogName string, notes int, tags ...string) Post {
+	return Post{
+		ID:        id,
+		Type:      "link",
+		BlogName:  blogName,
+		NoteCount: notes,
+		Tags:      tags,
+		Date:      "2017-01-02 12:33:44 CET",
+		URL:       "http://example.com/dashboard",
+	}
+}
+
+func TestDashboard(t *testing.T) {
+	teardown := setup()
+	defer teardown()
+
+	var posts []Post
+	for id := 30; id > 0; id-- {
+		posts = append(posts, dashboardPost(id, "artist", id, "art"))
+	}
+	posts[0] = dashboardPost(30, "spammer", 100, "art")
+	posts[1] = dashboardPost(29, "artist", 100, "cats")
+	mock := mockClient{DashboardPosts: posts}
+	syncer := Syncer{BlogName: "blog_with_posts", Client: &mock, Repo: repo}
+	filter := PostFilter{Blogs: []string{"artist"}, Tags: []string{"ART"}, MinNotes: 20}
+
+	// posts 20-28 pass the filter
+	assert.Equal(t, 9, syncer.Dashboard(filter))
+	stored, err := repo.GetPostByExternalID("28")
+	assert.Nil(t, err)
+	assert.Equal(t, "queued", stored.Status)
+	assert.False(t, repo.PostExistsWithExternalID("30"))
+	assert.False(t, repo.PostExistsWithExternalID("19"))
+
+	lastID, _ := repo.GetSyncState(dashboardStateKey)
+	assert.Equal(t, "30", lastID)
+
+	// only new posts are fetched
+	mock.DashboardPosts = append([]Post{dashboardPost(31, "artist", 50, "art")}, posts...)
+	assert.Equal(t, 1, syncer.Dashboard(PostFilter{}))
+	assert.True(t, repo.PostExistsWithExternalID("31"))
+	assert.False(t, repo.PostExistsWithExternalID("19"))
+	lastID, _ = repo.GetSyncState(dashboardStateKey)
+	assert.Equal(t, "31", lastID)
+}
@@ -0,0 +1,44 @@
+package tumblr
+
+import "strings"
+
+// PostFilter chooses posts imported from feeds, empty lists match any post
+type PostFilter struct {
+	Blogs    []string // blog names
+	Tags     []string // any of the tags, case-insensitive
+	Types    []string // post types
+	MinNotes int      // minimum note count
+}
+
+// accepts tells whether post passes all filters
+func (f PostFilter) accepts(post Post) bool {
+	if post.NoteCount < f.MinNotes {
+		return false
+	}
+	if len(f.Blogs) > 0 && !containsString(f.Blogs, post.BlogName) {
+		return false
+	}
+	if len(f.Types) > 0 && !containsString(f.Types, post.Type) {
+		return false
+	}
+	if len(f.Tags) == 0 {
+		return true
+	}
+	for _, tag := range post.Tags {
+		for _, filterTag := range f.Tags {
+			if strings.EqualFold(tag, filterTag) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,63 @@
+package tumblr
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// HarvestOptions choose posts harvested from a tag feed
+type HarvestOptions struct {
+	PostFilter
+	Tag   string
+	Limit int       // maximum number of stored posts, 0 means no limit
+	Since time.Time // older posts are not harvested, zero time means no limit
+}
+
+// Harvest stores posts with the tag from all blogs as queued posts for review,
+// posts stored before are skipped so harvest could be repeated. Returns number of stored posts.
+func (s Syncer) Harvest(options HarvestOptions) int {
+	fmt.Printf("Harvesting posts tagged [%s]...\n", options.Tag)
+	stored := 0
+	before := time.Now().Unix()
+	for options.Limit == 0 || stored < options.Limit {
+		posts := s.Client.Tagged(options.Tag, map[string]string{
+			"before": strconv.FormatInt(before, 10),
+			"limit":  "20",
+		})
+		// feed goes back in time, the page without older posts is the last one
+		oldest := before
+		for _, post := range posts {
+			if int64(post.Timestamp) < oldest {
+				oldest = int64(post.Timestamp)
+			}
+		}
+		if oldest == before {
+			break
+		}
+		before = oldest
+
+		for _, post := range posts {
+			if options.Limit > 0 && stored >= options.Limit {
+				break
+			}
+			if time.Unix(int64(post.Timestamp), 0).Before(options.Since) || !options.accepts(post) {
+				continue
+			}
+			externalID := strconv.Itoa(post.ID)
+			if s.Repo.PostExistsWithExternalID(externalID) {
+				continue
+			}
+			s.syncBlogPost(&post, "queued", "")
+			// rules could skip the post
+			if s.Repo.PostExistsWithExternalID(externalID) {
+				stored++
+			}
+		}
+		if time.Unix(before, 0).Before(options.Since) {
+			break
+		}
+	}
+	fmt.Printf("%d posts harvested\n", stored)
+	return stored
+}
@@ -0,0 +1,64 @@
+package tumblr
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func taggedPost(id, timestamp, notes int, postType string) Post {
+	return Post{
+		ID:        id,
+		Type:      postType,
+		BlogName:  "artist",
+		Timestamp: timestamp,
+		NoteCount: notes,
+		Date:      "2017-01-02 12:33:44 CET",
+		URL:       "http://example.com/art",
+		Body:      "art",
+	}
+}
+
+func TestHarvest(t *testing.T) {
+	teardown := setup()
+	defer teardown()
+
+	var posts []Post
+	for i := 50; i > 0; i-- {
+		postType := "link"
+		if i%10 == 0 {
+			postType = "text"
+		}
+		posts = append(posts, taggedPost(i, 1000+i, i, postType))
+	}
+	mock := mockClient{TaggedPosts: map[string][]Post{"art": posts}}
+	syncer := Syncer{BlogName: "blog_with_posts", Client: &mock, Repo: repo}
+
+	options := HarvestOptions{
+		PostFilter: PostFilter{Types: []string{"link"}, MinNotes: 5},
+		Tag:        "art",
+		Limit:      3,
+	}
+	assert.Equal(t, 3, syncer.Harvest(options))
+	for _, id := range []string{"49", "48", "47"} {
+		assert.True(t, repo.PostExistsWithExternalID(id))
+	}
+	assert.False(t, repo.PostExistsWithExternalID("50"))
+
+	stored, err := repo.GetPostByExternalID("49")
+	assert.Nil(t, err)
+	assert.Equal(t, "queued", stored.Status)
+
+	// posts harvested before are skipped
+	options.Limit = 0
+	options.Since = time.Unix(1030, 0)
+	assert.Equal(t, 15, syncer.Harvest(options))
+	assert.True(t, repo.PostExistsWithExternalID("31"))
+	assert.False(t, repo.PostExistsWithExternalID("29"))
+	assert.Equal(t, 0, syncer.Harvest(options))
+
+	var count int
+	DB.Get(&count, "SELECT count(*) FROM posts")
+	assert.Equal(t, 18, count)
+}
@@ -32,15 +32,9 @@ func (api client) info(url string, responseObject interface{}) {
 // This method GET requests only returning the []byte found
 // url - The GET URL
 func (api client) rawGet(url string) []byte {
-	request, err := http.NewRequest("GET", url, nil)
-
-	if err != nil {
-		log.Println(err)
-	}
-
-	api.oauthService.Sign(request, &api.config)
-	client := new(http.Client)
-	clientResponse, err := client.Do(request)
+	clientResponse, err := api.send(new(http.Client), func() (*http.Request, error) {
+		return http.NewRequest("GET", url, nil)
+	})
 
 	if err != nil {
 		log.Println(err)
@@ -56,6 +50,26 @@ func (api client) rawGet(url string) []byte {
 	return body
 }
 
+// This method GET requests a URL returning location it redirects to, empty when there is no redirect
+// url - The GET URL
+func (api client) redirectLocation(url string) string {
+	client := &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	clientResponse, err := api.send(client, func() (*http.Request, error) {
+		return http.NewRequest("GET", url, nil)
+	})
+	if err != nil {
+		log.Println(err)
+		return ""
+	}
+	defer clientResponse.Body.Close()
+
+	return clientResponse.Header.Get("Location")
+}
+
 // This method GET requests a URL
 // url - The GET URL
 func (api client) get(url string) Response {
@@ -73,20 +87,17 @@ func (api client) get(url string) Response {
 // url - The URL to post to
 // params - A string of the encoded parameters
 func (api client) post(url string, params string) Response {
-	request, err := http.NewRequest("POST", url, strings.NewReader(params))
-
-	if err != nil {
-		log.Println(err)
-	}
-
-	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-
-	api.oauthService.Sign(request, &api.config)
-	client := new(http.Client)
-	clientResponse, err := client.Do(request)
+	clientResponse, err := api.send(new(http.Client), func() (*http.Request, error) {
+		request, err := http.NewRequest("POST", url, strings.NewReader(params))
+		if err == nil {
+			request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		return request, err
+	})
 
 	if err != nil {
 		log.Println(err)
+		return Response{}
 	}
 	defer clientResponse.Body.Close()
 
@@ -102,3 +113,39 @@ func (api client) post(url string, params string) Response {
 	}
 	return response
 }
+
+// This method sends authorized request built by newRequest, the request is built and sent
+// once more when tumblr rejects it with 401 and the authenticator could refresh credentials.
+// Authorization failures panic with AuthError, responses to such requests are useless.
+// client - HTTP client sending the request
+// newRequest - Builds the request, called for every attempt
+func (api client) send(client *http.Client, newRequest func() (*http.Request, error)) (*http.Response, error) {
+	refreshed := false
+	for {
+		request, err := newRequest()
+		if err != nil {
+			return nil, err
+		}
+		err = api.auth.Authorize(request)
+		if err != nil {
+			panic(&AuthError{Err: err})
+		}
+		clientResponse, err := client.Do(request)
+		if err != nil || clientResponse.StatusCode != http.StatusUnauthorized {
+			return clientResponse, err
+		}
+		refresher, ok := api.auth.(Refresher)
+		if !ok {
+			return clientResponse, nil
+		}
+		clientResponse.Body.Close()
+		if refreshed {
+			panic(&AuthError{Err: fmt.Errorf("request is rejected with refreshed credentials: %s", clientResponse.Status)})
+		}
+		err = refresher.Refresh()
+		if err != nil {
+			panic(&AuthError{Err: err})
+		}
+		refreshed = true
+	}
+}
@@ -0,0 +1,148 @@
+package tumblr
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/kurrik/oauth1a"
+)
+
+// OAuthEndpoints are URLs of three-legged OAuth 1.0a flow
+type OAuthEndpoints struct {
+	RequestURL   string
+	AuthorizeURL string
+	AccessURL    string
+}
+
+// TumblrOAuthEndpoints are OAuth 1.0a endpoints of tumblr
+var TumblrOAuthEndpoints = OAuthEndpoints{
+	RequestURL:   requestTokenUrl,
+	AuthorizeURL: authorizeUrl,
+	AccessURL:    accessTokenUrl,
+}
+
+// AccessToken is OAuth 1.0a token of the user
+type AccessToken struct {
+	Key    string
+	Secret string
+}
+
+// Authorizer shows authorize URL to the user and returns token and verifier passed back by the provider,
+// empty token means the request token, other tokens than the request one are rejected
+type Authorizer func(authorizeURL string) (token, verifier string, err error)
+
+// LoginOAuth1 gets access token of the user with three-legged OAuth 1.0a flow:
+// request token is exchanged for access token after the user authorizes it.
+// Callback URL "oob" means that verifier is entered by the user.
+func LoginOAuth1(endpoints OAuthEndpoints, consumerKey, consumerSecret, callbackURL string, authorize Authorizer) (AccessToken, error) {
+	service := &oauth1a.Service{
+		RequestURL:   endpoints.RequestURL,
+		AuthorizeURL: endpoints.AuthorizeURL,
+		AccessURL:    endpoints.AccessURL,
+		ClientConfig: &oauth1a.ClientConfig{
+			ConsumerKey:    consumerKey,
+			ConsumerSecret: consumerSecret,
+			CallbackURL:    callbackURL,
+		},
+		Signer: new(oauth1a.HmacSha1Signer),
+	}
+	user := &oauth1a.UserConfig{}
+	httpClient := new(http.Client)
+
+	err := user.GetRequestToken(service, httpClient)
+	if err != nil {
+		return AccessToken{}, fmt.Errorf("can't get request token: %s", err)
+	}
+	authorizeURL, err := user.GetAuthorizeURL(service)
+	if err != nil {
+		return AccessToken{}, err
+	}
+	token, verifier, err := authorize(authorizeURL)
+	if err != nil {
+		return AccessToken{}, err
+	}
+	if token == "" {
+		token = user.RequestTokenKey
+	}
+	// callback could be forged to exchange token of another authorization
+	if token != user.RequestTokenKey {
+		return AccessToken{}, errors.New("authorized token doesn't match the request token")
+	}
+	err = user.GetAccessToken(token, verifier, service, httpClient)
+	if err != nil {
+		return AccessToken{}, fmt.Errorf("can't get access token: %s", err)
+	}
+	return AccessToken{Key: user.AccessTokenKey, Secret: user.AccessTokenSecret}, nil
+}
+
+// ParseVerifier takes verifier from pasted callback URL or returns pasted text as is
+func ParseVerifier(pasted string) string {
+	pasted = strings.TrimSpace(pasted)
+	if parsed, err := url.Parse(pasted); err == nil && parsed.Query().Get("oauth_verifier") != "" {
+		return parsed.Query().Get("oauth_verifier")
+	}
+	return pasted
+}
+
+// CallbackServer catches the redirect of authorization flow on loopback interface
+type CallbackServer struct {
+	URL      string // callback URL to pass to the provider
+	server   *http.Server
+	received chan url.Values
+}
+
+// NewCallbackServer starts callback server on a free loopback port
+func NewCallbackServer() (*CallbackServer, error) {
+	return NewCallbackServerAt("127.0.0.1:0")
+}
+
+// NewCallbackServerAt starts callback server on the address, providers
+// which check registered redirect URL need a fixed port
+func NewCallbackServerAt(address string) (*CallbackServer, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	callback := &CallbackServer{
+		URL:      "http://" + listener.Addr().String() + "/callback",
+		received: make(chan url.Values, 1),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/callback", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case callback.received <- r.URL.Query():
+		default:
+			// only the first callback counts
+		}
+		fmt.Fprintln(w, "Bellboy got the answer, this page could be closed.")
+	})
+	callback.server = &http.Server{Handler: mux}
+	go callback.server.Serve(listener)
+	return callback, nil
+}
+
+// Wait returns query parameters of the callback
+func (c *CallbackServer) Wait(timeout time.Duration) (url.Values, error) {
+	select {
+	case query := <-c.received:
+		if denied := query.Get("denied"); denied != "" {
+			return query, errors.New("authorization is denied")
+		}
+		if errorCode := query.Get("error"); errorCode != "" {
+			return query, fmt.Errorf("authorization failed: %s", errorCode)
+		}
+		return query, nil
+	case <-time.After(timeout):
+		return nil, errors.New("authorization timed out")
+	}
+}
+
+// Close stops callback server
+func (c *CallbackServer) Close() error {
+	return c.server.Close()
+}
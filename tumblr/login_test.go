@@ -0,0 +1,126 @@
+package tumblr
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeOAuthProvider implements three-legged OAuth 1.0a flow with fixed tokens
+func fakeOAuthProvider(t *testing.T) *httptest.Server {
+	var callbackURL string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/request_token", func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		assert.Contains(t, auth, `oauth_consumer_key="consumer"`)
+		callbackURL = authParam(auth, "oauth_callback")
+		fmt.Fprint(w, "oauth_token=request&oauth_token_secret=request_secret&oauth_callback_confirmed=true")
+	})
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		token := r.URL.Query().Get("oauth_token")
+		http.Redirect(w, r, callbackURL+"?oauth_token="+token+"&oauth_verifier=verifier", http.StatusFound)
+	})
+	mux.HandleFunc("/access_token", func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		if authParam(auth, "oauth_token") != "request" || authParam(auth, "oauth_verifier") != "verifier" {
+			http.Error(w, "wrong verifier", http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, "oauth_token=access&oauth_token_secret=access_secret")
+	})
+	return httptest.NewServer(mux)
+}
+
+// authParam returns unescaped parameter of OAuth authorization header
+func authParam(header, name string) string {
+	for _, part := range strings.Split(strings.TrimPrefix(header, "OAuth "), ", ") {
+		if strings.HasPrefix(part, name+"=") {
+			value, _ := url.QueryUnescape(strings.Trim(strings.TrimPrefix(part, name+"="), `"`))
+			return value
+		}
+	}
+	return ""
+}
+
+func fakeEndpoints(provider *httptest.Server) OAuthEndpoints {
+	return OAuthEndpoints{
+		RequestURL:   provider.URL + "/request_token",
+		AuthorizeURL: provider.URL + "/authorize",
+		AccessURL:    provider.URL + "/access_token",
+	}
+}
+
+func TestLoginOAuth1WithCallback(t *testing.T) {
+	provider := fakeOAuthProvider(t)
+	defer provider.Close()
+	callback, err := NewCallbackServer()
+	assert.Nil(t, err)
+	defer callback.Close()
+
+	token, err := LoginOAuth1(fakeEndpoints(provider), "consumer", "secret", callback.URL,
+		func(authorizeURL string) (string, string, error) {
+			// user approves access in the browser
+			response, err := http.Get(authorizeURL)
+			assert.Nil(t, err)
+			response.Body.Close()
+			query, err := callback.Wait(time.Second)
+			return query.Get("oauth_token"), query.Get("oauth_verifier"), err
+		})
+	assert.Nil(t, err)
+	assert.Equal(t, AccessToken{Key: "access", Secret: "access_secret"}, token)
+}
+
+func TestLoginOAuth1RejectsForeignToken(t *testing.T) {
+	provider := fakeOAuthProvider(t)
+	defer provider.Close()
+	callback, err := NewCallbackServer()
+	assert.Nil(t, err)
+	defer callback.Close()
+
+	_, err = LoginOAuth1(fakeEndpoints(provider), "consumer", "secret", callback.URL,
+		func(authorizeURL string) (string, string, error) {
+			// callback is opened with token of another authorization
+			response, err := http.Get(callback.URL + "?oauth_token=forged&oauth_verifier=verifier")
+			assert.Nil(t, err)
+			response.Body.Close()
+			query, err := callback.Wait(time.Second)
+			return query.Get("oauth_token"), query.Get("oauth_verifier"), err
+		})
+	assert.EqualError(t, err, "authorized token doesn't match the request token")
+}
+
+func TestLoginOAuth1WithPastedVerifier(t *testing.T) {
+	provider := fakeOAuthProvider(t)
+	defer provider.Close()
+
+	token, err := LoginOAuth1(fakeEndpoints(provider), "consumer", "secret", "oob",
+		func(authorizeURL string) (string, string, error) {
+			return "", ParseVerifier(" http://localhost/?oauth_token=request&oauth_verifier=verifier\n"), nil
+		})
+	assert.Nil(t, err)
+	assert.Equal(t, AccessToken{Key: "access", Secret: "access_secret"}, token)
+
+	_, err = LoginOAuth1(fakeEndpoints(provider), "consumer", "secret", "oob",
+		func(authorizeURL string) (string, string, error) {
+			return "", ParseVerifier("wrong"), nil
+		})
+	assert.NotNil(t, err)
+}
+
+func TestCallbackServerDenied(t *testing.T) {
+	callback, err := NewCallbackServer()
+	assert.Nil(t, err)
+	defer callback.Close()
+
+	response, err := http.Get(callback.URL + "?denied=request")
+	assert.Nil(t, err)
+	response.Body.Close()
+	_, err = callback.Wait(time.Second)
+	assert.EqualError(t, err, "authorization is denied")
+}
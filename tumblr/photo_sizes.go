@@ -0,0 +1,73 @@
+package tumblr
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PhotoSizePolicy decides which of the photo sizes offered by tumblr are downloaded.
+// Supported policies:
+//   - largest - original size, falls back to the biggest alternate size
+//   - max-width:N - biggest size not wider than N pixels, falls back to bigger ones
+//   - all-sizes - every available size is stored as a separate photo
+type PhotoSizePolicy struct {
+	MaxWidth int  // maximum width of downloaded photo, 0 means no limit
+	AllSizes bool // download all available sizes
+}
+
+// ParsePhotoSizePolicy parses policy from its config representation
+func ParsePhotoSizePolicy(policy string) (PhotoSizePolicy, error) {
+	switch {
+	case policy == "" || policy == "largest":
+		return PhotoSizePolicy{}, nil
+	case policy == "all-sizes":
+		return PhotoSizePolicy{AllSizes: true}, nil
+	case strings.HasPrefix(policy, "max-width:"):
+		maxWidth, err := strconv.Atoi(strings.TrimPrefix(policy, "max-width:"))
+		if err != nil || maxWidth <= 0 {
+			return PhotoSizePolicy{}, fmt.Errorf("invalid photo size policy [%s]: width should be positive number", policy)
+		}
+		return PhotoSizePolicy{MaxWidth: maxWidth}, nil
+	}
+	return PhotoSizePolicy{}, fmt.Errorf("unknown photo size policy [%s]", policy)
+}
+
+// candidates returns photo sizes in the order they should be tried
+func (policy PhotoSizePolicy) candidates(photo PostPhoto) []PhotoSize {
+	var sizes []PhotoSize
+	seen := map[string]bool{}
+	for _, size := range append([]PhotoSize{photo.OriginalSize}, photo.AlternateSizes...) {
+		if size.URL == "" || seen[size.URL] {
+			continue
+		}
+		seen[size.URL] = true
+		sizes = append(sizes, size)
+	}
+
+	// size without width is most likely the original one
+	width := func(size PhotoSize) int {
+		if size.Width == 0 {
+			return math.MaxInt32
+		}
+		return size.Width
+	}
+	sort.SliceStable(sizes, func(i, j int) bool {
+		return width(sizes[i]) > width(sizes[j])
+	})
+
+	if policy.MaxWidth == 0 {
+		return sizes
+	}
+	var fitting, wider []PhotoSize
+	for _, size := range sizes {
+		if width(size) > policy.MaxWidth {
+			wider = append([]PhotoSize{size}, wider...)
+		} else {
+			fitting = append(fitting, size)
+		}
+	}
+	return append(fitting, wider...)
+}
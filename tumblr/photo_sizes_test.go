@@ -0,0 +1,134 @@
+package tumblr
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/altmer/bellboy/media"
+	"github.com/stretchr/testify/assert"
+	httpmock "gopkg.in/jarcoal/httpmock.v1"
+)
+
+var multiSizePhoto = PostPhoto{
+	Caption:      "sized photo",
+	OriginalSize: PhotoSize{Width: 2000, Height: 1000, URL: "http://photo.tumblr/photo_2000.png"},
+	AlternateSizes: []PhotoSize{
+		{Width: 2000, Height: 1000, URL: "http://photo.tumblr/photo_2000.png"},
+		{Width: 500, Height: 250, URL: "http://photo.tumblr/photo_500.png"},
+		{Width: 1280, Height: 640, URL: "http://photo.tumblr/photo_1280.png"},
+		{Width: 100, Height: 50, URL: "http://photo.tumblr/photo_100.png"},
+	},
+}
+
+func TestParsePhotoSizePolicy(t *testing.T) {
+	testCases := []struct {
+		policy   string
+		expected PhotoSizePolicy
+		err      error
+	}{
+		{"", PhotoSizePolicy{}, nil},
+		{"largest", PhotoSizePolicy{}, nil},
+		{"all-sizes", PhotoSizePolicy{AllSizes: true}, nil},
+		{"max-width:1280", PhotoSizePolicy{MaxWidth: 1280}, nil},
+		{"max-width:abc", PhotoSizePolicy{}, errors.New("invalid photo size policy [max-width:abc]: width should be positive number")},
+		{"smallest", PhotoSizePolicy{}, errors.New("unknown photo size policy [smallest]")},
+	}
+
+	for _, testCase := range testCases {
+		actual, err := ParsePhotoSizePolicy(testCase.policy)
+		assert.Equal(t, testCase.err, err)
+		assert.Equal(t, testCase.expected, actual)
+	}
+}
+
+func TestPhotoSizeCandidates(t *testing.T) {
+	urls := func(sizes []PhotoSize) []string {
+		var result []string
+		for _, size := range sizes {
+			result = append(result, size.URL)
+		}
+		return result
+	}
+
+	testCases := []struct {
+		policy   PhotoSizePolicy
+		photo    PostPhoto
+		expected []string
+	}{
+		{PhotoSizePolicy{}, multiSizePhoto, []string{
+			"http://photo.tumblr/photo_2000.png", "http://photo.tumblr/photo_1280.png",
+			"http://photo.tumblr/photo_500.png", "http://photo.tumblr/photo_100.png",
+		}},
+		{PhotoSizePolicy{MaxWidth: 1280}, multiSizePhoto, []string{
+			"http://photo.tumblr/photo_1280.png", "http://photo.tumblr/photo_500.png",
+			"http://photo.tumblr/photo_100.png", "http://photo.tumblr/photo_2000.png",
+		}},
+		{PhotoSizePolicy{MaxWidth: 50}, multiSizePhoto, []string{
+			"http://photo.tumblr/photo_100.png", "http://photo.tumblr/photo_500.png",
+			"http://photo.tumblr/photo_1280.png", "http://photo.tumblr/photo_2000.png",
+		}},
+		{PhotoSizePolicy{}, PostPhoto{
+			OriginalSize:   PhotoSize{URL: "http://photo.tumblr/original.png"},
+			AlternateSizes: []PhotoSize{{Width: 500, URL: "http://photo.tumblr/500.png"}},
+		}, []string{"http://photo.tumblr/original.png", "http://photo.tumblr/500.png"}},
+		{PhotoSizePolicy{}, PostPhoto{}, nil},
+	}
+
+	for _, testCase := range testCases {
+		assert.Equal(t, testCase.expected, urls(testCase.policy.candidates(testCase.photo)))
+	}
+}
+
+func TestSyncPhotoFallback(t *testing.T) {
+	teardown := setup()
+	defer teardown()
+
+	httpmock.RegisterResponder("GET", "http://photo.tumblr/photo_2000.png",
+		httpmock.NewStringResponder(404, "not found"))
+	httpmock.RegisterResponder("GET", "http://photo.tumblr/photo_1280.png",
+		httpmock.NewStringResponder(200, "png file contents"))
+
+	post := &media.Post{ExternalID: "42", Type: "photo"}
+	repo.AddPost(post)
+
+	err := Syncer{Repo: repo}.syncPhoto(post, multiSizePhoto)
+	assert.Nil(t, err)
+
+	var photos []media.Photo
+	DB.Select(&photos, "SELECT id, external_url, width, height FROM photos WHERE post_id = ?", post.ID)
+
+	assert.Equal(t, 1, len(photos))
+	assert.Equal(t, "http://photo.tumblr/photo_1280.png", photos[0].ExternalURL)
+	assert.Equal(t, 1280, photos[0].Width)
+	assert.Equal(t, 640, photos[0].Height)
+	assert.Equal(t, uint(2), photos[0].ID, "row of failed download should not be left")
+	removeFile(repo.GetPhotoPath(&photos[0]))
+}
+
+func TestSyncPhotoAllSizes(t *testing.T) {
+	teardown := setup()
+	defer teardown()
+
+	for _, url := range []string{
+		"http://photo.tumblr/photo_2000.png", "http://photo.tumblr/photo_1280.png",
+		"http://photo.tumblr/photo_500.png", "http://photo.tumblr/photo_100.png",
+	} {
+		httpmock.RegisterResponder("GET", url, httpmock.NewStringResponder(200, "png file contents"))
+	}
+
+	post := &media.Post{ExternalID: "43", Type: "photo"}
+	repo.AddPost(post)
+
+	err := Syncer{Repo: repo, PhotoSizes: PhotoSizePolicy{AllSizes: true}}.syncPhoto(post, multiSizePhoto)
+	assert.Nil(t, err)
+
+	var photos []media.Photo
+	DB.Select(&photos, "SELECT id, external_url, width FROM photos WHERE post_id = ? ORDER BY width DESC", post.ID)
+
+	assert.Equal(t, 4, len(photos))
+	assert.Equal(t, 2000, photos[0].Width)
+	assert.Equal(t, 100, photos[3].Width)
+	for _, photo := range photos {
+		removeFile(repo.GetPhotoPath(&photo))
+	}
+}
@@ -1,8 +1,10 @@
 package tumblr
 
 import (
+	"errors"
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/altmer/bellboy/media"
@@ -11,29 +13,179 @@ import (
 // Syncer represents the main syncing entity
 type Syncer struct {
 	BlogName string
-	Client   API
-	Repo     media.Repository
+	// Blogs of the account synced in one run, only BlogName is synced when empty
+	Blogs []string
+	// Account is recorded in every synced post
+	Account string
+
+	Client     API
+	Repo       media.Repository
+	PhotoSizes PhotoSizePolicy
+	Tags       media.TagNormalizer
+	// SuggestTags stores tags of queued posts as suggestions promoted on approval
+	SuggestTags bool
+	// Rules change or skip new posts before they are stored
+	Rules *media.Rules
+	// Refresh updates posts synced before with the policy, existing posts are skipped when nil
+	Refresh *media.PostMergePolicy
+	// DryRun only prints subscriptions changes
+	DryRun bool
+	// Unfollow lets SubsUp unfollow blogs which are not subscribed locally
+	Unfollow bool
 }
 
-// Sync syncs tumblr blog with given config
+// Sync syncs tumblr blogs and likes of the account with given config
 func (s Syncer) Sync() {
-	fmt.Printf("Getting info from Tumblr blog [%s]\n", s.BlogName)
-	totalPosts := s.Client.BlogPosts(s.BlogName, map[string]string{}).TotalPosts
-	fmt.Printf("%d posts found\n", totalPosts)
-	s.syncBlogPosts(totalPosts)
+	for _, blogName := range s.blogs() {
+		fmt.Printf("Getting info from Tumblr blog [%s]\n", blogName)
+		totalPosts := s.Client.BlogPosts(blogName, map[string]string{}).TotalPosts
+		fmt.Printf("%d posts found\n", totalPosts)
+		s.syncBlogPosts(blogName, totalPosts)
+	}
 	totalLikes := s.Client.UserLikes(map[string]string{}).LikedCount
 	fmt.Printf("%d user likes found\n", totalLikes)
 	s.syncLikes(totalLikes)
 	fmt.Println("Tumblr synced!")
 }
 
-// SubsDown imports subscriptions from tumblr
+// Mirror archives all posts of any blog, posts are never removed from tumblr.
+// Runs are incremental: posts archived by the previous complete run are skipped and paging stops
+// at the first page which has only such posts. Pinned or reordered posts don't stop it early.
+func (s Syncer) Mirror(blogName string) {
+	mirror, err := s.Repo.GetMirror(blogName)
+	if err != nil {
+		mirror = media.Mirror{BlogName: blogName}
+	}
+	totalPosts := s.Client.BlogPosts(blogName, map[string]string{}).TotalPosts
+	fmt.Printf("%d posts found in [%s]\n", totalPosts, blogName)
+
+	newest := mirror.NewestPostAt
+	limit := 20
+	done := false
+	for offset := 0; offset < totalPosts && !done; offset += limit {
+		fmt.Printf("Fetching posts from [%d] to [%d]...\n", offset, offset+limit)
+		posts := s.Client.BlogPosts(blogName, map[string]string{
+			"offset": strconv.Itoa(offset),
+			"limit":  strconv.Itoa(limit),
+		})
+		if len(posts.Posts) == 0 {
+			fmt.Printf("No posts received at offset [%d] of [%d], blog [%s] is not mirrored completely\n", offset, totalPosts, blogName)
+			return
+		}
+		archived := 0
+		for _, post := range posts.Posts {
+			postedAt := unixTime(post.Timestamp)
+			if postedAt != nil && mirror.NewestPostAt != nil && !postedAt.After(*mirror.NewestPostAt) {
+				archived++
+				continue
+			}
+			// posts are kept on tumblr whatever is returned
+			s.syncBlogPost(&post, "added", blogName)
+			if postedAt != nil && (newest == nil || postedAt.After(*newest)) {
+				newest = postedAt
+			}
+		}
+		done = archived == len(posts.Posts)
+	}
+
+	// progress is saved only after complete run so that interrupted runs start over
+	now := time.Now()
+	mirror.NewestPostAt = newest
+	mirror.SyncedAt = &now
+	err = s.Repo.SaveMirror(&mirror)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("Blog [%s] mirrored!\n", blogName)
+}
+
+// SubsDown makes local subscriptions match tumblr ones: new tumblr subscriptions are added
+// and blogs unfollowed on tumblr since the last sync are removed. Local notes and
+// subscriptions which were never followed on tumblr are kept. Nothing is removed when
+// tumblr returns no subscriptions, since that is more likely a failed request.
 func (s Syncer) SubsDown() {
+	local, err := s.Repo.ListSubscriptions()
+	if err != nil {
+		panic(err)
+	}
+	remote := s.remoteSubscriptions()
+	diff := media.DiffSubscriptions(local, remote)
+	printSubsDiff(diff)
+	if s.DryRun {
+		return
+	}
+	removed := diff.Removed
+	if len(remote) == 0 && len(removed) > 0 {
+		fmt.Printf("No tumblr subscriptions received, refusing to remove %d local ones\n", len(removed))
+		removed = nil
+	}
+	for _, sub := range diff.Added {
+		s.Repo.AddSubscription(&sub)
+	}
+	for _, sub := range removed {
+		s.Repo.RemoveSubscription(sub.ID)
+	}
+	for _, change := range diff.Changed {
+		sub := change.Remote
+		sub.ID = change.Local.ID
+		s.Repo.UpdateSubscription(&sub)
+	}
+	var synced []string
+	for _, sub := range remote {
+		synced = append(synced, sub.BlogName)
+	}
+	err = s.Repo.MarkSubscriptionsSynced(synced, time.Now())
+	if err != nil {
+		panic(err)
+	}
+}
+
+// SubsUp makes tumblr subscriptions match local ones: follows local blogs which were never
+// followed on tumblr and, with Unfollow, unfollows blogs which are not subscribed locally.
+// Blogs unfollowed on tumblr since the last sync are not followed again. Nothing is
+// unfollowed when there are no local subscriptions, since that is more likely an empty
+// database than a wish to unfollow everything.
+func (s Syncer) SubsUp() {
+	local, err := s.Repo.ListSubscriptions()
+	if err != nil {
+		panic(err)
+	}
+	diff := media.DiffSubscriptions(local, s.remoteSubscriptions())
+	unfollow := diff.Added
+	if len(diff.Added) > 0 && !s.Unfollow {
+		fmt.Printf("%d blogs are not subscribed locally, they are unfollowed only with --unfollow\n", len(diff.Added))
+		unfollow = nil
+	}
+	if len(unfollow) > 0 && len(local) == 0 {
+		fmt.Printf("There are no local subscriptions, refusing to unfollow %d blogs\n", len(unfollow))
+		unfollow = nil
+	}
+	fmt.Printf("%d blogs to follow, %d blogs to unfollow\n", len(diff.LocalOnly), len(unfollow))
+	var followed []string
+	for _, sub := range diff.LocalOnly {
+		fmt.Printf("follow [%s]\n", sub.URL)
+		if !s.DryRun {
+			s.Client.UserFollow(sub.URL)
+			followed = append(followed, sub.BlogName)
+		}
+	}
+	for _, sub := range unfollow {
+		fmt.Printf("unfollow [%s]\n", sub.URL)
+		if !s.DryRun {
+			s.Client.UserUnfollow(sub.URL)
+		}
+	}
+	err = s.Repo.MarkSubscriptionsSynced(followed, time.Now())
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (s Syncer) remoteSubscriptions() []media.Subscription {
 	totalSubscriptions := s.Client.UserFollowing(map[string]string{}).TotalBlogs
 	fmt.Printf("%d user subscriptions found\n", totalSubscriptions)
 
-	s.Repo.RemoveAllSubscriptions()
-
+	var remote []media.Subscription
 	limit := 20
 	for offset := 0; offset < totalSubscriptions; offset += limit {
 		fmt.Printf("Fetching subscriptions from [%d] to [%d]...\n", offset, offset+limit)
@@ -42,41 +194,95 @@ func (s Syncer) SubsDown() {
 			"limit":  strconv.Itoa(limit),
 		})
 		for _, blog := range subscriptions.Blogs {
-			s.Repo.AddSubscription(&media.Subscription{
+			remote = append(remote, media.Subscription{
 				BlogName:    blog.Name,
 				URL:         blog.URL,
 				Description: blog.Description,
 				Title:       blog.Title,
 				Source:      "tumblr",
+				LastPostAt:  unixTime(blog.Updated),
 			})
 		}
 	}
+	return remote
 }
 
-// SubsUp exports subscriptions to tumblr blog (follows all of them)
-func (s Syncer) SubsUp() {
-	fmt.Println("Exporting subscriptions...")
+// SubsRefresh fetches activity, post count and avatar of every subscribed blog
+func (s Syncer) SubsRefresh() {
 	subs, err := s.Repo.ListSubscriptions()
 	if err != nil {
 		panic(err)
 	}
-	for _, sub := range subs {
-		s.Client.UserFollow(sub.URL)
+	fmt.Printf("Refreshing %d subscriptions...\n", len(subs))
+	for i := range subs {
+		sub := &subs[i]
+		info := s.Client.BlogInfo(sub.BlogName).Blog
+		if info.Name == "" {
+			fmt.Printf("Can't get info of [%s]\n", sub.BlogName)
+			continue
+		}
+		sub.LastPostAt = unixTime(info.Updated)
+		sub.PostCount = info.PostCount
+		avatar := s.Client.BlogAvatar(sub.BlogName, avatarSize)
+		err := s.Repo.RefreshSubscription(sub, avatar.AvatarURL)
+		if err != nil {
+			fmt.Printf("Can't refresh [%s]: %s\n", sub.BlogName, err)
+		}
+	}
+}
+
+// avatarSize is the size of downloaded avatars in pixels
+const avatarSize = 128
+
+// unixTime converts tumblr timestamp in seconds, zero means unknown time
+func unixTime(seconds int) *time.Time {
+	if seconds == 0 {
+		return nil
+	}
+	t := time.Unix(int64(seconds), 0)
+	return &t
+}
+
+// printSubsDiff prints diff from the local point of view: added are new tumblr subscriptions,
+// removed are unfollowed on tumblr since the last sync and local ones were never followed there
+func printSubsDiff(diff media.SubscriptionsDiff) {
+	if diff.Empty() {
+		fmt.Println("Subscriptions are in sync")
+	}
+	for _, sub := range diff.Added {
+		fmt.Printf("+ %s [%s]\n", sub.BlogName, sub.URL)
+	}
+	for _, sub := range diff.Removed {
+		fmt.Printf("- %s [%s]\n", sub.BlogName, sub.URL)
+	}
+	for _, change := range diff.Changed {
+		fmt.Printf("~ %s: %s\n", change.Local.BlogName, strings.Join(change.Fields, ", "))
+	}
+	for _, sub := range diff.LocalOnly {
+		fmt.Printf("* %s [%s] local only\n", sub.BlogName, sub.URL)
 	}
 }
 
-func (s Syncer) syncBlogPosts(totalPosts int) {
+// blogs returns names of account blogs to sync
+func (s Syncer) blogs() []string {
+	if len(s.Blogs) > 0 {
+		return s.Blogs
+	}
+	return []string{s.BlogName}
+}
+
+func (s Syncer) syncBlogPosts(blogName string, totalPosts int) {
 	limit := 20
 	for offset := 0; offset < totalPosts; offset += limit {
 		fmt.Printf("Fetching posts from [%d] to [%d]...\n", offset, offset+limit)
-		posts := s.Client.BlogPosts(s.BlogName, map[string]string{
+		posts := s.Client.BlogPosts(blogName, map[string]string{
 			"offset": strconv.Itoa(offset),
 			"limit":  strconv.Itoa(limit),
 		})
 		for _, post := range posts.Posts {
-			result := s.syncBlogPost(&post, "added")
+			result := s.syncBlogPost(&post, "added", blogName)
 			if result {
-				s.Client.PostDelete(s.BlogName, post.ID)
+				s.Client.PostDelete(blogName, post.ID)
 			}
 		}
 	}
@@ -91,7 +297,7 @@ func (s Syncer) syncLikes(totalLikes int) {
 			"limit":  strconv.Itoa(limit),
 		})
 		for _, post := range likes.LikedPost {
-			result := s.syncBlogPost(&post, "queued")
+			result := s.syncBlogPost(&post, "queued", "")
 			if result {
 				s.Client.UserUnlike(post.ID, post.ReblogKey)
 			}
@@ -99,9 +305,15 @@ func (s Syncer) syncLikes(totalLikes int) {
 	}
 }
 
-func (s Syncer) syncBlogPost(externalPost *Post, state string) bool {
+// syncBlogPost stores new post synced from the blog, blog name is empty for likes and feeds.
+// Returns true when the post could be removed from tumblr.
+func (s Syncer) syncBlogPost(externalPost *Post, state, blogName string) bool {
 	if s.Repo.PostExistsWithExternalID(strconv.Itoa(externalPost.ID)) {
-		fmt.Printf("Post with id [%d] already exists\n", externalPost.ID)
+		if s.Refresh != nil {
+			s.refreshPost(externalPost)
+		} else {
+			fmt.Printf("Post with id [%d] already exists\n", externalPost.ID)
+		}
 		return false
 	}
 
@@ -111,6 +323,21 @@ func (s Syncer) syncBlogPost(externalPost *Post, state string) bool {
 		return false
 	}
 	post.Status = state
+	post.Account = s.Account
+	post.Blog = blogName
+	settings := s.blogSettings(externalPost.BlogName)
+	if settings.AutoApprove {
+		post.Status = "added"
+	}
+	if settings.SFW != nil {
+		post.SFW = *settings.SFW
+	}
+	// rules see default tags of the blog and could override its settings
+	rules := s.Rules.Apply(post, append(append([]string{}, externalPost.Tags...), settings.Tags()...))
+	if rules.Skip {
+		fmt.Printf("Post with id [%d] skipped by rule [%s]\n", externalPost.ID, rules.Fired[len(rules.Fired)-1])
+		return false
+	}
 	err = s.Repo.AddPost(post)
 	if err != nil {
 		fmt.Printf("WARN: Post creation failed with error [%s] for post [%#v]\n", err, post)
@@ -134,10 +361,9 @@ func (s Syncer) syncBlogPost(externalPost *Post, state string) bool {
 		}
 	case "photo":
 		for _, externalPhoto := range externalPost.Photos {
-			photo := createPhoto(post, externalPhoto.OriginalSize.URL, externalPhoto.Caption)
-			err = s.Repo.AddPhoto(photo)
+			err = s.syncPhoto(post, externalPhoto)
 			if err != nil {
-				fmt.Printf("WARN: Photo creation failed with error [%s] for photo [%#v]", err, photo)
+				fmt.Printf("WARN: Photo creation failed with error [%s] for photo [%#v]", err, externalPhoto)
 				return false
 			}
 		}
@@ -153,15 +379,117 @@ func (s Syncer) syncBlogPost(externalPost *Post, state string) bool {
 		return false
 	}
 
-	// we load tags only for finished posts
-	if post.Status != "added" {
-		return true
+	// tags of queued posts are only suggested
+	switch {
+	case post.Status == "added":
+		s.syncTags(rules.Tags, func(tag string) error { return s.Repo.AddTagToPost(post, tag) })
+	case s.SuggestTags:
+		s.syncTags(rules.Tags, func(tag string) error { return s.Repo.AddSuggestedTagToPost(post, tag) })
+	}
+	return !settings.Archive
+}
+
+// blogSettings returns sync settings of subscribed blog, other blogs get default settings
+func (s Syncer) blogSettings(blogName string) media.SubscriptionSettings {
+	sub, err := s.Repo.GetSubscription(blogName)
+	if err != nil {
+		return media.SubscriptionSettings{}
+	}
+	return sub.SubscriptionSettings
+}
+
+// refreshPost updates mutable fields of stored post and adds tags it didn't have,
+// local edits of other fields and local tags are kept
+func (s Syncer) refreshPost(externalPost *Post) {
+	stored, err := s.Repo.GetPostByExternalID(strconv.Itoa(externalPost.ID))
+	if err != nil {
+		fmt.Printf("WARN: Post refresh failed with error [%s]\n", err)
+		return
+	}
+	if stored.DeletedAt != nil {
+		fmt.Printf("Post with id [%d] is deleted, not refreshed\n", externalPost.ID)
+		return
+	}
+	fresh, err := createPost(externalPost)
+	if err != nil {
+		fmt.Printf("WARN: Post refresh failed with error [%s] for post [%d]\n", err, externalPost.ID)
+		return
+	}
+	changed := s.Refresh.Merge(&stored, *fresh)
+
+	// known tags are taken from the table new tags go to
+	listTags, addTag := s.Repo.ListPostAssignedTags, s.Repo.AddTagToPost
+	if stored.Status != "added" {
+		listTags, addTag = s.Repo.ListPostSuggestedTags, s.Repo.AddSuggestedTagToPost
+	}
+	tags, err := listTags(stored.ID)
+	if err != nil {
+		fmt.Printf("WARN: Post refresh failed with error [%s] for post [%d]\n", err, externalPost.ID)
+		return
+	}
+	known := map[string]bool{}
+	for _, tag := range tags {
+		known[tag] = true
+	}
+	if stored.Status == "added" || s.SuggestTags {
+		s.syncTags(externalPost.Tags, func(tag string) error {
+			if known[tag] {
+				return nil
+			}
+			changed = append(changed, "tag "+tag)
+			return addTag(&stored, tag)
+		})
 	}
 
-	for _, externalTag := range externalPost.Tags {
-		s.Repo.AddTagToPost(post, externalTag)
+	err = s.Repo.UpdatePost(&stored)
+	if err != nil {
+		fmt.Printf("WARN: Post refresh failed with error [%s] for post [%d]\n", err, externalPost.ID)
+		return
+	}
+	if len(changed) > 0 {
+		fmt.Printf("Post with id [%d] refreshed: %s\n", externalPost.ID, strings.Join(changed, ", "))
+	}
+}
+
+// syncTags normalizes and resolves external tags and adds each resulting tag once
+func (s Syncer) syncTags(externalTags []string, addTag func(string) error) {
+	// normalization and aliases could map several external tags to the same one
+	added := map[string]bool{}
+	for _, externalTag := range externalTags {
+		tag := s.Tags.Normalize(externalTag)
+		if tag == "" {
+			continue
+		}
+		tag = s.Repo.ResolveTag(tag)
+		if added[tag] {
+			continue
+		}
+		added[tag] = true
+		addTag(tag)
+	}
+}
+
+// syncPhoto stores photo in the size chosen by photo size policy,
+// next best size is tried when download fails
+func (s Syncer) syncPhoto(post *media.Post, externalPhoto PostPhoto) error {
+	err := errors.New("photo has no sizes to download")
+	saved := 0
+	for _, size := range s.PhotoSizes.candidates(externalPhoto) {
+		photo := createPhoto(post, size, externalPhoto.Caption)
+		err = s.Repo.AddPhoto(photo)
+		if err != nil {
+			fmt.Printf("WARN: Photo download failed with error [%s] for url [%s]\n", err, size.URL)
+			continue
+		}
+		saved++
+		if !s.PhotoSizes.AllSizes {
+			break
+		}
+	}
+	if saved > 0 {
+		return nil
 	}
-	return true
+	return err
 }
 
 func createPost(externalPost *Post) (*media.Post, error) {
@@ -200,11 +528,13 @@ func createVideo(post *media.Post, externalPost *Post) *media.Video {
 	}
 }
 
-func createPhoto(post *media.Post, url, caption string) *media.Photo {
+func createPhoto(post *media.Post, size PhotoSize, caption string) *media.Photo {
 	return &media.Photo{
 		PostID:      post.ID,
 		Caption:     caption,
-		ExternalURL: url,
+		ExternalURL: size.URL,
 		SFW:         false,
+		Width:       size.Width,
+		Height:      size.Height,
 	}
 }
@@ -0,0 +1,41 @@
+package tumblr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSyncAccountBlogs(t *testing.T) {
+	teardown := setup()
+	defer teardown()
+
+	first := mirroredPost(100, 100)
+	first.BlogName = "first"
+	second := mirroredPost(200, 200)
+	second.BlogName = "second"
+	mock := mockClient{Blogs: map[string][]Post{"first": {first}, "second": {second}}}
+
+	Syncer{
+		BlogName: "first",
+		Blogs:    []string{"first", "second"},
+		Account:  "work",
+		Client:   &mock,
+		Repo:     repo,
+	}.Sync()
+
+	assert.Equal(t, []int{100, 200}, mock.DeletedPosts)
+	post, err := repo.GetPostByExternalID("100")
+	assert.Nil(t, err)
+	assert.Equal(t, "work", post.Account)
+	assert.Equal(t, "first", post.Blog)
+	post, _ = repo.GetPostByExternalID("200")
+	assert.Equal(t, "work", post.Account)
+	assert.Equal(t, "second", post.Blog)
+
+	// likes are synced once per account
+	post, _ = repo.GetPostByExternalID("14")
+	assert.Equal(t, "work", post.Account)
+	assert.Equal(t, "", post.Blog)
+	assert.Equal(t, []int{14, 18}, mock.UnlikedPosts)
+}
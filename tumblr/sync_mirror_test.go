@@ -0,0 +1,93 @@
+package tumblr
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func mirroredPost(id, timestamp int) Post {
+	return Post{
+		ID:        id,
+		Type:      "link",
+		BlogName:  "artist",
+		Timestamp: timestamp,
+		Date:      "2017-01-02 12:33:44 CET",
+		URL:       "http://example.com/art",
+	}
+}
+
+func TestMirror(t *testing.T) {
+	teardown := setup()
+	defer teardown()
+
+	mock := mockClient{Blogs: map[string][]Post{
+		"artist": {mirroredPost(3, 300), mirroredPost(2, 200), mirroredPost(1, 100)},
+	}}
+	syncer := Syncer{BlogName: "blog_with_posts", Client: &mock, Repo: repo}
+	syncer.Mirror("artist")
+
+	assert.Empty(t, mock.DeletedPosts)
+	mirror, err := repo.GetMirror("artist")
+	assert.Nil(t, err)
+	assert.Equal(t, time.Unix(300, 0).Unix(), mirror.NewestPostAt.Unix())
+	assert.NotNil(t, mirror.SyncedAt)
+
+	// older posts are not fetched again
+	DB.Exec("DELETE FROM posts WHERE external_id = '1'")
+	mock.Blogs["artist"] = append([]Post{mirroredPost(4, 400)}, mock.Blogs["artist"]...)
+	syncer.Mirror("artist")
+
+	assert.True(t, repo.PostExistsWithExternalID("4"))
+	assert.False(t, repo.PostExistsWithExternalID("1"))
+	mirrors, err := repo.ListMirrors()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(mirrors))
+	assert.Equal(t, "artist", mirrors[0].BlogName)
+	assert.Equal(t, 3, mirrors[0].Posts)
+	assert.Equal(t, time.Unix(400, 0).Unix(), mirrors[0].NewestPostAt.Unix())
+}
+
+func TestMirrorSkipsPinnedPosts(t *testing.T) {
+	teardown := setup()
+	defer teardown()
+
+	var posts []Post
+	for id := 30; id > 0; id-- {
+		posts = append(posts, mirroredPost(id, id*100))
+	}
+	mock := mockClient{Blogs: map[string][]Post{"artist": posts}}
+	syncer := Syncer{BlogName: "blog_with_posts", Client: &mock, Repo: repo}
+	syncer.Mirror("artist")
+
+	// old pinned post goes first, new posts follow it
+	DB.Exec("DELETE FROM posts WHERE external_id = '1'")
+	mock.Blogs["artist"] = append([]Post{mirroredPost(5, 500), mirroredPost(32, 3200), mirroredPost(31, 3100)}, posts...)
+	syncer.Mirror("artist")
+
+	assert.True(t, repo.PostExistsWithExternalID("32"))
+	assert.True(t, repo.PostExistsWithExternalID("31"))
+	// the second page has only archived posts
+	assert.False(t, repo.PostExistsWithExternalID("1"))
+	mirror, err := repo.GetMirror("artist")
+	assert.Nil(t, err)
+	assert.Equal(t, time.Unix(3200, 0).Unix(), mirror.NewestPostAt.Unix())
+}
+
+func TestMirrorKeepsProgressOfIncompleteRun(t *testing.T) {
+	teardown := setup()
+	defer teardown()
+
+	var posts []Post
+	for id := 20; id > 0; id-- {
+		posts = append(posts, mirroredPost(id, id*100))
+	}
+	mock := mockClient{Blogs: map[string][]Post{"artist": posts}, MissingPosts: 5}
+	syncer := Syncer{BlogName: "blog_with_posts", Client: &mock, Repo: repo}
+	syncer.Mirror("artist")
+
+	assert.True(t, repo.PostExistsWithExternalID("20"))
+	_, err := repo.GetMirror("artist")
+	assert.NotNil(t, err, "progress of incomplete run should not be saved")
+}
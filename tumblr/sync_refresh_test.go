@@ -0,0 +1,100 @@
+package tumblr
+
+import (
+	"testing"
+
+	"github.com/altmer/bellboy/media"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSyncRefreshesExistingPosts(t *testing.T) {
+	teardown := setup()
+	defer teardown()
+
+	syncer := Syncer{BlogName: "blog_with_posts", Client: &mockClient{}, Repo: repo}
+	post := Post{
+		ID:        100,
+		Type:      "link",
+		BlogName:  "linksblog",
+		Date:      "2017-01-02 12:33:44 CET",
+		URL:       "http://example.com/cats",
+		NoteCount: 5,
+		Summary:   "cats",
+		Tags:      []string{"cats"},
+	}
+	assert.True(t, syncer.syncBlogPost(&post, "added", ""))
+
+	// local edits
+	stored, _ := repo.GetPostByExternalID("100")
+	stored.Status = "approved"
+	stored.SFW = true
+	repo.UpdatePost(&stored)
+	repo.AddTagToPost(&stored, "favorite")
+
+	post.NoteCount = 42
+	post.Summary = "cats and dogs"
+	post.Tags = []string{"cats", "dogs"}
+	assert.False(t, syncer.syncBlogPost(&post, "added", ""))
+	refreshed, _ := repo.GetPostByExternalID("100")
+	assert.Equal(t, 5, refreshed.Likes, "posts are not refreshed without refresh mode")
+
+	syncer.Refresh = &media.DefaultPostMergePolicy
+	assert.False(t, syncer.syncBlogPost(&post, "added", ""))
+	refreshed, _ = repo.GetPostByExternalID("100")
+	assert.Equal(t, 42, refreshed.Likes)
+	assert.Equal(t, "cats and dogs", refreshed.Summary)
+	assert.Equal(t, "approved", refreshed.Status)
+	assert.True(t, refreshed.SFW)
+	assert.True(t, refreshed.UpdatedAt.After(stored.UpdatedAt))
+	tags, _ := repo.ListPostTags(refreshed.ID)
+	assert.Equal(t, []string{"cats", "favorite"}, tags, "tags are added only to finished posts")
+
+	refreshed.Status = "added"
+	repo.UpdatePost(&refreshed)
+	assert.False(t, syncer.syncBlogPost(&post, "added", ""))
+	tags, _ = repo.ListPostTags(refreshed.ID)
+	assert.Equal(t, []string{"cats", "dogs", "favorite"}, tags)
+
+	var count int
+	DB.Get(&count, "SELECT count(*) FROM links")
+	assert.Equal(t, 1, count)
+}
+
+func TestSyncDoesNotRefreshDeletedPosts(t *testing.T) {
+	teardown := setup()
+	defer teardown()
+
+	syncer := Syncer{BlogName: "blog_with_posts", Client: &mockClient{}, Repo: repo, Refresh: &media.DefaultPostMergePolicy}
+	post := Post{ID: 100, Type: "link", BlogName: "linksblog", Date: "2017-01-02 12:33:44 CET", URL: "http://example.com/cats"}
+	assert.True(t, syncer.syncBlogPost(&post, "added", ""))
+	stored, _ := repo.GetPostByExternalID("100")
+	repo.DeletePost(stored.ID)
+
+	post.NoteCount = 42
+	assert.False(t, syncer.syncBlogPost(&post, "added", ""))
+	stored, _ = repo.GetPostByExternalID("100")
+	assert.Equal(t, 0, stored.Likes)
+}
+
+func TestSyncRefreshSuggestsTagsOfQueuedPosts(t *testing.T) {
+	teardown := setup()
+	defer teardown()
+
+	syncer := Syncer{BlogName: "blog_with_posts", Client: &mockClient{}, Repo: repo, SuggestTags: true}
+	post := Post{ID: 100, Type: "link", BlogName: "linksblog", Date: "2017-01-02 12:33:44 CET", URL: "http://example.com/cats", Tags: []string{"cats"}}
+	assert.True(t, syncer.syncBlogPost(&post, "queued", ""))
+	stored, _ := repo.GetPostByExternalID("100")
+	repo.AddTagToPost(&stored, "dogs")
+
+	syncer.Refresh = &media.DefaultPostMergePolicy
+	post.Tags = []string{"cats", "dogs", "birds"}
+	assert.False(t, syncer.syncBlogPost(&post, "queued", ""))
+	suggested, _ := repo.ListPostSuggestedTags(stored.ID)
+	assert.Equal(t, []string{"birds", "cats", "dogs"}, suggested)
+	assigned, _ := repo.ListPostAssignedTags(stored.ID)
+	assert.Equal(t, []string{"dogs"}, assigned)
+
+	assert.Nil(t, repo.ApprovePost(stored.ID))
+	assigned, _ = repo.ListPostAssignedTags(stored.ID)
+	assert.Equal(t, []string{"birds", "cats", "dogs"}, assigned)
+}
@@ -4,6 +4,7 @@ import (
 	"github.com/jmoiron/sqlx"
 	"github.com/spf13/viper"
 	"os"
+	"strconv"
 
 	httpmock "gopkg.in/jarcoal/httpmock.v1"
 
@@ -53,26 +54,10 @@ var likes = Likes{
 			Date:        "2017-06-01 09:33:44 CET",
 			Summary:     "photo exhibition",
 			Caption:     "photo caption",
-			Photos: []struct {
-				Caption      string `json:"caption,omitempty"` // user supplied caption for the individual photo
-				OriginalSize struct {
-					Height int    `json:"height,omitempty"` // height of the image
-					Width  int    `json:"width,omitempty"`  // width of the image
-					URL    string `json:"url,omitempty"`    // location of the photo file
-				} `json:"original_size,omitempty"`
-				AlternateSizes []struct {
-					Height int    `json:"height,omitempty"` // height of the photo
-					Width  int    `json:"width,omitempty"`  // width of the photo
-					URL    string `json:"url,omitempty"`    // Location of the photo file
-				} `json:"alt_sizes,omitempty"` // alternate photo sizes
-			}{
+			Photos: []PostPhoto{
 				{
 					Caption: "photo inner caption",
-					OriginalSize: struct {
-						Height int    `json:"height,omitempty"` // height of the image
-						Width  int    `json:"width,omitempty"`  // width of the image
-						URL    string `json:"url,omitempty"`    // location of the photo file
-					}{
+					OriginalSize: PhotoSize{
 						Height: 100,
 						Width:  100,
 						URL:    "http://photo.tumblr/photo.png",
@@ -114,18 +99,53 @@ var userFollowing = UserFollowing{
 			URL:         "http://tumblr.com/blog2",
 			Description: "description2",
 			Title:       "title2",
+			Updated:     1400000000,
 		},
 	},
 }
 
 type mockClient struct {
-	UnlikedPosts  []int
-	DeletedPosts  []int
-	FollowedBlogs []string
+	UnlikedPosts    []int
+	DeletedPosts    []int
+	FollowedBlogs   []string
+	UnfollowedBlogs []string
+	Blogs           map[string][]Post // posts of other blogs, newest first
+	TaggedPosts     map[string][]Post // posts by tag, newest first
+	DashboardPosts  []Post            // newest first
+	Following       *UserFollowing    // replaces userFollowing when set
+	MissingPosts    int               // blog posts counted in totals but never returned
+}
+
+func (client mockClient) BlogInfo(blogName string) BlogInfo {
+	info := BlogInfo{}
+	if blogName != "unknown" {
+		info.Blog.Name = blogName
+		info.Blog.Title = "title of " + blogName
+		info.Blog.PostCount = 42
+		info.Blog.Updated = 1500000000
+	}
+	return info
+}
+
+func (client mockClient) BlogAvatar(blogName string, size int) BlogAvatar {
+	return BlogAvatar{AvatarURL: "http://photo.tumblr/avatar.png"}
 }
 
 func (client mockClient) BlogPosts(blogName string, params map[string]string) BlogPosts {
-	return blogPosts
+	if client.Blogs == nil {
+		return blogPosts
+	}
+	posts := client.Blogs[blogName]
+	offset, _ := strconv.Atoi(params["offset"])
+	limit, err := strconv.Atoi(params["limit"])
+	if err != nil {
+		limit = 20
+	}
+	page := BlogPosts{TotalPosts: len(posts) + client.MissingPosts}
+	for i := offset; i < offset+limit && i < len(posts); i++ {
+		page.Posts = append(page.Posts, posts[i])
+	}
+	return page
 }
 
 func (client *mockClient) PostDelete(blogName string, postID int) Meta {
@@ -142,7 +162,48 @@ func (client *mockClient) UserUnlike(postID int, reblogKey string) Meta {
 	return Meta{}
 }
 
+func (client mockClient) Tagged(tag string, params map[string]string) []Post {
+	before, err := strconv.Atoi(params["before"])
+	if err != nil {
+		before = int(^uint(0) >> 1)
+	}
+	limit, err := strconv.Atoi(params["limit"])
+	if err != nil {
+		limit = 20
+	}
+	var page []Post
+	for _, post := range client.TaggedPosts[tag] {
+		if post.Timestamp < before && len(page) < limit {
+			page = append(page, post)
+		}
+	}
+	return page
+}
+
+func (client mockClient) UserDashboard(params map[string]string) BlogList {
+	sinceID, _ := strconv.Atoi(params["since_id"])
+	offset, _ := strconv.Atoi(params["offset"])
+	limit, err := strconv.Atoi(params["limit"])
+	if err != nil {
+		limit = 20
+	}
+	var posts []Post
+	for _, post := range client.DashboardPosts {
+		if post.ID > sinceID {
+			posts = append(posts, post)
+		}
+	}
+	dashboard := BlogList{}
+	for i := offset; i < offset+limit && i < len(posts); i++ {
+		dashboard.Posts = append(dashboard.Posts, posts[i])
+	}
+	return dashboard
+}
+
 func (client mockClient) UserFollowing(params map[string]string) UserFollowing {
+	if client.Following != nil {
+		return *client.Following
+	}
 	return userFollowing
 }
 
@@ -151,6 +212,11 @@ func (client *mockClient) UserFollow(followURL string) Meta {
 	return Meta{}
 }
 
+func (client *mockClient) UserUnfollow(unfollowURL string) Meta {
+	client.UnfollowedBlogs = append(client.UnfollowedBlogs, unfollowURL)
+	return Meta{}
+}
+
 var DB *sqlx.DB
 var repo media.Repository
 
@@ -163,6 +229,8 @@ func setup() func() {
 		httpmock.NewStringResponder(200, "mp4 file contents"))
 	httpmock.RegisterResponder("GET", "http://photo.tumblr/video_thumb.png",
 		httpmock.NewStringResponder(200, "thumbnail file contents"))
+	httpmock.RegisterResponder("GET", "http://photo.tumblr/avatar.png",
+		httpmock.NewStringResponder(200, "\x89PNG\r\n\x1a\navatar file contents"))
 
 	testDBPath := "./test.db"
 	testMediaPath := "./"
@@ -181,3 +249,7 @@ func setup() func() {
 		httpmock.DeactivateAndReset()
 	}
 }
+
+func removeFile(path string) {
+	os.Remove(path)
+}
@@ -0,0 +1,105 @@
+package tumblr
+
+import (
+	"testing"
+	"time"
+
+	"github.com/altmer/bellboy/media"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubsDownKeepsNotes(t *testing.T) {
+	teardown := setup()
+	defer teardown()
+
+	synced := time.Now().Add(-time.Hour)
+	repo.AddSubscription(&media.Subscription{BlogName: "blog1", URL: "http://tumblr.com/blog1", Title: "old title", SyncedAt: &synced})
+	repo.AddSubscription(&media.Subscription{BlogName: "gone", URL: "http://tumblr.com/gone", SyncedAt: &synced})
+	repo.AddSubscription(&media.Subscription{BlogName: "mine", URL: "http://tumblr.com/mine"})
+	assert.Nil(t, repo.SetSubscriptionNotes("blog1", "great art"))
+
+	Syncer{Client: &mockClient{}, Repo: repo}.SubsDown()
+
+	subs, err := repo.ListSubscriptions()
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(subs))
+	assert.Equal(t, "blog1", subs[0].BlogName)
+	assert.Equal(t, "title1", subs[0].Title)
+	assert.Equal(t, "description1", subs[0].Description)
+	assert.Equal(t, "great art", subs[0].Notes)
+	assert.True(t, subs[0].SyncedAt.After(synced))
+	assert.Equal(t, "mine", subs[1].BlogName, "subscriptions never followed on tumblr are kept")
+	assert.Nil(t, subs[1].SyncedAt)
+	assert.Equal(t, "blog2", subs[2].BlogName)
+	assert.NotNil(t, subs[2].SyncedAt)
+}
+
+func TestSubsDownKeepsSubscriptionsWithoutRemoteOnes(t *testing.T) {
+	teardown := setup()
+	defer teardown()
+
+	synced := time.Now().Add(-time.Hour)
+	repo.AddSubscription(&media.Subscription{BlogName: "blog1", URL: "http://tumblr.com/blog1", SyncedAt: &synced})
+
+	Syncer{Client: &mockClient{Following: &UserFollowing{}}, Repo: repo}.SubsDown()
+
+	subs, err := repo.ListSubscriptions()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(subs))
+}
+
+func TestSubsDownDryRun(t *testing.T) {
+	teardown := setup()
+	defer teardown()
+
+	repo.AddSubscription(&media.Subscription{BlogName: "blog1", URL: "http://tumblr.com/blog1", Title: "old title"})
+	repo.AddSubscription(&media.Subscription{BlogName: "gone", URL: "http://tumblr.com/gone"})
+
+	Syncer{Client: &mockClient{}, Repo: repo, DryRun: true}.SubsDown()
+
+	subs, err := repo.ListSubscriptions()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(subs))
+	assert.Equal(t, "old title", subs[0].Title)
+	assert.Equal(t, "gone", subs[1].BlogName)
+}
+
+func TestSubsUpUnfollows(t *testing.T) {
+	teardown := setup()
+	defer teardown()
+
+	repo.AddSubscription(&media.Subscription{BlogName: "blog1", URL: "http://tumblr.com/blog1"})
+	repo.AddSubscription(&media.Subscription{BlogName: "a", URL: "http://tumblr.com/a"})
+
+	mock := mockClient{}
+	Syncer{Client: &mock, Repo: repo, DryRun: true}.SubsUp()
+	assert.Empty(t, mock.FollowedBlogs)
+
+	Syncer{Client: &mock, Repo: repo}.SubsUp()
+	assert.Equal(t, []string{"http://tumblr.com/a"}, mock.FollowedBlogs)
+	assert.Empty(t, mock.UnfollowedBlogs, "blogs are unfollowed only when asked")
+
+	// followed blog is synced now, it is not followed again when unfollowed on tumblr
+	mock = mockClient{}
+	Syncer{Client: &mock, Repo: repo}.SubsUp()
+	assert.Empty(t, mock.FollowedBlogs)
+
+	mock = mockClient{}
+	Syncer{Client: &mock, Repo: repo, Unfollow: true}.SubsUp()
+	assert.Equal(t, []string{"http://tumblr.com/blog2"}, mock.UnfollowedBlogs)
+
+	mock = mockClient{}
+	Syncer{Client: &mock, Repo: repo, Unfollow: true, DryRun: true}.SubsUp()
+	assert.Empty(t, mock.FollowedBlogs)
+	assert.Empty(t, mock.UnfollowedBlogs)
+}
+
+func TestSubsUpKeepsFollowingWithoutLocalSubscriptions(t *testing.T) {
+	teardown := setup()
+	defer teardown()
+
+	mock := mockClient{}
+	Syncer{Client: &mock, Repo: repo, Unfollow: true}.SubsUp()
+	assert.Empty(t, mock.FollowedBlogs)
+	assert.Empty(t, mock.UnfollowedBlogs)
+}
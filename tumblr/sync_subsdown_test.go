@@ -4,6 +4,7 @@ import (
 	"github.com/altmer/bellboy/media"
 	"github.com/stretchr/testify/assert"
 	"testing"
+	"time"
 )
 
 func TestSubsDown(t *testing.T) {
@@ -15,11 +16,13 @@ func TestSubsDown(t *testing.T) {
 	teardown := setup()
 	defer teardown()
 
-	repo.AddSubscription(&media.Subscription{BlogName: "a"})
-	repo.AddSubscription(&media.Subscription{BlogName: "b"})
-	repo.AddSubscription(&media.Subscription{BlogName: "c"})
-	repo.AddSubscription(&media.Subscription{BlogName: "d"})
-	repo.AddSubscription(&media.Subscription{BlogName: "e"})
+	// blogs were followed on tumblr when synced last time
+	synced := time.Now().Add(-time.Hour)
+	repo.AddSubscription(&media.Subscription{BlogName: "a", SyncedAt: &synced})
+	repo.AddSubscription(&media.Subscription{BlogName: "b", SyncedAt: &synced})
+	repo.AddSubscription(&media.Subscription{BlogName: "c", SyncedAt: &synced})
+	repo.AddSubscription(&media.Subscription{BlogName: "d", SyncedAt: &synced})
+	repo.AddSubscription(&media.Subscription{BlogName: "e", SyncedAt: &synced})
 	var subsCount int
 	DB.Get(&subsCount, "SELECT count(*) FROM subscriptions")
 
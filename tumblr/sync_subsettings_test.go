@@ -0,0 +1,50 @@
+package tumblr
+
+import (
+	"testing"
+
+	"github.com/altmer/bellboy/media"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSyncAppliesSubscriptionSettings(t *testing.T) {
+	teardown := setup()
+	defer teardown()
+
+	sfw := true
+	sub := &media.Subscription{BlogName: "linksblog"}
+	repo.AddSubscription(sub)
+	sub.SubscriptionSettings = media.SubscriptionSettings{AutoApprove: true, SFW: &sfw, DefaultTags: "art, links", Archive: true}
+	assert.Nil(t, repo.UpdateSubscriptionSettings(sub))
+
+	syncer := Syncer{BlogName: "blog_with_posts", Client: &mockClient{}, Repo: repo}
+	post := Post{
+		ID:       100,
+		Type:     "link",
+		BlogName: "linksblog",
+		Date:     "2017-01-02 12:33:44 CET",
+		URL:      "http://example.com/art",
+		Tags:     []string{"cats"},
+	}
+	// archived blog posts are kept on tumblr
+	assert.False(t, syncer.syncBlogPost(&post, "queued", ""))
+
+	stored, err := repo.GetPostByExternalID("100")
+	assert.Nil(t, err)
+	assert.Equal(t, "added", stored.Status)
+	assert.True(t, stored.SFW)
+	tags, _ := repo.ListPostTags(stored.ID)
+	assert.ElementsMatch(t, []string{"art", "cats", "links"}, tags)
+
+	post = Post{
+		ID:       101,
+		Type:     "link",
+		BlogName: "otherblog",
+		Date:     "2017-01-02 12:33:44 CET",
+		URL:      "http://example.com/other",
+	}
+	assert.True(t, syncer.syncBlogPost(&post, "queued", ""))
+	stored, _ = repo.GetPostByExternalID("101")
+	assert.Equal(t, "queued", stored.Status)
+	assert.False(t, stored.SFW)
+}
@@ -0,0 +1,47 @@
+package tumblr
+
+import (
+	"testing"
+	"time"
+
+	"github.com/altmer/bellboy/media"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubsRefresh(t *testing.T) {
+	teardown := setup()
+	defer teardown()
+	defer removeFile("./avatar_1.png")
+
+	repo.AddSubscription(&media.Subscription{BlogName: "blog1", URL: "http://tumblr.com/blog1"})
+	repo.AddSubscription(&media.Subscription{BlogName: "unknown", URL: "http://tumblr.com/unknown"})
+
+	Syncer{Client: &mockClient{}, Repo: repo}.SubsRefresh()
+
+	subs, err := repo.ListSubscriptions()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(subs))
+
+	assert.Equal(t, 42, subs[0].PostCount)
+	assert.Equal(t, time.Unix(1500000000, 0).Unix(), subs[0].LastPostAt.Unix())
+	assert.Equal(t, "image/png", subs[0].AvatarMimeType)
+	assert.Equal(t, "avatar_1.png", subs[0].AvatarFileName())
+	assert.FileExists(t, "./avatar_1.png")
+
+	assert.Nil(t, subs[1].LastPostAt)
+	assert.Equal(t, 0, subs[1].PostCount)
+	assert.Equal(t, "", repo.GetSubscriptionAvatarPath(&subs[1]))
+}
+
+func TestSubsDownStoresActivity(t *testing.T) {
+	teardown := setup()
+	defer teardown()
+
+	Syncer{Client: &mockClient{}, Repo: repo}.SubsDown()
+
+	subs, err := repo.ListSubscriptions()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(subs))
+	assert.Nil(t, subs[0].LastPostAt)
+	assert.Equal(t, time.Unix(1400000000, 0).Unix(), subs[1].LastPostAt.Unix())
+}
@@ -0,0 +1,136 @@
+package tumblr
+
+import (
+	"testing"
+
+	"github.com/altmer/bellboy/media"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSyncAppliesTagAliases(t *testing.T) {
+	teardown := setup()
+	defer teardown()
+
+	repo.AddTagAlias("Cats", "cats")
+	repo.AddTagAlias("#cats", "cats")
+	repo.AddTagAlias("kitty", "cats")
+
+	syncer := Syncer{BlogName: "blog_with_posts", Client: &mockClient{}, Repo: repo}
+	post := Post{
+		ID:       100,
+		Type:     "link",
+		BlogName: "linksblog",
+		Date:     "2017-01-02 12:33:44 CET",
+		URL:      "http://example.com/cats",
+		Tags:     []string{"Cats", "#cats", "cats", "kitty", "dogs"},
+	}
+	assert.True(t, syncer.syncBlogPost(&post, "added", ""))
+
+	var tags []string
+	DB.Select(&tags, `SELECT tags.name FROM tags
+		INNER JOIN posts_tags ON posts_tags.tag_id = tags.id ORDER BY tags.name`)
+	assert.Equal(t, []string{"cats", "dogs"}, tags)
+}
+
+func TestSyncNormalizesTags(t *testing.T) {
+	teardown := setup()
+	defer teardown()
+
+	repo.AddTagAlias("kitty", "cats")
+
+	syncer := Syncer{
+		BlogName: "blog_with_posts",
+		Client:   &mockClient{},
+		Repo:     repo,
+		Tags: media.TagNormalizer{
+			Unicode:        true,
+			CaseFold:       true,
+			CollapseSpaces: true,
+			StripPrefixes:  []string{"#"},
+			StopList:       []string{"reblog"},
+		},
+	}
+	post := Post{
+		ID:       100,
+		Type:     "link",
+		BlogName: "linksblog",
+		Date:     "2017-01-02 12:33:44 CET",
+		URL:      "http://example.com/cats",
+		Tags:     []string{"Cats", "#CATS", " black  cats ", "KITTY", "Reblog", "#"},
+	}
+	assert.True(t, syncer.syncBlogPost(&post, "added", ""))
+
+	var tags []string
+	DB.Select(&tags, `SELECT tags.name FROM tags
+		INNER JOIN posts_tags ON posts_tags.tag_id = tags.id ORDER BY tags.name`)
+	assert.Equal(t, []string{"black cats", "cats"}, tags)
+}
+
+func TestSyncSuggestsTagsOfQueuedPosts(t *testing.T) {
+	teardown := setup()
+	defer teardown()
+
+	post := Post{
+		ID:       100,
+		Type:     "link",
+		BlogName: "linksblog",
+		Date:     "2017-01-02 12:33:44 CET",
+		URL:      "http://example.com/cats",
+		Tags:     []string{"#cats", "cute"},
+	}
+	syncer := Syncer{BlogName: "blog_with_posts", Client: &mockClient{}, Repo: repo}
+	assert.True(t, syncer.syncBlogPost(&post, "queued", ""))
+	tags, _ := repo.ListSuggestedTags()
+	assert.Empty(t, tags)
+
+	post.ID = 101
+	syncer.SuggestTags = true
+	syncer.Tags = media.TagNormalizer{StripPrefixes: []string{"#"}}
+	assert.True(t, syncer.syncBlogPost(&post, "queued", ""))
+	tags, _ = repo.ListSuggestedTags()
+	var names []string
+	for _, tag := range tags {
+		names = append(names, tag.Name)
+	}
+	assert.Equal(t, []string{"cats", "cute"}, names)
+
+	var count int
+	DB.Get(&count, "SELECT count(*) FROM posts_tags")
+	assert.Equal(t, 0, count)
+}
+
+func TestSyncAppliesRules(t *testing.T) {
+	teardown := setup()
+	defer teardown()
+
+	rules, err := media.ParseRules([]byte(`
+rules:
+  - if: {blog: artblog}
+    then: {sfw: true, status: approved, add_tags: art}
+  - if: {blog: spamblog}
+    then: {skip: true}
+`))
+	assert.Nil(t, err)
+	syncer := Syncer{BlogName: "blog_with_posts", Client: &mockClient{}, Repo: repo, Rules: rules}
+
+	post := Post{
+		ID:       100,
+		Type:     "link",
+		BlogName: "artblog",
+		Date:     "2017-01-02 12:33:44 CET",
+		URL:      "http://example.com/art",
+		Tags:     []string{"painting"},
+	}
+	assert.True(t, syncer.syncBlogPost(&post, "queued", ""))
+	var stored media.Post
+	DB.Get(&stored, "SELECT * FROM posts WHERE external_id = '100'")
+	assert.True(t, stored.SFW)
+	assert.Equal(t, "added", stored.Status)
+	tags, _ := repo.ListPostTags(stored.ID)
+	assert.Equal(t, []string{"art", "painting"}, tags)
+
+	post.ID = 101
+	post.BlogName = "spamblog"
+	assert.False(t, syncer.syncBlogPost(&post, "queued", ""))
+	assert.False(t, repo.PostExistsWithExternalID("101"))
+}
@@ -86,20 +86,8 @@ type Post struct {
 	Title string `json:"title,omitempty"` // The optional title of the post
 	Body  string `json:"body,omitempty"`  // The full post body
 	// Photo posts
-	Caption string `json:"caption,omitempty"` // The user-supplied caption
-	Photos  []struct {
-		Caption      string `json:"caption,omitempty"` // user supplied caption for the individual photo
-		OriginalSize struct {
-			Height int    `json:"height,omitempty"` // height of the image
-			Width  int    `json:"width,omitempty"`  // width of the image
-			URL    string `json:"url,omitempty"`    // location of the photo file
-		} `json:"original_size,omitempty"`
-		AlternateSizes []struct {
-			Height int    `json:"height,omitempty"` // height of the photo
-			Width  int    `json:"width,omitempty"`  // width of the photo
-			URL    string `json:"url,omitempty"`    // Location of the photo file
-		} `json:"alt_sizes,omitempty"` // alternate photo sizes
-	} `json:"photos,omitempty"`
+	Caption string      `json:"caption,omitempty"` // The user-supplied caption
+	Photos  []PostPhoto `json:"photos,omitempty"`
 	// Link posts
 	URL         string `json:"url,omitempty"`         // The link
 	Author      string `json:"author,omitempty"`      // The author of the article the link points to
@@ -115,6 +103,20 @@ type Post struct {
 	} `json:"player,omitempty"`
 }
 
+// PostPhoto represents one photo of the photo post
+type PostPhoto struct {
+	Caption        string      `json:"caption,omitempty"`       // user supplied caption for the individual photo
+	OriginalSize   PhotoSize   `json:"original_size,omitempty"` // original photo size
+	AlternateSizes []PhotoSize `json:"alt_sizes,omitempty"`     // alternate photo sizes
+}
+
+// PhotoSize represents one of the sizes photo is available in
+type PhotoSize struct {
+	Height int    `json:"height,omitempty"` // height of the photo
+	Width  int    `json:"width,omitempty"`  // width of the photo
+	URL    string `json:"url,omitempty"`    // location of the photo file
+}
+
 // UserInfo /user/info – Get a User's Information
 type UserInfo struct {
 	User struct {